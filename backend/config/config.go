@@ -28,8 +28,124 @@ type Config struct {
 		DefaultModel string `yaml:"default_model"`
 	} `yaml:"ai"`
 	System struct {
-		Port string `yaml:"port"`
+		Port           string   `yaml:"port"`
+		TrustedProxies []string `yaml:"trusted_proxies"` // 可信反向代理的 IP 或网段，只有来自这些地址的 X-Forwarded-For 才会被采用；默认不信任任何代理
 	}
+	Feed struct {
+		Title   string `yaml:"title"`    // 订阅源标题
+		SiteURL string `yaml:"site_url"` // 前端站点地址，用作订阅源主页链接
+		BaseURL string `yaml:"base_url"` // 接口对外地址，生成订阅源 id 和 self 链接，为空时使用 site_url
+		Limit   int    `yaml:"limit"`    // 每个订阅源的条目数，默认 50
+	} `yaml:"feed"`
+	Article struct {
+		Enabled bool `yaml:"enabled"` // 更新任务中抓取新闻原文正文
+		Jobs    int  `yaml:"jobs"`    // 并发抓取数，默认 4
+	} `yaml:"article"`
+	Translation struct {
+		Enabled   bool     `yaml:"enabled"`   // 更新任务中翻译新闻标题和摘要
+		Languages []string `yaml:"languages"` // 需要提供的语言，默认 zh、en
+	} `yaml:"translation"`
+	Review struct {
+		RequireApproval []string `yaml:"require_approval"` // 需人工审核后才发布的批次类型，如 [morning, evening]，为空表示自动发布
+	} `yaml:"review"`
+	Login        LoginConfig       `yaml:"login"`
+	Session      SessionConfig     `yaml:"session"`
+	Password     PasswordConfig    `yaml:"password"`
+	CORS         CORSConfig        `yaml:"cors"`
+	OIDC         OIDCConfig        `yaml:"oidc"`
+	Publishers   []PublisherConfig `yaml:"publishers"`
+	SMTP         SMTPConfig        `yaml:"smtp"`
+	Subscription struct {
+		BaseURL         string `yaml:"base_url"`          // 确认/退订链接的后端地址，如 https://cj.wsky.fun
+		MaxAttempts     int    `yaml:"max_attempts"`      // 单封邮件最大发送次数，默认 5
+		ConfirmTTLHours int    `yaml:"confirm_ttl_hours"` // 确认链接有效期（小时），默认 48
+		ResendMinutes   int    `yaml:"resend_minutes"`    // 同一邮箱两次发送确认邮件的最小间隔（分钟），默认 10
+		MaxPerIPHour    int    `yaml:"max_per_ip_hour"`   // 同一 IP 每小时最多提交订阅次数，默认 5
+	} `yaml:"subscription"`
+}
+
+// LoginConfig 管理端登录限流与锁定策略，未配置的项使用默认值
+type LoginConfig struct {
+	MaxUserFailures      int    `yaml:"max_user_failures"`      // 同一用户名在窗口内失败次数达到后锁定，默认 5
+	MaxIPFailures        int    `yaml:"max_ip_failures"`        // 同一 IP 在窗口内失败次数达到后锁定，默认 20
+	WindowMinutes        int    `yaml:"window_minutes"`         // 失败计数窗口（分钟），默认 15
+	LockoutMinutes       int    `yaml:"lockout_minutes"`        // 首次锁定时长（分钟），之后每次翻倍，默认 15
+	MaxLockoutMinutes    int    `yaml:"max_lockout_minutes"`    // 锁定时长上限（分钟），默认 1440
+	HistoryRetentionDays int    `yaml:"history_retention_days"` // 登录记录保留天数，默认 90
+	TOTPIssuer           string `yaml:"totp_issuer"`            // 两步验证 App 中显示的名称，默认 BRE News
+}
+
+// SessionConfig 管理端会话有效期，未配置的项使用默认值
+type SessionConfig struct {
+	TTLHours    int    `yaml:"ttl_hours"`    // 登录后最长有效期（小时），默认 720
+	IdleMinutes int    `yaml:"idle_minutes"` // 超过该时长未使用即失效，每次使用顺延，默认 4320；-1 表示不限制
+	TokenPrefix string `yaml:"token_prefix"` // 新签发令牌的前缀，便于在日志中识别令牌类型，如 bres_；默认不加
+}
+
+// PasswordConfig 管理员密码强度与有效期，未配置的项使用默认值
+type PasswordConfig struct {
+	MinLength     int    `yaml:"min_length"`     // 最短长度，默认 10
+	MinClasses    int    `yaml:"min_classes"`    // 至少包含的字符类别数（小写、大写、数字、符号），默认 3
+	MaxAgeDays    int    `yaml:"max_age_days"`   // 超过该天数须修改密码，0 表示不过期
+	BlocklistFile string `yaml:"blocklist_file"` // 额外的弱密码列表，每行一个，与内置常见密码一起校验
+}
+
+// CORSConfig 跨域策略，公开接口和管理端接口（/api/admin）分别配置
+type CORSConfig struct {
+	Public CORSPolicyConfig `yaml:"public"`
+	Admin  CORSPolicyConfig `yaml:"admin"`
+}
+
+// CORSPolicyConfig 单组接口的跨域策略，未配置的项使用默认值
+type CORSPolicyConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`   // 如 https://admin.example.com、https://*.example.com（任意子域名），* 表示任意来源
+	AllowedMethods   []string `yaml:"allowed_methods"`   // 允许的方法
+	AllowedHeaders   []string `yaml:"allowed_headers"`   // 预检请求中允许的请求头
+	AllowCredentials bool     `yaml:"allow_credentials"` // 是否允许携带 Cookie 等凭据，不能与 * 同时使用
+	MaxAgeSeconds    int      `yaml:"max_age_seconds"`   // 预检结果缓存时长，默认 86400
+}
+
+// OIDCConfig 管理端单点登录（OpenID Connect 授权码 + PKCE），与密码登录并存
+type OIDCConfig struct {
+	Enabled        bool              `yaml:"enabled"`
+	Issuer         string            `yaml:"issuer"` // 身份提供方地址，从 {issuer}/.well-known/openid-configuration 读取端点
+	ClientID       string            `yaml:"client_id"`
+	ClientSecret   string            `yaml:"client_secret"`   // 公共客户端可留空，仅使用 PKCE
+	RedirectURL    string            `yaml:"redirect_url"`    // 回调地址，指向 /api/admin/oidc/callback
+	FrontendURL    string            `yaml:"frontend_url"`    // 登录完成后跳回的管理后台地址
+	Scopes         []string          `yaml:"scopes"`          // 默认 openid profile email
+	ButtonLabel    string            `yaml:"button_label"`    // 登录页按钮文字，默认“单点登录”
+	UsernameClaim  string            `yaml:"username_claim"`  // 用作用户名的声明，默认 preferred_username，缺失时用 email
+	GroupsClaim    string            `yaml:"groups_claim"`    // 用户组声明，默认 groups
+	RoleMapping    map[string]string `yaml:"role_mapping"`    // 用户组 -> 角色，匹配多个时取权限最高的角色，每次登录同步
+	DefaultRole    string            `yaml:"default_role"`    // 没有匹配的用户组时使用的角色，为空则拒绝登录
+	AutoProvision  bool              `yaml:"auto_provision"`  // 首次登录时自动创建管理员
+	AllowedDomains []string          `yaml:"allowed_domains"` // 限制已验证邮箱的域名，为空不限制
+}
+
+// SMTPConfig 邮件发送配置
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	TLS      bool   `yaml:"tls"` // true 为隐式 TLS（465 端口），否则在服务器支持时使用 STARTTLS
+}
+
+// PublisherConfig 群聊机器人推送渠道配置
+type PublisherConfig struct {
+	Name         string   `yaml:"name"`
+	Type         string   `yaml:"type"` // dingtalk, feishu, wecom, telegram
+	Enabled      bool     `yaml:"enabled"`
+	Webhook      string   `yaml:"webhook"`       // dingtalk / feishu / wecom 机器人地址
+	Secret       string   `yaml:"secret"`        // dingtalk / feishu 加签密钥，可选
+	BotToken     string   `yaml:"bot_token"`     // telegram
+	ChatID       string   `yaml:"chat_id"`       // telegram
+	APIBase      string   `yaml:"api_base"`      // telegram，默认 https://api.telegram.org
+	BatchTypes   []string `yaml:"batch_types"`   // 为空表示推送所有批次
+	AnalysisType string   `yaml:"analysis_type"` // 附带的分析类型，默认 3_day
+	Template     string   `yaml:"template"`      // text/template 模板，为空使用默认模板
 }
 
 func InitConfig() {
@@ -59,6 +175,11 @@ func InitDB() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// 新闻与标签的关联表带有来源字段，需在迁移前注册
+	if err := DB.SetupJoinTable(&models.NewsItem{}, "Tags", &models.NewsItemTag{}); err != nil {
+		log.Fatal("Failed to setup join table:", err)
+	}
+
 	// Auto Migrate
 	err = DB.AutoMigrate(
 		&models.BatchLog{},
@@ -69,6 +190,24 @@ func InitDB() {
 		&models.SiteItem{},
 		&models.AdminUser{},
 		&models.AdminSession{},
+		&models.Subscriber{},
+		&models.EmailQueue{},
+		&models.NewsSource{},
+		&models.NewsSourceItem{},
+		&models.Tag{},
+		&models.NewsItemTag{},
+		&models.Entity{},
+		&models.EntityMention{},
+		&models.TrendingTopic{},
+		&models.NewsTranslation{},
+		&models.AuditLog{},
+		&models.LoginAttempt{},
+		&models.LoginLock{},
+		&models.AdminRecoveryCode{},
+		&models.AdminLoginChallenge{},
+		&models.AdminSetting{},
+		&models.APIKey{},
+		&models.OIDCLoginState{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
@@ -6,12 +6,14 @@ import (
 	"bre_new_backend/services"
 	"errors"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func AdminSetup(c *gin.Context) {
@@ -25,7 +27,7 @@ func AdminSetup(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
 		return
 	}
-	session, err := services.CreateAdminSession(config.DB, user.ID, 30*24*time.Hour)
+	_, token, err := services.CreateAdminSession(config.DB, user.ID, services.CurrentSessionPolicy().TTL, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create session failed"})
 		return
@@ -34,7 +36,7 @@ func AdminSetup(c *gin.Context) {
 		"code": 200,
 		"msg":  "success",
 		"data": gin.H{
-			"token": session.Token,
+			"token": token,
 			"user":  gin.H{"id": user.ID, "username": user.Username},
 		},
 	})
@@ -47,47 +49,105 @@ func AdminLogin(c *gin.Context) {
 		return
 	}
 
-	user, err := services.ValidateAdminLogin(config.DB, req.Username, req.Password)
+	user, err := services.AttemptAdminLogin(config.DB, services.CurrentLoginPolicy(), req.Username, req.Password, c.ClientIP(), c.Request.UserAgent(), time.Now())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "invalid credentials"})
+		loginError(c, err)
 		return
 	}
 
-	session, err := services.CreateAdminSession(config.DB, user.ID, 30*24*time.Hour)
+	// 启用两步验证的账号先返回挑战，验证码通过后再创建会话
+	if user.TOTPEnabled {
+		challenge, err := services.CreateLoginChallenge(config.DB, user.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "login failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"msg":  "success",
+			"data": gin.H{"mfa_required": true, "challenge": challenge.Token, "expires_at": challenge.ExpiresAt},
+		})
+		return
+	}
+	issueAdminSession(c, user)
+}
+
+// AdminLoginVerify 第二步验证：{"challenge": "...", "code": "123456"}，code 也可以是恢复码
+func AdminLoginVerify(c *gin.Context) {
+	var req struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Challenge == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	user, err := services.CompleteLoginChallenge(config.DB, services.CurrentLoginPolicy(), req.Challenge, req.Code, c.ClientIP(), c.Request.UserAgent(), time.Now())
+	if err != nil {
+		loginError(c, err)
+		return
+	}
+	issueAdminSession(c, user)
+}
+
+// loginError 锁定中返回 429 和 Retry-After，凭据或验证码错误返回 401
+func loginError(c *gin.Context, err error) {
+	var locked *services.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		retry := int(time.Until(locked.Until).Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(retry))
+		c.JSON(http.StatusTooManyRequests, gin.H{"code": 429, "msg": "too many failed attempts", "data": gin.H{"locked_until": locked.Until}})
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrInvalidOTP), errors.Is(err, services.ErrChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "login failed"})
+	}
+}
+
+// issueAdminSession 创建会话并返回登录结果；策略要求两步验证而账号未启用，或密码须修改时提示前端先处理
+func issueAdminSession(c *gin.Context, user *models.AdminUser) {
+	_, token, err := services.CreateAdminSession(config.DB, user.ID, services.CurrentSessionPolicy().TTL, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create session failed"})
 		return
 	}
+	setupRequired := false
+	if !user.TOTPEnabled {
+		setupRequired, _ = services.TwoFactorRequired(config.DB)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
 		"data": gin.H{
-			"token": session.Token,
-			"user":  gin.H{"id": user.ID, "username": user.Username},
+			"token":                    token,
+			"user":                     gin.H{"id": user.ID, "username": user.Username, "role": user.Role, "totp_enabled": user.TOTPEnabled},
+			"mfa_setup_required":       setupRequired,
+			"password_change_required": services.CurrentPasswordPolicy().PasswordChangeRequired(user, time.Now()),
 		},
 	})
 }
 
 func AdminLogout(c *gin.Context) {
-	sessionAny, ok := c.Get("adminSession")
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
-		return
-	}
-	session, _ := sessionAny.(*models.AdminSession)
+	session := currentSession(c)
 	if session == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
 		return
 	}
 
-	_ = services.DeleteAdminSession(config.DB, session.Token)
+	_ = services.DeleteAdminSession(config.DB, session.TokenHash)
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
 type AdminUserCreateRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string           `json:"username"`
+	Password string           `json:"password"`
+	Role     models.AdminRole `json:"role"` // 默认 viewer
+}
+
+type AdminUserRoleRequest struct {
+	Role models.AdminRole `json:"role"`
 }
 
 type AdminUserPasswordRequest struct {
@@ -96,11 +156,12 @@ type AdminUserPasswordRequest struct {
 
 func AdminUserList(c *gin.Context) {
 	var rows []models.AdminUser
-	if err := config.DB.Order("id asc").Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, config.DB.Model(&models.AdminUser{}), adminUserListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminUserCreate(c *gin.Context) {
@@ -109,14 +170,58 @@ func AdminUserCreate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
 		return
 	}
-	user, err := services.CreateAdminUser(config.DB, req.Username, req.Password)
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	// 他人设置的初始密码，首次登录后须修改
+	user, err := services.CreateAdminUser(config.DB, req.Username, req.Password, req.Role, true)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"id": user.ID, "username": user.Username}})
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"id": user.ID, "username": user.Username, "role": user.Role}})
+}
+
+// AdminUserSetRole 修改管理员角色，最后一个 owner 不能被降级
+func AdminUserSetRole(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req AdminUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || id == 0 || !services.IsAdminRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	user, err := services.SetAdminRole(config.DB, uint(id), req.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+			return
+		}
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": user})
+}
+
+// AdminMe 当前登录的管理员及其权限，供前端控制菜单和按钮
+func AdminMe(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"user":                     user,
+			"permissions":              services.RolePermissions[user.Role],
+			"password_change_required": services.CurrentPasswordPolicy().PasswordChangeRequired(user, time.Now()),
+			"has_password":             user.PasswordHash != "", // 单点登录账号没有本地密码
+		},
+	})
 }
 
+// AdminUserSetPassword 重置其他管理员的密码，新密码为临时密码，对方登录后须修改；修改自己的密码使用 /admin/me/password
 func AdminUserSetPassword(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 	if id == 0 {
@@ -128,6 +233,10 @@ func AdminUserSetPassword(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
 		return
 	}
+	if current := currentAdmin(c); current != nil && current.ID == uint(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "use /admin/me/password to change your own password"})
+		return
+	}
 
 	var user models.AdminUser
 	if err := config.DB.Where("id = ?", uint(id)).First(&user).Error; err != nil {
@@ -135,12 +244,11 @@ func AdminUserSetPassword(c *gin.Context) {
 		return
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
-		return
-	}
-	if err := config.DB.Model(&models.AdminUser{}).Where("id = ?", user.ID).Update("password_hash", string(hash)).Error; err != nil {
+	if err := services.SetAdminPassword(config.DB, services.CurrentPasswordPolicy(), &user, req.Password, true, time.Now()); err != nil {
+		if errors.Is(err, services.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
 		return
 	}
@@ -148,6 +256,35 @@ func AdminUserSetPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
+// AdminPasswordChange 修改自己的密码：{"old_password": "...", "new_password": "..."}，成功后注销其他会话
+func AdminPasswordChange(c *gin.Context) {
+	user := currentAdmin(c)
+	session := currentSession(c)
+	if user == nil || session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.OldPassword == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	err := services.ChangeOwnPassword(config.DB, services.CurrentPasswordPolicy(), user, req.OldPassword, req.NewPassword, session.TokenHash, time.Now())
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+	case errors.Is(err, services.ErrInvalidCredentials), errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrPasswordReused):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+	case errors.Is(err, services.ErrNoLocalPassword):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+	}
+}
+
 func AdminUserDelete(c *gin.Context) {
 	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 	if id == 0 {
@@ -165,18 +302,17 @@ func AdminUserDelete(c *gin.Context) {
 		}
 	}
 
-	tx := config.DB.Begin()
-	if err := tx.Where("user_id = ?", uint(id)).Delete(&models.AdminSession{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
-		return
-	}
-	if err := tx.Where("id = ?", uint(id)).Delete(&models.AdminUser{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+	if err := services.DeleteAdminUser(config.DB, uint(id)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrLastOwner):
+			c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+		}
 		return
 	}
-	tx.Commit()
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
@@ -187,11 +323,12 @@ type SiteCategoryUpsertRequest struct {
 
 func AdminSiteCategoryList(c *gin.Context) {
 	var rows []models.SiteCategory
-	if err := config.DB.Order("sort asc, id asc").Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, config.DB.Model(&models.SiteCategory{}), siteListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminSiteCategoryCreate(c *gin.Context) {
@@ -263,17 +400,18 @@ type SiteUpsertRequest struct {
 
 func AdminSiteList(c *gin.Context) {
 	var rows []models.SiteItem
-	q := config.DB.Order("sort asc, id asc")
+	q := config.DB.Model(&models.SiteItem{})
 	if categoryIDStr := c.Query("categoryId"); categoryIDStr != "" {
 		if categoryID, err := strconv.ParseUint(categoryIDStr, 10, 64); err == nil && categoryID > 0 {
 			q = q.Where("category_id = ?", uint(categoryID))
 		}
 	}
-	if err := q.Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, q, siteListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminSiteCreate(c *gin.Context) {
@@ -341,6 +479,119 @@ func AdminSiteDelete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
+type NewsSourceUpsertRequest struct {
+	Name         string `json:"name"`
+	Url          string `json:"url"`
+	Category     string `json:"category"`
+	PollInterval int    `json:"poll_interval"`
+	Enabled      *bool  `json:"enabled"`
+}
+
+func AdminNewsSourceList(c *gin.Context) {
+	var rows []models.NewsSource
+	meta, err := paginate(c, config.DB.Model(&models.NewsSource{}), newsSourceListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+func AdminNewsSourceCreate(c *gin.Context) {
+	var req NewsSourceUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || !isHTTPURL(req.Url) || req.PollInterval < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	row := models.NewsSource{
+		Name:         strings.TrimSpace(req.Name),
+		Url:          strings.TrimSpace(req.Url),
+		Category:     req.Category,
+		PollInterval: req.PollInterval,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if err := config.DB.Create(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": row})
+}
+
+func AdminNewsSourceUpdate(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var req NewsSourceUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PollInterval < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Url != "" {
+		if !isHTTPURL(req.Url) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+			return
+		}
+		updates["url"] = strings.TrimSpace(req.Url)
+	}
+	updates["category"] = req.Category
+	if req.PollInterval > 0 {
+		updates["poll_interval"] = req.PollInterval
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if err := config.DB.Model(&models.NewsSource{}).Where("id = ?", uint(id)).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+func AdminNewsSourceDelete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := config.DB.Where("id = ?", uint(id)).Delete(&models.NewsSource{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+// AdminNewsSourceFetch 立即抓取一次新闻源，返回最新的抓取状态
+func AdminNewsSourceFetch(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var source models.NewsSource
+	if err := config.DB.Where("id = ?", uint(id)).First(&source).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+		return
+	}
+	_, fetchErr := services.FetchNewsSource(config.DB, source, nil, time.Now())
+	_ = config.DB.Where("id = ?", source.ID).First(&source).Error
+	if fetchErr != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": fetchErr.Error(), "data": source})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": source})
+}
+
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func parseTimeFlexible(value string) (*time.Time, error) {
 	if value == "" {
 		return nil, nil
@@ -362,11 +613,14 @@ func AdminBatchList(c *gin.Context) {
 	createdAtEndStr := c.Query("createdAtEnd")
 	batchType := c.Query("type")
 
-	q := config.DB.Model(&models.BatchLog{}).Order("created_at desc")
+	q := config.DB.Model(&models.BatchLog{})
 
 	if batchType != "" {
 		q = q.Where("type = ?", batchType)
 	}
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
 	if createdAtStart, err := parseTimeFlexible(createdAtStartStr); err == nil && createdAtStart != nil {
 		q = q.Where("created_at >= ?", *createdAtStart)
 	}
@@ -375,11 +629,12 @@ func AdminBatchList(c *gin.Context) {
 	}
 
 	var rows []models.BatchLog
-	if err := q.Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, q, batchListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminBatchNewsList(c *gin.Context) {
@@ -389,11 +644,12 @@ func AdminBatchNewsList(c *gin.Context) {
 		return
 	}
 	var rows []models.NewsItem
-	if err := config.DB.Where("batch_id = ?", uint(id)).Order("id asc").Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, config.DB.Model(&models.NewsItem{}).Where("batch_id = ?", uint(id)).Preload("Tags"), batchNewsListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminBatchDelete(c *gin.Context) {
@@ -423,11 +679,13 @@ func AdminBatchDelete(c *gin.Context) {
 }
 
 type NewsUpsertRequest struct {
-	BatchID uint   `json:"batch_id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
-	Url     string `json:"url"`
-	Source  string `json:"source"`
+	BatchID  uint   `json:"batch_id"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Url      string `json:"url"`
+	Source   string `json:"source"`
+	Pinned   *bool  `json:"pinned"`
+	Featured *bool  `json:"featured"`
 }
 
 func AdminNewsList(c *gin.Context) {
@@ -436,7 +694,7 @@ func AdminNewsList(c *gin.Context) {
 	createdAtStartStr := c.Query("createdAtStart")
 	createdAtEndStr := c.Query("createdAtEnd")
 
-	q := config.DB.Model(&models.NewsItem{}).Order("created_at desc, id desc")
+	q := config.DB.Model(&models.NewsItem{})
 	if batchIDStr != "" {
 		if batchID, err := strconv.ParseUint(batchIDStr, 10, 64); err == nil && batchID > 0 {
 			q = q.Where("batch_id = ?", uint(batchID))
@@ -445,6 +703,12 @@ func AdminNewsList(c *gin.Context) {
 	if keyword != "" {
 		q = q.Where("title like ?", "%"+keyword+"%")
 	}
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		q = withTag(q, tag)
+	}
 	if createdAtStart, err := parseTimeFlexible(createdAtStartStr); err == nil && createdAtStart != nil {
 		q = q.Where("created_at >= ?", *createdAtStart)
 	}
@@ -453,11 +717,12 @@ func AdminNewsList(c *gin.Context) {
 	}
 
 	var rows []models.NewsItem
-	if err := q.Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, q.Preload("Tags"), newsListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminNewsCreate(c *gin.Context) {
@@ -466,12 +731,26 @@ func AdminNewsCreate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
 		return
 	}
+	status, ok := batchStatus(c, req.BatchID)
+	if !ok {
+		return
+	}
+	rank, err := services.NextSortRank(config.DB, req.BatchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
+		return
+	}
 	row := models.NewsItem{
 		BatchID:   req.BatchID,
 		Title:     strings.TrimSpace(req.Title),
 		Content:   req.Content,
 		Url:       req.Url,
 		Source:    req.Source,
+		Language:  services.DetectLanguage(req.Title),
+		Status:    status,
+		Pinned:    req.Pinned != nil && *req.Pinned,
+		Featured:  req.Featured != nil && *req.Featured,
+		SortRank:  rank,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -479,6 +758,13 @@ func AdminNewsCreate(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
 		return
 	}
+	if row.Pinned {
+		if err := services.RenumberBatchNews(config.DB, row.BatchID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
+			return
+		}
+		_ = config.DB.First(&row, row.ID).Error
+	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": row})
 }
 
@@ -494,14 +780,42 @@ func AdminNewsUpdate(c *gin.Context) {
 		return
 	}
 
+	var row models.NewsItem
+	if err := config.DB.Select("id, batch_id, title, pinned, status").First(&row, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
 	updates := map[string]interface{}{
 		"updated_at": time.Now(),
 	}
-	if req.BatchID != 0 {
+	batchID := row.BatchID
+	if req.BatchID != 0 && req.BatchID != row.BatchID {
+		status, ok := batchStatus(c, req.BatchID)
+		if !ok {
+			return
+		}
+		rank, err := services.NextSortRank(config.DB, req.BatchID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+			return
+		}
 		updates["batch_id"] = req.BatchID
+		updates["sort_rank"] = rank
+		batchID = req.BatchID
+		// 换到其他批次后沿用新批次的发布状态，单独驳回的条目保持驳回
+		if row.Status != models.StatusRejected {
+			updates["status"] = status
+		}
 	}
+	titleChanged := false
 	if title := strings.TrimSpace(req.Title); title != "" {
 		updates["title"] = title
+		if title != row.Title {
+			// 标题变化后重新识别语言，旧译文已不对应，删除后可对批次重新执行翻译
+			titleChanged = true
+			updates["language"] = services.DetectLanguage(title)
+		}
 	}
 	if req.Content != "" {
 		updates["content"] = req.Content
@@ -512,15 +826,37 @@ func AdminNewsUpdate(c *gin.Context) {
 	if req.Source != "" {
 		updates["source"] = req.Source
 	}
+	if req.Pinned != nil {
+		updates["pinned"] = *req.Pinned
+	}
+	if req.Featured != nil {
+		updates["featured"] = *req.Featured
+	}
 
 	if len(updates) == 1 {
 		c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 		return
 	}
-	if err := config.DB.Model(&models.NewsItem{}).Where("id = ?", uint(id)).Updates(updates).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.NewsItem{}).Where("id = ?", uint(id)).Updates(updates).Error; err != nil {
+			return err
+		}
+		if titleChanged {
+			return tx.Where("news_item_id = ?", uint(id)).Delete(&models.NewsTranslation{}).Error
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
 		return
 	}
+	// 置顶状态变化后重新编号，保证置顶新闻排在最前
+	if (req.Pinned != nil && *req.Pinned != row.Pinned) || batchID != row.BatchID {
+		if err := services.RenumberBatchNews(config.DB, batchID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
@@ -537,6 +873,31 @@ func AdminNewsDelete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
 
+// AdminNewsExtract 重新抓取单条新闻的原文正文
+func AdminNewsExtract(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var row models.NewsItem
+	if err := config.DB.Where("id = ?", uint(id)).First(&row).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+		return
+	}
+	if row.Url == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "url empty"})
+		return
+	}
+	extractErr := services.ExtractNewsItem(config.DB, row, services.FetchArticle)
+	_ = config.DB.Where("id = ?", row.ID).First(&row).Error
+	if extractErr != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": extractErr.Error(), "data": row})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": row})
+}
+
 type AnalysisUpsertRequest struct {
 	BatchID uint   `json:"batch_id"`
 	Type    string `json:"type"`
@@ -549,7 +910,7 @@ func AdminAnalysisList(c *gin.Context) {
 	createdAtStartStr := c.Query("createdAtStart")
 	createdAtEndStr := c.Query("createdAtEnd")
 
-	q := config.DB.Model(&models.Analysis{}).Order("created_at desc, id desc")
+	q := config.DB.Model(&models.Analysis{})
 	if batchIDStr != "" {
 		if batchID, err := strconv.ParseUint(batchIDStr, 10, 64); err == nil && batchID > 0 {
 			q = q.Where("batch_id = ?", uint(batchID))
@@ -558,6 +919,9 @@ func AdminAnalysisList(c *gin.Context) {
 	if analysisType != "" {
 		q = q.Where("type = ?", analysisType)
 	}
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
 	if createdAtStart, err := parseTimeFlexible(createdAtStartStr); err == nil && createdAtStart != nil {
 		q = q.Where("created_at >= ?", *createdAtStart)
 	}
@@ -566,11 +930,12 @@ func AdminAnalysisList(c *gin.Context) {
 	}
 
 	var rows []models.Analysis
-	if err := q.Find(&rows).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+	meta, err := paginate(c, q, analysisListSpec, &rows)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+	listResponse(c, rows, meta)
 }
 
 func AdminAnalysisCreate(c *gin.Context) {
@@ -583,10 +948,15 @@ func AdminAnalysisCreate(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
 		return
 	}
+	status, ok := batchStatus(c, req.BatchID)
+	if !ok {
+		return
+	}
 	row := models.Analysis{
 		BatchID:   req.BatchID,
 		Type:      models.AnalysisType(req.Type),
 		Content:   req.Content,
+		Status:    status,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -609,11 +979,25 @@ func AdminAnalysisUpdate(c *gin.Context) {
 		return
 	}
 
+	var row models.Analysis
+	if err := config.DB.Select("id, batch_id, status").First(&row, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
 	updates := map[string]interface{}{
 		"updated_at": time.Now(),
 	}
-	if req.BatchID != 0 {
+	if req.BatchID != 0 && req.BatchID != row.BatchID {
+		status, ok := batchStatus(c, req.BatchID)
+		if !ok {
+			return
+		}
 		updates["batch_id"] = req.BatchID
+		// 换到其他批次后沿用新批次的发布状态，单独驳回的分析保持驳回
+		if row.Status != models.StatusRejected {
+			updates["status"] = status
+		}
 	}
 	if req.Type != "" {
 		if req.Type != string(models.Analysis3Day) && req.Type != string(models.Analysis7Day) {
@@ -649,3 +1033,181 @@ func AdminAnalysisDelete(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
 }
+
+type TagUpsertRequest struct {
+	Slug     string  `json:"slug"`
+	Name     string  `json:"name"`
+	Keywords *string `json:"keywords"`
+	Sort     *int    `json:"sort"`
+}
+
+var tagSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+func AdminTagList(c *gin.Context) {
+	var rows []models.Tag
+	meta, err := paginate(c, config.DB.Model(&models.Tag{}), tagListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+func AdminTagCreate(c *gin.Context) {
+	var req TagUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" || !tagSlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	row := models.Tag{Slug: req.Slug, Name: req.Name}
+	if req.Keywords != nil {
+		row.Keywords = *req.Keywords
+	}
+	if req.Sort != nil {
+		row.Sort = *req.Sort
+	}
+	if err := config.DB.Create(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": row})
+}
+
+func AdminTagUpdate(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var req TagUpsertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Slug != "" && !tagSlugPattern.MatchString(req.Slug)) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.Slug != "" {
+		updates["slug"] = req.Slug
+	}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Keywords != nil {
+		updates["keywords"] = *req.Keywords
+	}
+	if req.Sort != nil {
+		updates["sort"] = *req.Sort
+	}
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := config.DB.Model(&models.Tag{}).Where("id = ?", uint(id)).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+func AdminTagDelete(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	tx := config.DB.Begin()
+	if err := tx.Where("tag_id = ?", uint(id)).Delete(&models.NewsItemTag{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+		return
+	}
+	if err := tx.Where("id = ?", uint(id)).Delete(&models.Tag{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+		return
+	}
+	tx.Commit()
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+type NewsTagsRequest struct {
+	TagIDs []uint `json:"tag_ids"`
+}
+
+// AdminNewsSetTags 人工修正新闻标签
+func AdminNewsSetTags(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var req NewsTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var row models.NewsItem
+	if err := config.DB.First(&row, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+		return
+	}
+	if err := services.SetNewsTags(config.DB, row.ID, req.TagIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+		return
+	}
+	config.DB.Preload("Tags").First(&row, row.ID)
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": row})
+}
+
+// AdminBatchClassify 重新为批次新闻自动打标签，人工修正过的新闻不受影响
+func AdminBatchClassify(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := services.TagBatchNews(config.DB, uint(id), nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+// AdminBatchTranslate 补齐批次新闻的译文，已有译文的不会重复翻译
+func AdminBatchTranslate(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := services.TranslateBatchNews(config.DB, uint(id), config.AppConfig.Translation.Languages, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+// AdminBatchReorder 一次性调整批次内新闻顺序：{"ids": [12, 9, 10]}，未列出的新闻排在后面，置顶新闻始终在最前
+func AdminBatchReorder(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || id == 0 || len(req.IDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := services.ReorderBatchNews(config.DB, uint(id), req.IDs); err != nil {
+		if errors.Is(err, services.ErrNewsNotInBatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+		return
+	}
+	var rows []models.NewsItem
+	if err := config.DB.Where("batch_id = ?", uint(id)).Order("sort_rank asc, id asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows, "total": len(rows)})
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"bre_new_backend/config"
+	"bre_new_backend/models"
 	"bre_new_backend/services"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +18,9 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
 			token = strings.TrimSpace(authHeader[7:])
 		}
+		if token == "" {
+			token = strings.TrimSpace(c.GetHeader("X-API-Key"))
+		}
 		if token == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 401,
@@ -25,6 +30,20 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// API 密钥以固定前缀区分，以所属管理员身份访问，权限受密钥范围限制
+		if services.IsAPIKey(token) {
+			key, user, err := services.AuthenticateAPIKey(config.DB, token, c.ClientIP(), time.Now())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+				c.Abort()
+				return
+			}
+			c.Set("adminUser", user)
+			c.Set("apiKey", key)
+			c.Next()
+			return
+		}
+
 		user, session, err := services.GetAdminSessionUser(config.DB, token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -41,3 +60,77 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// currentAdmin 返回 AdminAuthMiddleware 写入的当前管理员
+func currentAdmin(c *gin.Context) *models.AdminUser {
+	v, ok := c.Get("adminUser")
+	if !ok {
+		return nil
+	}
+	user, _ := v.(*models.AdminUser)
+	return user
+}
+
+// currentAPIKey 通过 API 密钥认证时返回密钥，会话登录时为 nil
+func currentAPIKey(c *gin.Context) *models.APIKey {
+	v, ok := c.Get("apiKey")
+	if !ok {
+		return nil
+	}
+	key, _ := v.(*models.APIKey)
+	return key
+}
+
+// RequireSession 只允许会话登录访问，用于账号自身的安全设置，API 密钥不可调用
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentSession(c) == nil {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "forbidden"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission 校验当前管理员的角色拥有 perm，需放在 AdminAuthMiddleware 之后
+func RequirePermission(perm services.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentAdmin(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+			c.Abort()
+			return
+		}
+		allowed := services.HasPermission(user.Role, perm)
+		if key := currentAPIKey(c); key != nil {
+			allowed = services.APIKeyAllows(key, user.Role, perm)
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": "forbidden"})
+			c.Abort()
+			return
+		}
+		// 以下账号策略对 API 密钥同样生效：所属管理员满足要求前，其密钥也无法使用
+		// 策略要求两步验证时，未绑定的账号只能访问 /admin/me 下的绑定接口
+		if !user.TOTPEnabled {
+			required, err := services.TwoFactorRequired(config.DB)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+				c.Abort()
+				return
+			}
+			if required {
+				c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": services.ErrTwoFactorRequired.Error()})
+				c.Abort()
+				return
+			}
+		}
+		// 临时密码或密码已过期时，只能访问 /admin/me 下的接口修改密码
+		if services.CurrentPasswordPolicy().PasswordChangeRequired(user, time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"code": 403, "msg": services.ErrPasswordChangeRequired.Error()})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
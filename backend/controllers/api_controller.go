@@ -3,7 +3,12 @@ package controllers
 import (
 	"bre_new_backend/config"
 	"bre_new_backend/models"
+	"bre_new_backend/services"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -12,7 +17,7 @@ import (
 func GetLatestNews(c *gin.Context) {
 	// Find the latest batch
 	var lastBatch models.BatchLog
-	result := config.DB.Order("created_at desc").First(&lastBatch)
+	result := config.DB.Where("status = ?", models.StatusPublished).Order("created_at desc").First(&lastBatch)
 	if result.Error != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"code": 0,
@@ -23,19 +28,37 @@ func GetLatestNews(c *gin.Context) {
 	}
 
 	var news []models.NewsItem
-	config.DB.Where("batch_id = ?", lastBatch.ID).Find(&news)
+	q := config.DB.Model(&models.NewsItem{}).Where("batch_id = ? AND status = ?", lastBatch.ID, models.StatusPublished).Preload("Tags")
+	if tag := c.Query("tag"); tag != "" {
+		q = withTag(q, tag)
+	}
+	if featured, _ := strconv.ParseBool(c.Query("featured")); featured {
+		q = q.Where("featured = ?", true)
+	}
+	meta, err := paginateIfRequested(c, q, batchNewsListSpec, &news)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	if !localizeNews(c, news) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code": 200,
-		"msg":  "success",
-		"rows": news,
-		"batch": lastBatch,
+		"code":       200,
+		"msg":        "success",
+		"rows":       news,
+		"batch":      lastBatch,
+		"total":      meta.Total,
+		"page":       meta.Page,
+		"pageSize":   meta.PageSize,
+		"nextCursor": meta.NextCursor,
 	})
 }
 
 func GetLatestAnalysis(c *gin.Context) {
 	days := c.Query("days") // 3 or 7
-	
+
 	var analysisType models.AnalysisType
 	if days == "7" {
 		analysisType = models.Analysis7Day
@@ -44,8 +67,8 @@ func GetLatestAnalysis(c *gin.Context) {
 	}
 
 	var analysis models.Analysis
-	result := config.DB.Where("type = ?", analysisType).Order("created_at desc").First(&analysis)
-	
+	result := publishedOnly(config.DB).Where("type = ?", analysisType).Order("created_at desc").First(&analysis)
+
 	if result.Error != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"code": 0,
@@ -64,22 +87,66 @@ func GetLatestAnalysis(c *gin.Context) {
 
 func GetSiteCategories(c *gin.Context) {
 	var categories []models.SiteCategory
-	db := config.DB
-	err := db.Order("sort asc, id asc").Preload("Sites", func(tx *gorm.DB) *gorm.DB {
+	q := config.DB.Model(&models.SiteCategory{}).Preload("Sites", func(tx *gorm.DB) *gorm.DB {
 		return tx.Order("sort asc, id asc")
-	}).Find(&categories).Error
+	})
+	meta, err := paginateIfRequested(c, q, siteListSpec, &categories)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "query failed",
-			"rows": []interface{}{},
-		})
+		listError(c, err)
 		return
 	}
 
+	listResponse(c, categories, meta)
+}
+
+// GetTags 标签列表，供前端筛选
+func GetTags(c *gin.Context) {
+	var rows []models.Tag
+	if err := config.DB.Order("sort asc, id asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows, "total": len(rows)})
+}
+
+// SearchContent 全文检索新闻或分析：/api/search?q=降准&kind=news&batchType=morning&source=&dateStart=&dateEnd=&page=1&pageSize=20
+func SearchContent(c *gin.Context) {
+	q := services.SearchQuery{
+		Q:         strings.TrimSpace(c.Query("q")),
+		Kind:      c.DefaultQuery("kind", services.SearchKindNews),
+		BatchType: c.Query("batchType"),
+		Source:    c.Query("source"),
+	}
+	if q.Q == "" || utf8.RuneCountInString(q.Q) > 100 || (q.Kind != services.SearchKindNews && q.Kind != services.SearchKindAnalysis) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request", "rows": []interface{}{}})
+		return
+	}
+	if t, err := parseTimeFlexible(c.Query("dateStart")); err == nil {
+		q.DateStart = t
+	}
+	if t, err := parseTimeFlexible(c.Query("dateEnd")); err == nil && t != nil {
+		if len(c.Query("dateEnd")) == len("2006-01-02") {
+			// 只给日期时包含当天
+			end := t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			t = &end
+		}
+		q.DateEnd = t
+	}
+	q.Page, q.PageSize = parsePage(c, defaultPageSize)
+	q.PageSize = min(q.PageSize, services.MaxSearchPageSize)
+
+	result, err := services.Search(config.DB, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"code": 200,
-		"msg":  "success",
-		"rows": categories,
+		"code":     200,
+		"msg":      "success",
+		"rows":     result.Rows,
+		"total":    result.Total,
+		"page":     q.Page,
+		"pageSize": q.PageSize,
+		"facets":   result.Facets,
 	})
 }
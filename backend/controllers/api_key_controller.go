@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyCreateRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`          // 如 ["news:write", "run:trigger"]，见 services.APIKeyScopes
+	UserID        uint     `json:"user_id"`         // 密钥所属管理员，默认为当前管理员
+	ExpiresInDays int      `json:"expires_in_days"` // 0 表示永不过期
+}
+
+// AdminAPIKeyList API 密钥列表：/admin/api-keys?userId=&active=1
+func AdminAPIKeyList(c *gin.Context) {
+	q := config.DB.Model(&models.APIKey{})
+	if v := c.Query("userId"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			listError(c, errBadListQuery)
+			return
+		}
+		q = q.Where("user_id = ?", n)
+	}
+	if c.Query("active") == "1" {
+		q = q.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now())
+	}
+
+	var rows []models.APIKey
+	meta, err := paginate(c, q, apiKeyListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// AdminAPIKeyCreate 创建 API 密钥：{"name": "deploy", "scopes": ["run:trigger"], "expires_in_days": 90}，密钥明文只在响应中返回一次
+func AdminAPIKeyCreate(c *gin.Context) {
+	var req APIKeyCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	creator := currentAdmin(c)
+	if creator == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	owner := creator
+	if req.UserID != 0 && req.UserID != owner.ID {
+		var user models.AdminUser
+		if err := config.DB.First(&user, req.UserID).Error; err != nil {
+			notFoundOrError(c, err)
+			return
+		}
+		owner = &user
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	key, plain, err := services.CreateAPIKey(config.DB, owner, creator.ID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownScope) || errors.Is(err, services.ErrScopeNotOwned) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "create failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{
+		"id":         key.ID,
+		"key":        plain,
+		"key_prefix": key.KeyPrefix,
+		"name":       key.Name,
+		"user_id":    key.UserID,
+		"created_by": key.CreatedBy,
+		"scopes":     key.Scopes,
+		"expires_at": key.ExpiresAt,
+	}})
+}
+
+// AdminAPIKeyRevoke 吊销 API 密钥，立即失效
+func AdminAPIKeyRevoke(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	key, err := services.RevokeAPIKey(config.DB, uint(id), time.Now())
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": key})
+}
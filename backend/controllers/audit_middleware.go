@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditWriter 记录响应体，用于从新增接口的响应中取得新记录 ID
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// createdID 解析 {"data": {"id": 1}} 形式的响应
+func createdID(body []byte) uint {
+	var resp struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return 0
+	}
+	return resp.Data.ID
+}
+
+// AuditMiddleware 记录管理端写操作的操作人、目标和前后差异，需放在 AdminAuthMiddleware 之后
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		targetType, action := services.AuditAction(method, c.FullPath())
+		targetKey := services.AuditTargetKey(c.FullPath(), c.Param)
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		targetID := uint(id)
+		var before map[string]interface{}
+		if targetType != "" && (targetID > 0 || targetKey != "") {
+			snapshot, err := services.LoadAuditSnapshot(config.DB, targetType, targetID, targetKey)
+			if err != nil {
+				fmt.Printf("读取审计快照失败: %v\n", err)
+			}
+			before = snapshot
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		lookupCreated := targetID == 0 && targetKey == ""
+		if lookupCreated {
+			c.Writer = writer
+		}
+		c.Next()
+
+		if lookupCreated {
+			targetID = createdID(writer.body.Bytes())
+		}
+		var after map[string]interface{}
+		if targetType != "" && (targetID > 0 || targetKey != "") {
+			snapshot, err := services.LoadAuditSnapshot(config.DB, targetType, targetID, targetKey)
+			if err != nil {
+				fmt.Printf("读取审计快照失败: %v\n", err)
+			}
+			after = snapshot
+		}
+
+		entry := models.AuditLog{
+			Action:     action,
+			Method:     method,
+			Path:       c.Request.URL.Path,
+			TargetType: targetType,
+			TargetID:   targetID,
+			TargetKey:  targetKey,
+			Status:     c.Writer.Status(),
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+		}
+		if user := currentAdmin(c); user != nil {
+			entry.UserID = user.ID
+			entry.Username = user.Username
+		}
+		if key := currentAPIKey(c); key != nil {
+			entry.APIKeyID = key.ID
+			entry.KeyCreator = key.CreatedBy
+		}
+		if err := services.RecordAudit(config.DB, entry, before, after); err != nil {
+			fmt.Printf("保存审计日志失败: %v\n", err)
+		}
+	}
+}
+
+// AdminAuditLogList 审计日志：/admin/audit-logs?userId=&apiKeyId=&keyCreator=&action=batch.delete&targetType=batch&targetId=12&targetKey=&createdAtStart=&createdAtEnd=
+func AdminAuditLogList(c *gin.Context) {
+	q := config.DB.Model(&models.AuditLog{})
+	for _, p := range []struct{ param, column string }{{"userId", "user_id"}, {"targetId", "target_id"}, {"apiKeyId", "api_key_id"}, {"keyCreator", "key_creator"}} {
+		if v := c.Query(p.param); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				listError(c, errBadListQuery)
+				return
+			}
+			q = q.Where(p.column+" = ?", n)
+		}
+	}
+	for _, p := range []struct{ param, column string }{{"action", "action"}, {"targetType", "target_type"}, {"targetKey", "target_key"}, {"username", "username"}} {
+		if v := c.Query(p.param); v != "" {
+			q = q.Where(p.column+" = ?", v)
+		}
+	}
+	if createdAtStart, err := parseTimeFlexible(c.Query("createdAtStart")); err == nil && createdAtStart != nil {
+		q = q.Where("created_at >= ?", *createdAtStart)
+	}
+	if createdAtEnd, err := parseTimeFlexible(c.Query("createdAtEnd")); err == nil && createdAtEnd != nil {
+		q = q.Where("created_at <= ?", *createdAtEnd)
+	}
+
+	var rows []models.AuditLog
+	meta, err := paginate(c, q, auditLogListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
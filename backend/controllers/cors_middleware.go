@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"bre_new_backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminPathPrefix 使用管理端跨域策略的路径前缀
+const adminPathPrefix = "/api/admin"
+
+// CORSMiddleware 按路径选择公开接口或管理端接口的跨域策略。
+// 需挂在引擎上而不是路由组上，否则未注册 OPTIONS 路由的预检请求不会经过中间件。
+// 来源不被允许时普通请求照常处理但不返回跨域头，由浏览器拦截响应；预检请求直接返回 403。
+func CORSMiddleware(public, admin services.CORSPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := public
+		if p := c.Request.URL.Path; p == adminPathPrefix || strings.HasPrefix(p, adminPathPrefix+"/") {
+			policy = admin
+		}
+
+		header := c.Writer.Header()
+		if !policy.AllowAnyOrigin {
+			header.Add("Vary", "Origin")
+		}
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if !policy.AllowOrigin(origin) {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 403, "msg": "origin not allowed"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if policy.AllowAnyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if policy.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			c.Next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		if !policy.AllowMethod(c.GetHeader("Access-Control-Request-Method")) || !policy.AllowHeaders(c.GetHeader("Access-Control-Request-Headers")) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 403, "msg": "cors request not allowed"})
+			return
+		}
+		header.Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEntities 实体列表：/api/entities?type=company&q=茅台
+func GetEntities(c *gin.Context) {
+	entityType := c.Query("type")
+	if entityType != "" && !services.IsEntityType(entityType) {
+		listError(c, errBadListQuery)
+		return
+	}
+	q := config.DB.Model(&models.Entity{})
+	if entityType != "" {
+		q = q.Where("type = ?", entityType)
+	}
+	if keyword := strings.TrimSpace(c.Query("q")); keyword != "" {
+		q = q.Where("name like ?", "%"+keyword+"%")
+	}
+	var rows []models.Entity
+	meta, err := paginate(c, q, entityListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// GetTrendingEntities 最近 N 天被最多新闻提及的实体：/api/entities/trending?days=7&type=company&limit=20
+func GetTrendingEntities(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	entityType := c.Query("type")
+	if days <= 0 || days > 90 || limit <= 0 || limit > 100 || (entityType != "" && !services.IsEntityType(entityType)) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request", "rows": []interface{}{}})
+		return
+	}
+	rows, err := services.TrendingEntities(config.DB, time.Now().AddDate(0, 0, -days), entityType, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows, "total": len(rows), "days": days})
+}
+
+func entityParam(c *gin.Context) (*models.Entity, bool) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return nil, false
+	}
+	var entity models.Entity
+	if err := config.DB.First(&entity, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return nil, false
+	}
+	return &entity, true
+}
+
+// GetEntity 实体详情
+func GetEntity(c *gin.Context) {
+	entity, ok := entityParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": entity})
+}
+
+// GetEntityNews 提及该实体的新闻
+func GetEntityNews(c *gin.Context) {
+	entity, ok := entityParam(c)
+	if !ok {
+		return
+	}
+	mentions := config.DB.Model(&models.EntityMention{}).Select("news_item_id").Where("entity_id = ? AND news_item_id > 0", entity.ID)
+	q := publishedOnly(config.DB.Model(&models.NewsItem{})).Where("id IN (?)", mentions).Preload("Tags")
+	var rows []models.NewsItem
+	meta, err := paginate(c, q, newsListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	if !localizeNews(c, rows) {
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// GetEntityAnalysis 提及该实体的分析
+func GetEntityAnalysis(c *gin.Context) {
+	entity, ok := entityParam(c)
+	if !ok {
+		return
+	}
+	mentions := config.DB.Model(&models.EntityMention{}).Select("analysis_id").Where("entity_id = ? AND analysis_id > 0", entity.ID)
+	q := publishedOnly(config.DB.Model(&models.Analysis{})).Where("id IN (?)", mentions)
+	var rows []models.Analysis
+	meta, err := paginate(c, q, analysisListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
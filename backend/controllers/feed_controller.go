@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func feedSettings() (string, int) {
+	title := config.AppConfig.Feed.Title
+	if title == "" {
+		title = "财经热点"
+	}
+	limit := config.AppConfig.Feed.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return title, limit
+}
+
+// feedIdentity 返回订阅源的 id 和对外地址，地址由配置的 base_url（缺省为 site_url）拼接路由路径，不使用请求中的 Host 等头；
+// 查询参数只保留区分订阅源的 type 和 lang；未配置地址时 id 使用 tag: URI，地址为空
+func feedIdentity(c *gin.Context) (string, string) {
+	path := c.Request.URL.Path
+	q := url.Values{}
+	for _, key := range []string{"type", "lang"} {
+		if v := c.Query(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	base := config.AppConfig.Feed.BaseURL
+	if base == "" {
+		base = config.AppConfig.Feed.SiteURL
+	}
+	if base == "" {
+		return "tag:" + services.FeedTagAuthority + ":" + strings.TrimPrefix(path, "/"), ""
+	}
+	feedURL := strings.TrimRight(base, "/") + path
+	return feedURL, feedURL
+}
+
+func isFeedFormat(format string) bool {
+	return format == services.FeedRSS || format == services.FeedAtom || format == services.FeedJSON
+}
+
+func GetNewsFeed(c *gin.Context) {
+	format := c.Param("format")
+	batchType := c.Query("type")
+	if !isFeedFormat(format) || (batchType != "" && services.BatchTypeLabel(models.BatchType(batchType)) == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+
+	title, limit := feedSettings()
+	q := config.DB.Model(&models.NewsItem{}).
+		Joins("JOIN batch_logs ON batch_logs.id = news_items.batch_id AND batch_logs.deleted_at IS NULL AND batch_logs.status = ?", models.StatusPublished).
+		Where("news_items.status = ?", models.StatusPublished).
+		Order("news_items.created_at desc, news_items.id desc").
+		Limit(limit)
+	if batchType != "" {
+		q = q.Where("batch_logs.type = ?", batchType)
+		title += " - " + services.BatchTypeLabel(models.BatchType(batchType))
+	}
+
+	var rows []models.NewsItem
+	if err := q.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	if !localizeNews(c, rows) {
+		return
+	}
+
+	id, feedURL := feedIdentity(c)
+	feed := services.Feed{
+		ID:          id,
+		Title:       title,
+		Description: "每日早、中、晚三批次财经热点新闻",
+		Language:    c.Query("lang"),
+		HomeURL:     config.AppConfig.Feed.SiteURL,
+		FeedURL:     feedURL,
+	}
+	for _, row := range rows {
+		published := row.CreatedAt
+		if row.PublishedAt != nil {
+			published = *row.PublishedAt
+		}
+		feed.Items = append(feed.Items, services.FeedItem{
+			GUID:      services.FeedGUID("news", row.ID),
+			Title:     row.Title,
+			Link:      row.Url,
+			Content:   row.Content,
+			Category:  row.Source,
+			Published: published,
+			Updated:   row.UpdatedAt,
+		})
+	}
+	writeFeed(c, &feed, format)
+}
+
+func GetAnalysisFeed(c *gin.Context) {
+	format := c.Param("format")
+	analysisType := c.Query("type")
+	if !isFeedFormat(format) || (analysisType != "" && analysisType != string(models.Analysis3Day) && analysisType != string(models.Analysis7Day)) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+
+	title, limit := feedSettings()
+	q := publishedOnly(config.DB.Model(&models.Analysis{})).Order("created_at desc, id desc").Limit(limit)
+	if analysisType != "" {
+		q = q.Where("type = ?", analysisType)
+	}
+	var rows []models.Analysis
+	if err := q.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+
+	id, feedURL := feedIdentity(c)
+	feed := services.Feed{
+		ID:          id,
+		Title:       title + " - 财经分析",
+		Description: "基于近 3 日 / 7 日新闻的 AI 财经分析",
+		HomeURL:     config.AppConfig.Feed.SiteURL,
+		FeedURL:     feedURL,
+	}
+	for _, row := range rows {
+		feed.Items = append(feed.Items, services.FeedItem{
+			GUID:      services.FeedGUID("analysis", row.ID),
+			Title:     services.AnalysisTitle(row),
+			Content:   row.Content,
+			Category:  string(row.Type),
+			Published: row.CreatedAt,
+			Updated:   row.UpdatedAt,
+		})
+	}
+	writeFeed(c, &feed, format)
+}
+
+// writeFeed 输出订阅源，支持 If-None-Match / If-Modified-Since 条件请求
+func writeFeed(c *gin.Context, feed *services.Feed, format string) {
+	etag := feed.ETag(format)
+	lastModified := feed.LastModified().UTC().Truncate(time.Second)
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	body, contentType, err := feed.Render(format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "render failed"})
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// etagMatches 按弱比较规则匹配 If-None-Match 中的任意一个 ETag
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
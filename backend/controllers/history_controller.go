@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const dateLayout = "2006-01-02"
+
+// publishedOnly 只保留已发布、且所属批次已发布未删除的记录，手工录入的 batch_id = 0 也保留
+func publishedOnly(db *gorm.DB) *gorm.DB {
+	hidden := config.DB.Model(&models.BatchLog{}).Unscoped().Select("id").Where("deleted_at IS NOT NULL OR status <> ?", models.StatusPublished)
+	return db.Where("status = ? AND batch_id NOT IN (?)", models.StatusPublished, hidden)
+}
+
+// localizeNews 按 ?lang=zh|en 替换新闻标题和摘要，参数非法或查询失败时写入响应并返回 false
+func localizeNews(c *gin.Context, rows []models.NewsItem) bool {
+	lang := c.Query("lang")
+	if lang == "" {
+		return true
+	}
+	if !services.IsSupportedLanguage(lang) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return false
+	}
+	if err := services.LocalizeNews(config.DB, rows, lang); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return false
+	}
+	return true
+}
+
+func notFoundOrError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": "not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+}
+
+// GetBatches 历史批次列表：/api/batches?type=morning&dateStart=2025-01-01&dateEnd=2025-01-31
+func GetBatches(c *gin.Context) {
+	batchType := c.Query("type")
+	if batchType != "" && batchType != string(models.BatchMorning) && batchType != string(models.BatchNoon) && batchType != string(models.BatchEvening) {
+		listError(c, errBadListQuery)
+		return
+	}
+	q := config.DB.Model(&models.BatchLog{}).Where("status = ?", models.StatusPublished)
+	if batchType != "" {
+		q = q.Where("type = ?", batchType)
+	}
+	for _, p := range []struct{ param, cond string }{{"dateStart", "date >= ?"}, {"dateEnd", "date <= ?"}} {
+		value := c.Query(p.param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, value); err != nil {
+			listError(c, errBadListQuery)
+			return
+		}
+		q = q.Where(p.cond, value)
+	}
+
+	var rows []models.BatchLog
+	meta, err := paginate(c, q, batchListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// GetBatchNews 指定批次的新闻列表
+func GetBatchNews(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var batch models.BatchLog
+	if err := config.DB.Where("status = ?", models.StatusPublished).First(&batch, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+
+	var rows []models.NewsItem
+	q := config.DB.Model(&models.NewsItem{}).Where("batch_id = ? AND status = ?", batch.ID, models.StatusPublished).Preload("Tags")
+	if tag := c.Query("tag"); tag != "" {
+		q = withTag(q, tag)
+	}
+	if featured, _ := strconv.ParseBool(c.Query("featured")); featured {
+		q = q.Where("featured = ?", true)
+	}
+	meta, err := paginate(c, q, batchNewsListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	if !localizeNews(c, rows) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"msg":        "success",
+		"rows":       rows,
+		"batch":      batch,
+		"total":      meta.Total,
+		"page":       meta.Page,
+		"pageSize":   meta.PageSize,
+		"nextCursor": meta.NextCursor,
+	})
+}
+
+// GetNewsDetail 单条新闻详情，附带所属批次
+func GetNewsDetail(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var item models.NewsItem
+	if err := publishedOnly(config.DB).Preload("Tags").First(&item, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	items := []models.NewsItem{item}
+	if !localizeNews(c, items) {
+		return
+	}
+	item = items[0]
+	var batch *models.BatchLog
+	if item.BatchID > 0 {
+		var b models.BatchLog
+		if err := config.DB.First(&b, item.BatchID).Error; err == nil {
+			batch = &b
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": item, "batch": batch})
+}
+
+// analysisTypeParam 解析 type=3_day|7_day 或 days=3|7，未指定时返回空
+func analysisTypeParam(c *gin.Context) (models.AnalysisType, error) {
+	switch t := c.Query("type"); t {
+	case string(models.Analysis3Day), string(models.Analysis7Day):
+		return models.AnalysisType(t), nil
+	case "":
+	default:
+		return "", errBadListQuery
+	}
+	switch c.Query("days") {
+	case "3":
+		return models.Analysis3Day, nil
+	case "7":
+		return models.Analysis7Day, nil
+	case "":
+		return "", nil
+	}
+	return "", errBadListQuery
+}
+
+// GetAnalysisList 历史分析列表：/api/analysis?date=2025-01-07&type=3_day
+// date 按生成日期（本地时区）筛选
+func GetAnalysisList(c *gin.Context) {
+	analysisType, err := analysisTypeParam(c)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	q := publishedOnly(config.DB.Model(&models.Analysis{}))
+	if analysisType != "" {
+		q = q.Where("type = ?", analysisType)
+	}
+	if date := c.Query("date"); date != "" {
+		day, err := time.ParseInLocation(dateLayout, date, time.Local)
+		if err != nil {
+			listError(c, errBadListQuery)
+			return
+		}
+		q = q.Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1))
+	}
+
+	var rows []models.Analysis
+	meta, err := paginate(c, q, analysisListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// GetAnalysisDetail 单篇分析详情
+func GetAnalysisDetail(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	var analysis models.Analysis
+	if err := publishedOnly(config.DB).First(&analysis, uint(id)).Error; err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": analysis})
+}
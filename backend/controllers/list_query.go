@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 200
+)
+
+var errBadListQuery = errors.New("bad list query")
+
+// ListSpec 描述列表接口允许的排序字段和默认排序
+type ListSpec struct {
+	Sorts           map[string]string // 参数名 -> 列名，如 "createdAt": "news_items.created_at"
+	DefaultSort     string
+	DefaultOrder    string // asc, desc
+	IDColumn        string // 排序的第二关键字，同时用于游标分页，默认 id
+	DefaultPageSize int
+}
+
+// ListMeta 分页信息，随 rows 一起返回
+type ListMeta struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"pageSize"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// listCursor 游标记录上一页最后一行的排序值和 ID（keyset 分页）
+type listCursor struct {
+	Value interface{} `json:"v,omitempty"`
+	Time  *int64      `json:"t,omitempty"` // 时间类型的排序值，UnixNano
+	ID    uint64      `json:"id"`
+}
+
+// parsePage 解析 page / pageSize 参数
+func parsePage(c *gin.Context, defaultSize int) (int, int) {
+	if defaultSize <= 0 {
+		defaultSize = defaultPageSize
+	}
+	page, _ := strconv.Atoi(c.Query("page"))
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+	if pageSize <= 0 {
+		pageSize = defaultSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// listSort 解析 sort / order 参数，返回排序列、方向和第二关键字列
+func listSort(c *gin.Context, spec ListSpec) (string, string, string, error) {
+	idColumn := spec.IDColumn
+	if idColumn == "" {
+		idColumn = "id"
+	}
+	sortKey := c.DefaultQuery("sort", spec.DefaultSort)
+	column, ok := spec.Sorts[sortKey]
+	if !ok {
+		return "", "", "", errBadListQuery
+	}
+	order := strings.ToLower(c.DefaultQuery("order", spec.DefaultOrder))
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		return "", "", "", errBadListQuery
+	}
+	return column, order, idColumn, nil
+}
+
+// paginationRequested 请求中是否带了 page、pageSize 或 cursor
+func paginationRequested(c *gin.Context) bool {
+	return c.Query("page") != "" || c.Query("pageSize") != "" || c.Query("cursor") != ""
+}
+
+// paginateIfRequested 带分页参数时同 paginate；否则按 sort/order 排序返回全部记录，兼容不分页的旧客户端
+func paginateIfRequested(c *gin.Context, q *gorm.DB, spec ListSpec, dest interface{}) (*ListMeta, error) {
+	if paginationRequested(c) {
+		return paginate(c, q, spec, dest)
+	}
+	column, order, idColumn, err := listSort(c, spec)
+	if err != nil {
+		return nil, err
+	}
+	tx := q.Order(column + " " + order)
+	if column != idColumn {
+		tx = tx.Order(idColumn + " " + order)
+	}
+	if err := tx.Find(dest).Error; err != nil {
+		return nil, err
+	}
+	n := reflect.Indirect(reflect.ValueOf(dest)).Len()
+	return &ListMeta{Total: int64(n), Page: 1, PageSize: n}, nil
+}
+
+// paginate 统计总数并按 sort/order 排序，再按 page 或 cursor 取一页写入 dest
+// 支持的参数：page、pageSize、sort、order、cursor（传 cursor 时忽略 page）
+func paginate(c *gin.Context, q *gorm.DB, spec ListSpec, dest interface{}) (*ListMeta, error) {
+	column, order, idColumn, err := listSort(c, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	page, pageSize := parsePage(c, spec.DefaultPageSize)
+	meta := &ListMeta{Page: page, PageSize: pageSize}
+
+	base := q.Session(&gorm.Session{})
+	if err := base.Count(&meta.Total).Error; err != nil {
+		return nil, err
+	}
+
+	tx := base.Order(column + " " + order)
+	if column != idColumn {
+		tx = tx.Order(idColumn + " " + order)
+	}
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := decodeListCursor(cursorStr)
+		if err != nil {
+			return nil, errBadListQuery
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		var value interface{} = cursor.Value
+		if cursor.Time != nil {
+			value = time.Unix(0, *cursor.Time)
+		}
+		if column == idColumn {
+			tx = tx.Where(idColumn+" "+cmp+" ?", cursor.ID)
+		} else {
+			tx = tx.Where("("+column+" "+cmp+" ? OR ("+column+" = ? AND "+idColumn+" "+cmp+" ?))", value, value, cursor.ID)
+		}
+	} else {
+		tx = tx.Offset((page - 1) * pageSize)
+	}
+
+	if err := tx.Limit(pageSize).Find(dest).Error; err != nil {
+		return nil, err
+	}
+	meta.NextCursor = nextListCursor(q, dest, column, idColumn, pageSize)
+	return meta, nil
+}
+
+// nextListCursor 取本页最后一行生成下一页游标，不足一页时返回空
+func nextListCursor(db *gorm.DB, dest interface{}, column, idColumn string, pageSize int) string {
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 || rv.Len() < pageSize {
+		return ""
+	}
+	last := rv.Index(rv.Len() - 1)
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(last.Addr().Interface()); err != nil {
+		return ""
+	}
+	fieldValue := func(col string) (interface{}, bool) {
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			col = col[i+1:]
+		}
+		field := stmt.Schema.LookUpField(col)
+		if field == nil {
+			return nil, false
+		}
+		v, _ := field.ValueOf(db.Statement.Context, last)
+		return v, true
+	}
+
+	idValue, ok := fieldValue(idColumn)
+	if !ok {
+		return ""
+	}
+	cursor := listCursor{}
+	switch id := idValue.(type) {
+	case uint:
+		cursor.ID = uint64(id)
+	case uint64:
+		cursor.ID = id
+	case int:
+		cursor.ID = uint64(id)
+	default:
+		return ""
+	}
+	if column != idColumn {
+		v, _ := fieldValue(column)
+		switch t := v.(type) {
+		case time.Time:
+			nano := t.UnixNano()
+			cursor.Time = &nano
+		case *time.Time:
+			if t != nil {
+				nano := t.UnixNano()
+				cursor.Time = &nano
+			}
+		default:
+			cursor.Value = v
+		}
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(value string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor listCursor
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil {
+		return nil, err
+	}
+	if n, ok := cursor.Value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			cursor.Value = i
+		} else if f, err := n.Float64(); err == nil {
+			cursor.Value = f
+		}
+	}
+	return &cursor, nil
+}
+
+// listResponse 输出统一的列表结构：rows + 分页信息
+func listResponse(c *gin.Context, rows interface{}, meta *ListMeta) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":       200,
+		"msg":        "success",
+		"rows":       rows,
+		"total":      meta.Total,
+		"page":       meta.Page,
+		"pageSize":   meta.PageSize,
+		"nextCursor": meta.NextCursor,
+	})
+}
+
+// listError 输出列表查询失败的响应，非法参数返回 400
+func listError(c *gin.Context, err error) {
+	if errors.Is(err, errBadListQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request", "rows": []interface{}{}, "total": 0})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}, "total": 0})
+}
+
+// withTag 按标签 slug 筛选新闻
+func withTag(q *gorm.DB, slug string) *gorm.DB {
+	return q.Where("news_items.id IN (?)", q.Session(&gorm.Session{NewDB: true}).
+		Table("news_item_tags").
+		Select("news_item_tags.news_item_id").
+		Joins("JOIN tags ON tags.id = news_item_tags.tag_id").
+		Where("tags.slug = ?", slug))
+}
+
+// 各列表接口允许的排序字段
+var (
+	adminUserListSpec = ListSpec{
+		Sorts:        map[string]string{"id": "id", "username": "username", "createdAt": "created_at"},
+		DefaultSort:  "id",
+		DefaultOrder: "asc",
+	}
+	siteListSpec = ListSpec{
+		Sorts:           map[string]string{"sort": "sort", "id": "id", "name": "name", "createdAt": "created_at"},
+		DefaultSort:     "sort",
+		DefaultOrder:    "asc",
+		DefaultPageSize: 100,
+	}
+	newsSourceListSpec = ListSpec{
+		Sorts:        map[string]string{"id": "id", "name": "name", "createdAt": "created_at"},
+		DefaultSort:  "id",
+		DefaultOrder: "asc",
+	}
+	batchListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id", "date": "date", "type": "type"},
+		DefaultSort:  "createdAt",
+		DefaultOrder: "desc",
+	}
+	// 批次内新闻默认按编辑排序，置顶新闻的排序值总是最小
+	batchNewsListSpec = ListSpec{
+		Sorts:           map[string]string{"rank": "sort_rank", "id": "id", "title": "title", "wordCount": "word_count", "createdAt": "created_at"},
+		DefaultSort:     "rank",
+		DefaultOrder:    "asc",
+		DefaultPageSize: 50,
+	}
+	newsListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id", "title": "title", "source": "source", "wordCount": "word_count", "batchId": "batch_id"},
+		DefaultSort:  "createdAt",
+		DefaultOrder: "desc",
+	}
+	tagListSpec = ListSpec{
+		Sorts:           map[string]string{"sort": "sort", "id": "id", "slug": "slug"},
+		DefaultSort:     "sort",
+		DefaultOrder:    "asc",
+		DefaultPageSize: 100,
+	}
+	entityListSpec = ListSpec{
+		Sorts:        map[string]string{"id": "id", "name": "name", "createdAt": "created_at"},
+		DefaultSort:  "id",
+		DefaultOrder: "desc",
+	}
+	auditLogListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id"},
+		DefaultSort:  "id",
+		DefaultOrder: "desc",
+	}
+	apiKeyListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id"}, // last_used_at 可为 NULL，无法用于游标分页
+		DefaultSort:  "id",
+		DefaultOrder: "desc",
+	}
+	loginAttemptListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id"},
+		DefaultSort:  "id",
+		DefaultOrder: "desc",
+	}
+	analysisListSpec = ListSpec{
+		Sorts:        map[string]string{"createdAt": "created_at", "id": "id", "type": "type", "batchId": "batch_id"},
+		DefaultSort:  "createdAt",
+		DefaultOrder: "desc",
+	}
+)
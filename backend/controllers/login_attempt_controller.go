@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminLoginAttemptList 登录记录：/admin/login-attempts?username=&ip=&success=0&reason=bad_password&createdAtStart=&createdAtEnd=
+func AdminLoginAttemptList(c *gin.Context) {
+	q := config.DB.Model(&models.LoginAttempt{})
+	for _, p := range []struct{ param, column string }{{"username", "username"}, {"ip", "ip"}, {"reason", "reason"}} {
+		if v := c.Query(p.param); v != "" {
+			q = q.Where(p.column+" = ?", v)
+		}
+	}
+	if v := c.Query("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			listError(c, errBadListQuery)
+			return
+		}
+		q = q.Where("success = ?", success)
+	}
+	if createdAtStart, err := parseTimeFlexible(c.Query("createdAtStart")); err == nil && createdAtStart != nil {
+		q = q.Where("created_at >= ?", *createdAtStart)
+	}
+	if createdAtEnd, err := parseTimeFlexible(c.Query("createdAtEnd")); err == nil && createdAtEnd != nil {
+		q = q.Where("created_at <= ?", *createdAtEnd)
+	}
+
+	var rows []models.LoginAttempt
+	meta, err := paginate(c, q, loginAttemptListSpec, &rows)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	listResponse(c, rows, meta)
+}
+
+// AdminLoginReport 登录失败报表：/admin/login-attempts/report?days=7&limit=10
+func AdminLoginReport(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days <= 0 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	now := time.Now()
+	report, err := services.BuildLoginFailureReport(config.DB, now.AddDate(0, 0, -days), now, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": report})
+}
+
+// AdminLoginUnlock 手动解除锁定：DELETE /admin/login-locks/user:alice
+func AdminLoginUnlock(c *gin.Context) {
+	key := c.Param("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := services.UnlockLogin(config.DB, key); err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
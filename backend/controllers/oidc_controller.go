@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie 发起登录的浏览器中保存 state 哈希的 Cookie，回调时校验
+const (
+	oidcStateCookie    = "bre_oidc_state"
+	oidcStateCookieTTL = 10 * time.Minute
+)
+
+// setOIDCStateCookie 写入或清除 state Cookie；SameSite=Lax 使身份提供方跳回的顶层 GET 请求仍会带上
+func setOIDCStateCookie(c *gin.Context, value string, maxAge int) {
+	secure := strings.HasPrefix(config.AppConfig.OIDC.RedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, value, maxAge, "/api/admin/oidc", "", secure, true)
+}
+
+// oidcRedirect 跳回管理后台，结果放在 URL 片段中，不会出现在服务器日志和 Referer 里
+func oidcRedirect(c *gin.Context, values url.Values) {
+	target := config.AppConfig.OIDC.FrontendURL
+	if target == "" {
+		target = "/"
+	}
+	c.Redirect(http.StatusFound, target+"#"+values.Encode())
+}
+
+// AdminOIDCConfig 登录页是否显示单点登录按钮
+func AdminOIDCConfig(c *gin.Context) {
+	_, err := services.CurrentOIDCClient()
+	label := config.AppConfig.OIDC.ButtonLabel
+	if label == "" {
+		label = "单点登录"
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"enabled": err == nil, "label": label}})
+}
+
+// AdminOIDCLogin 跳转到身份提供方登录
+func AdminOIDCLogin(c *gin.Context) {
+	client, err := services.CurrentOIDCClient()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": err.Error()})
+		return
+	}
+	target, state, err := services.StartOIDCLogin(c.Request.Context(), config.DB, client, 0, time.Now())
+	if err != nil {
+		fmt.Printf("单点登录跳转失败: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "sso provider unavailable"})
+		return
+	}
+	setOIDCStateCookie(c, services.OIDCStateCookieValue(state), int(oidcStateCookieTTL.Seconds()))
+	c.Redirect(http.StatusFound, target)
+}
+
+// AdminOIDCLink 已登录的管理员关联单点登录账号，返回身份提供方地址，由前端整页跳转
+func AdminOIDCLink(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	client, err := services.CurrentOIDCClient()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": err.Error()})
+		return
+	}
+	target, _, err := services.StartOIDCLogin(c.Request.Context(), config.DB, client, user.ID, time.Now())
+	if err != nil {
+		fmt.Printf("单点登录关联跳转失败: %v\n", err)
+		c.JSON(http.StatusBadGateway, gin.H{"code": 502, "msg": "sso provider unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"url": target}})
+}
+
+// AdminOIDCCallback 身份提供方回调：校验通过后创建会话，启用两步验证的账号返回挑战，由前端继续第二步
+func AdminOIDCCallback(c *gin.Context) {
+	client, err := services.CurrentOIDCClient()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "msg": err.Error()})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		oidcRedirect(c, url.Values{"sso_error": {e}})
+		return
+	}
+
+	stateCookie, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+
+	now := time.Now()
+	user, linkToken, err := services.FinishOIDCLogin(c.Request.Context(), config.DB, client, c.Query("state"), stateCookie, c.Query("code"), c.ClientIP(), c.Request.UserAgent(), now)
+	if err != nil {
+		fmt.Printf("单点登录失败: %v\n", err)
+		msg := "sso login failed"
+		for _, known := range []error{services.ErrOIDCState, services.ErrOIDCNoRole, services.ErrOIDCNotProvisioned, services.ErrOIDCUsernameTaken, services.ErrOIDCEmailDomain,
+			services.ErrOIDCLastOwner} {
+			if errors.Is(err, known) {
+				msg = known.Error()
+			}
+		}
+		oidcRedirect(c, url.Values{"sso_error": {msg}})
+		return
+	}
+
+	if linkToken != "" {
+		oidcRedirect(c, url.Values{"sso_link": {linkToken}})
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := services.CreateLoginChallenge(config.DB, user.ID, now)
+		if err != nil {
+			oidcRedirect(c, url.Values{"sso_error": {"sso login failed"}})
+			return
+		}
+		oidcRedirect(c, url.Values{"mfa_challenge": {challenge.Token}})
+		return
+	}
+	_, token, err := services.CreateAdminSession(config.DB, user.ID, services.CurrentSessionPolicy().TTL, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		oidcRedirect(c, url.Values{"sso_error": {"create session failed"}})
+		return
+	}
+	values := url.Values{"token": {token}}
+	if required, _ := services.TwoFactorRequired(config.DB); required {
+		values.Set("mfa_setup_required", "1")
+	}
+	if services.CurrentPasswordPolicy().PasswordChangeRequired(user, now) {
+		values.Set("password_change_required", "1")
+	}
+	oidcRedirect(c, values)
+}
+
+// AdminOIDCLinkConfirm 确认关联：{"token": "..."}，token 来自单点登录回调跳回时的地址片段
+func AdminOIDCLinkConfirm(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	linked, err := services.ConfirmOIDCLink(config.DB, req.Token, user.ID, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCState) || errors.Is(err, services.ErrOIDCAlreadyLinked) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": linked})
+}
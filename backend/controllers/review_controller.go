@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReviewRequest struct {
+	Action string `json:"action"` // submit, approve, reject, withdraw
+	Note   string `json:"note"`
+}
+
+// reviewError 未知操作返回 400，状态不允许该操作返回 409
+func reviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownReviewAction):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+	default:
+		notFoundOrError(c, err)
+	}
+}
+
+func bindReview(c *gin.Context) (uint, *ReviewRequest, bool) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil || id == 0 || req.Action == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return 0, nil, false
+	}
+	return uint(id), &req, true
+}
+
+// batchStatus 手工录入的新闻和分析沿用所属批次的发布状态。
+// 批次不存在时返回 400，查询失败返回 500，均已写入响应
+func batchStatus(c *gin.Context, batchID uint) (models.PublishStatus, bool) {
+	var batch models.BatchLog
+	if err := config.DB.Select("id, status").First(&batch, batchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "batch not found"})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return "", false
+	}
+	if batch.Status == "" {
+		return models.StatusPublished, true
+	}
+	return batch.Status, true
+}
+
+// AdminBatchReview 审核批次：{"action": "approve"}，批次内未单独驳回的新闻和分析随之变更，首次发布时推送群聊和订阅邮件
+func AdminBatchReview(c *gin.Context) {
+	id, req, ok := bindReview(c)
+	if !ok {
+		return
+	}
+	var reviewerID uint
+	if user := currentAdmin(c); user != nil {
+		reviewerID = user.ID
+	}
+	batch, deliver, err := services.ReviewBatch(config.DB, id, req.Action, req.Note, reviewerID, time.Now())
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+	if deliver {
+		go services.DeliverBatch(config.DB, *batch, config.AppConfig.Publishers, true)
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": batch})
+}
+
+// AdminNewsReview 单独审核一条新闻，如驳回批次中的错误条目
+func AdminNewsReview(c *gin.Context) {
+	id, req, ok := bindReview(c)
+	if !ok {
+		return
+	}
+	status, err := services.ReviewNews(config.DB, id, req.Action)
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"id": id, "status": status}})
+}
+
+// AdminAnalysisReview 单独审核一篇分析
+func AdminAnalysisReview(c *gin.Context) {
+	id, req, ok := bindReview(c)
+	if !ok {
+		return
+	}
+	status, err := services.ReviewAnalysis(config.DB, id, req.Action)
+	if err != nil {
+		reviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"id": id, "status": status}})
+}
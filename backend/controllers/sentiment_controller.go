@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSentimentTimeSeries 每日新闻情绪：/api/sentiment/timeseries?days=30&tag=macro
+// 也可用 dateStart / dateEnd 指定日期范围；返回整体序列和按主题的序列
+func GetSentimentTimeSeries(c *gin.Context) {
+	end := time.Now()
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days <= 0 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	today, _ := time.ParseInLocation(dateLayout, end.Format(dateLayout), time.Local)
+	start := today.AddDate(0, 0, 1-days)
+
+	var err error
+	if v := c.Query("dateStart"); v != "" {
+		if start, err = time.ParseInLocation(dateLayout, v, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+			return
+		}
+	}
+	if v := c.Query("dateEnd"); v != "" {
+		dateEnd, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+			return
+		}
+		end = dateEnd.AddDate(0, 0, 1)
+	}
+	if !start.Before(end) || end.Sub(start) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+
+	tag := c.Query("tag")
+	overall, err := services.SentimentTimeSeries(config.DB, start, end, false, "", false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	topics, err := services.SentimentTimeSeries(config.DB, start, end, true, tag, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"start":   start.Format(dateLayout),
+			"end":     end.Add(-time.Second).Format(dateLayout),
+			"overall": overall,
+			"topics":  topics,
+		},
+	})
+}
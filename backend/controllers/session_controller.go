@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentSession 取得 AdminAuthMiddleware 写入的当前会话
+func currentSession(c *gin.Context) *models.AdminSession {
+	v, ok := c.Get("adminSession")
+	if !ok {
+		return nil
+	}
+	session, _ := v.(*models.AdminSession)
+	return session
+}
+
+// AdminSessionList 当前管理员已登录的设备
+func AdminSessionList(c *gin.Context) {
+	user, session := currentAdmin(c), currentSession(c)
+	if user == nil || session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	rows, err := services.ListAdminSessions(config.DB, services.CurrentSessionPolicy(), user.ID, session.TokenHash, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows})
+}
+
+// AdminSessionRevoke 注销自己的某个会话，注销当前会话等同于退出登录
+func AdminSessionRevoke(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if err := services.RevokeAdminSession(config.DB, user.ID, id); err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+// AdminSessionRevokeOthers 注销除当前会话外的所有会话
+func AdminSessionRevokeOthers(c *gin.Context) {
+	user, session := currentAdmin(c), currentSession(c)
+	if user == nil || session == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	revoked, err := services.RevokeOtherAdminSessions(config.DB, user.ID, session.TokenHash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"revoked": revoked}})
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/services"
+	"errors"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Subscribe(c *gin.Context) {
+	var req services.SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if _, err := services.Subscribe(config.DB, req, services.CurrentSubscriptionLimits(), c.ClientIP(), time.Now()); err != nil {
+		if errors.Is(err, services.ErrSubscribeRateLimited) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"code": 429, "msg": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidSubscription) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "subscribe failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "confirmation email sent"})
+}
+
+// SubscribeConfirm 和 Unsubscribe 由邮件中的链接打开，返回简单的 HTML 页面。
+// 邮件安全网关会预取链接，GET 只展示提交按钮，状态变更只在 POST 中进行
+func SubscribeConfirm(c *gin.Context) {
+	token := subscriptionToken(c)
+	if c.Request.Method != http.MethodPost {
+		subscriptionForm(c, token, "确认订阅")
+		return
+	}
+	if _, err := services.ConfirmSubscription(config.DB, token, time.Now()); err != nil {
+		subscriptionPage(c, http.StatusBadRequest, "链接无效或已过期")
+		return
+	}
+	subscriptionPage(c, http.StatusOK, "订阅成功，感谢关注！")
+}
+
+// Unsubscribe 的 POST 同时用于邮件客户端的一键退订（List-Unsubscribe-Post）
+func Unsubscribe(c *gin.Context) {
+	token := subscriptionToken(c)
+	if c.Request.Method != http.MethodPost {
+		subscriptionForm(c, token, "确认退订")
+		return
+	}
+	if err := services.Unsubscribe(config.DB, token); err != nil {
+		subscriptionPage(c, http.StatusBadRequest, "链接无效")
+		return
+	}
+	subscriptionPage(c, http.StatusOK, "已退订，您将不再收到邮件。")
+}
+
+// subscriptionToken 优先读取表单中的令牌，一键退订请求只在链接参数中携带令牌
+func subscriptionToken(c *gin.Context) string {
+	if token := c.PostForm("token"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
+
+func subscriptionForm(c *gin.Context, token, action string) {
+	if token == "" {
+		subscriptionPage(c, http.StatusBadRequest, "链接无效")
+		return
+	}
+	body := `<!DOCTYPE html><html><head><meta charset="utf-8"><title>新闻订阅</title></head>` +
+		`<body style="font-family:sans-serif;text-align:center;padding-top:80px">` +
+		`<form method="post"><input type="hidden" name="token" value="` + html.EscapeString(token) + `">` +
+		`<button type="submit">` + html.EscapeString(action) + `</button></form></body></html>`
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(body))
+}
+
+func subscriptionPage(c *gin.Context, status int, message string) {
+	body := `<!DOCTYPE html><html><head><meta charset="utf-8"><title>新闻订阅</title></head>` +
+		`<body style="font-family:sans-serif;text-align:center;padding-top:80px"><p>` +
+		html.EscapeString(message) + `</p></body></html>`
+	c.Data(status, "text/html; charset=utf-8", []byte(body))
+}
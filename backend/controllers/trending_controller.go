@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bre_new_backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrendingTopics 热点话题：/api/trending?batchId=12&kind=entity
+// 不指定批次时返回最近一个有快照的批次
+func GetTrendingTopics(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && kind != services.TopicEntity && kind != services.TopicKeyword {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request", "rows": []interface{}{}})
+		return
+	}
+
+	var batch models.BatchLog
+	if v := c.Query("batchId"); v != "" {
+		id, _ := strconv.ParseUint(v, 10, 64)
+		if id == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request", "rows": []interface{}{}})
+			return
+		}
+		if err := config.DB.Where("status = ?", models.StatusPublished).First(&batch, uint(id)).Error; err != nil {
+			notFoundOrError(c, err)
+			return
+		}
+	} else {
+		snapshots := config.DB.Model(&models.TrendingTopic{}).Select("batch_id")
+		if err := config.DB.Where("id IN (?) AND status = ?", snapshots, models.StatusPublished).Order("created_at desc").First(&batch).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{"code": 0, "msg": "No trending topics yet", "rows": []interface{}{}})
+			return
+		}
+	}
+
+	q := config.DB.Where("batch_id = ?", batch.ID)
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	var rows []models.TrendingTopic
+	if err := q.Order("`rank` asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed", "rows": []interface{}{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": rows, "total": len(rows), "batch": batch})
+}
+
+// AdminBatchTrending 重新计算批次的热点话题
+func AdminBatchTrending(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	topics, err := services.UpdateTrendingTopics(config.DB, uint(id))
+	if err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "rows": topics, "total": len(topics)})
+}
@@ -0,0 +1,21 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigureTrustedProxies 只采用可信代理转发的 X-Forwarded-For，proxies 为空时不信任任何代理。
+// gin 默认信任所有代理，客户端伪造该请求头即可绕过按 IP 的登录限流，并伪造登录记录、审计日志和会话中的 IP
+func ConfigureTrustedProxies(r *gin.Engine, proxies []string) error {
+	if len(proxies) == 0 {
+		return r.SetTrustedProxies(nil)
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		// 配置有误时退回不信任任何代理，避免沿用 gin 信任全部代理的默认值
+		_ = r.SetTrustedProxies(nil)
+		return fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"bre_new_backend/services"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func lockKeyFor(t *testing.T, proxies []string, remoteAddr, forwardedFor string) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := ConfigureTrustedProxies(r, proxies); err != nil {
+		t.Fatal(err)
+	}
+	var key string
+	r.GET("/", func(c *gin.Context) { key = services.IPLockKey(c.ClientIP()) })
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	return key
+}
+
+func TestSpoofedForwardedForKeepsLockKey(t *testing.T) {
+	want := lockKeyFor(t, nil, "203.0.113.7:51000", "")
+	for _, spoofed := range []string{"1.2.3.4", "10.0.0.1, 8.8.8.8"} {
+		if got := lockKeyFor(t, nil, "203.0.113.7:51000", spoofed); got != want {
+			t.Fatalf("X-Forwarded-For %q changed lock key to %s, want %s", spoofed, got, want)
+		}
+	}
+	// 不在可信列表中的代理转发的请求头同样忽略
+	if got := lockKeyFor(t, []string{"127.0.0.1"}, "203.0.113.7:51000", "1.2.3.4"); got != want {
+		t.Fatalf("untrusted peer changed lock key to %s", got)
+	}
+	if got := lockKeyFor(t, []string{"127.0.0.1"}, "127.0.0.1:40000", "198.51.100.9"); got != services.IPLockKey("198.51.100.9") {
+		t.Fatalf("trusted proxy should forward client ip, got %s", got)
+	}
+	if err := ConfigureTrustedProxies(gin.New(), []string{"not-an-ip"}); err == nil {
+		t.Fatal("invalid proxy should be reported")
+	}
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/services"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twoFactorError 验证码错误返回 400，状态不允许该操作返回 409
+func twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidOTP):
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+	case errors.Is(err, services.ErrTOTPAlreadyEnabled), errors.Is(err, services.ErrTOTPNotEnabled),
+		errors.Is(err, services.ErrTOTPSetupMissing), errors.Is(err, services.ErrTwoFactorRequired):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": err.Error()})
+	default:
+		notFoundOrError(c, err)
+	}
+}
+
+type TOTPCodeRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+// AdminTOTPStatus 当前管理员的两步验证状态
+func AdminTOTPStatus(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	remaining, err := services.RemainingRecoveryCodes(config.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	required, err := services.TwoFactorRequired(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{
+		"enabled":             user.TOTPEnabled,
+		"required":            required,
+		"recovery_codes_left": remaining,
+	}})
+}
+
+// AdminTOTPSetup 生成新密钥，返回 otpauth:// 链接用于扫码，需再调用 enable 确认
+func AdminTOTPSetup(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	secret, uri, err := services.StartTOTPSetup(config.DB, user.ID)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"secret": secret, "uri": uri}})
+}
+
+// AdminTOTPEnable 提交验证器 App 中的验证码启用两步验证：{"code": "123456"}，返回只展示一次的恢复码
+func AdminTOTPEnable(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	codes, err := services.EnableTOTP(config.DB, user.ID, req.Code, time.Now())
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"recovery_codes": codes}})
+}
+
+// AdminTOTPDisable 输入验证码（或恢复码）和密码关闭两步验证：{"code": "123456", "password": "..."}，
+// 单点登录账号没有本地密码，只需验证码；策略要求两步验证时不允许关闭
+func AdminTOTPDisable(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || (user.PasswordHash != "" && req.Password == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if !user.TOTPEnabled {
+		twoFactorError(c, services.ErrTOTPNotEnabled)
+		return
+	}
+	required, err := services.TwoFactorRequired(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+		return
+	}
+	if required {
+		twoFactorError(c, services.ErrTwoFactorRequired)
+		return
+	}
+	if err := services.DisableOwnTOTP(config.DB, user, req.Password, req.Code, time.Now()); err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": err.Error()})
+			return
+		}
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+// AdminTOTPRecoveryCodes 用当前验证码重新生成恢复码：{"code": "123456"}，旧恢复码作废
+func AdminTOTPRecoveryCodes(c *gin.Context) {
+	user := currentAdmin(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "unauthorized"})
+		return
+	}
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	codes, err := services.RegenerateRecoveryCodes(config.DB, user.ID, req.Code, time.Now())
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"recovery_codes": codes}})
+}
+
+// AdminUserResetTOTP 重置其他管理员的两步验证，用于丢失设备的情况
+func AdminUserResetTOTP(c *gin.Context) {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	// 自己的两步验证只能通过验证码关闭，避免会话被盗后直接绕过
+	if current := currentAdmin(c); current != nil && current.ID == uint(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "use /admin/me/totp/disable to turn off your own 2fa"})
+		return
+	}
+	if err := services.DisableTOTP(config.DB, uint(id)); err != nil {
+		notFoundOrError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success"})
+}
+
+type SecurityPolicyRequest struct {
+	Require2FA bool `json:"require_2fa"`
+}
+
+// AdminSecurityPolicyGet 读取全局安全策略
+func AdminSecurityPolicyGet(c *gin.Context) {
+	required, err := services.TwoFactorRequired(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"require_2fa": required}})
+}
+
+// AdminSecurityPolicyUpdate 修改全局安全策略：{"require_2fa": true}，开启前操作人需先启用两步验证
+func AdminSecurityPolicyUpdate(c *gin.Context) {
+	var req SecurityPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "msg": "bad request"})
+		return
+	}
+	if user := currentAdmin(c); req.Require2FA && (user == nil || !user.TOTPEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "msg": "enable two-factor authentication for your own account first"})
+		return
+	}
+	if err := services.SetTwoFactorRequired(config.DB, req.Require2FA); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "success", "data": gin.H{"require_2fa": req.Require2FA}})
+}
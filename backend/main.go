@@ -15,6 +15,17 @@ func main() {
 	config.InitConfig()
 	config.InitDB()
 	_ = services.EnsureAdminUser(config.DB)
+	if err := services.EnsureSearchIndexes(config.DB); err != nil {
+		fmt.Println("Error creating search indexes:", err)
+	}
+	if err := services.EnsureDefaultTags(config.DB); err != nil {
+		fmt.Println("Error creating default tags:", err)
+	}
+	if n, err := services.MigrateSessionTokens(config.DB); err != nil {
+		fmt.Println("Error hashing session tokens:", err)
+	} else if n > 0 {
+		fmt.Printf("Hashed %d legacy session tokens\n", n)
+	}
 
 	// 2. Setup Cron
 	c := cron.New()
@@ -48,6 +59,38 @@ func main() {
 		fmt.Println("Error scheduling evening task:", err)
 	}
 
+	// News sources: poll due RSS/Atom feeds every 5 minutes
+	_, err = c.AddFunc("*/5 * * * *", func() {
+		services.RunNewsSourcePoll()
+	})
+	if err != nil {
+		fmt.Println("Error scheduling news source poll:", err)
+	}
+
+	// Email queue: send pending subscription mails every minute, skipping a tick while the previous run is still sending
+	_, err = c.AddJob("* * * * *", cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(func() {
+		services.RunEmailQueue()
+	})))
+	if err != nil {
+		fmt.Println("Error scheduling email queue:", err)
+	}
+
+	// Login history: purge expired attempts daily at 3:30
+	_, err = c.AddFunc("30 3 * * *", func() {
+		services.RunLoginHistoryPurge()
+	})
+	if err != nil {
+		fmt.Println("Error scheduling login history purge:", err)
+	}
+
+	// Admin sessions: sweep expired and idle sessions every 10 minutes
+	_, err = c.AddFunc("*/10 * * * *", func() {
+		services.RunSessionSweep()
+	})
+	if err != nil {
+		fmt.Println("Error scheduling session sweep:", err)
+	}
+
 	c.Start()
 
 	// Optional: Run immediately on startup if DB is empty for demo purposes
@@ -55,6 +98,9 @@ func main() {
 
 	// 3. Setup Router
 	r := gin.Default()
+	if err := controllers.ConfigureTrustedProxies(r, config.AppConfig.System.TrustedProxies); err != nil {
+		fmt.Println("Error setting trusted proxies:", err)
+	}
 
 	// Traffic Counter Middleware
 	r.Use(func(c *gin.Context) {
@@ -65,84 +111,149 @@ func main() {
 		c.Next()
 	})
 
-	// CORS Middleware
-	r.Use(func(c *gin.Context) {
-		origin := c.GetHeader("Origin")
-		if origin != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			c.Writer.Header().Set("Vary", "Origin")
-			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		} else {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		}
-
-		requestHeaders := c.GetHeader("Access-Control-Request-Headers")
-		if requestHeaders != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Headers", requestHeaders)
-		} else {
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With")
-		}
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+	// CORS Middleware: separate policies for public and admin APIs
+	publicCORS, adminCORS := services.CurrentCORSPolicies()
+	if !adminCORS.AllowAnyOrigin && len(adminCORS.Origins) == 0 && len(adminCORS.WildcardOrigins) == 0 {
+		fmt.Println("CORS: cors.admin.allowed_origins is empty, admin API only accepts same-origin requests")
+	}
+	r.Use(controllers.CORSMiddleware(publicCORS, adminCORS))
 
 	api := r.Group("/api")
 	{
 		api.GET("/news/latest", controllers.GetLatestNews)
 		api.GET("/analysis/latest", controllers.GetLatestAnalysis)
 		api.GET("/sites/categories", controllers.GetSiteCategories)
+		api.GET("/tags", controllers.GetTags)
+
+		api.GET("/batches", controllers.GetBatches)
+		api.GET("/batches/:id/news", controllers.GetBatchNews)
+		api.GET("/news/:id", controllers.GetNewsDetail)
+		api.GET("/analysis", controllers.GetAnalysisList)
+		api.GET("/analysis/:id", controllers.GetAnalysisDetail)
+
+		api.GET("/entities", controllers.GetEntities)
+		api.GET("/entities/trending", controllers.GetTrendingEntities)
+		api.GET("/entities/:id", controllers.GetEntity)
+		api.GET("/entities/:id/news", controllers.GetEntityNews)
+		api.GET("/entities/:id/analysis", controllers.GetEntityAnalysis)
+
+		api.GET("/sentiment/timeseries", controllers.GetSentimentTimeSeries)
+		api.GET("/trending", controllers.GetTrendingTopics)
+
+		api.GET("/search", controllers.SearchContent)
+
+		api.GET("/feeds/news/:format", controllers.GetNewsFeed)
+		api.GET("/feeds/analysis/:format", controllers.GetAnalysisFeed)
+
+		api.POST("/subscribe", controllers.Subscribe)
+		api.GET("/subscribe/confirm", controllers.SubscribeConfirm)
+		api.POST("/subscribe/confirm", controllers.SubscribeConfirm)
+		api.GET("/unsubscribe", controllers.Unsubscribe)
+		api.POST("/unsubscribe", controllers.Unsubscribe)
 	}
 
 	admin := api.Group("/admin")
 	{
 		// admin.POST("/setup", controllers.AdminSetup) // 暂时不开放此功能
 		admin.POST("/login", controllers.AdminLogin)
+		admin.POST("/login/verify", controllers.AdminLoginVerify)
+		admin.GET("/oidc/config", controllers.AdminOIDCConfig)
+		admin.GET("/oidc/login", controllers.AdminOIDCLogin)
+		admin.GET("/oidc/callback", controllers.AdminOIDCCallback)
 	}
 
 	adminAuthed := api.Group("/admin")
-	adminAuthed.Use(controllers.AdminAuthMiddleware())
+	adminAuthed.Use(controllers.AdminAuthMiddleware(), controllers.AuditMiddleware())
+	// 每个接口按角色权限校验，见 services.RolePermissions
+	read := controllers.RequirePermission(services.PermContentRead)
+	edit := controllers.RequirePermission(services.PermContentEdit)
+	review := controllers.RequirePermission(services.PermContentReview)
+	remove := controllers.RequirePermission(services.PermContentDelete)
+	manageSites := controllers.RequirePermission(services.PermSiteManage)
+	manageSources := controllers.RequirePermission(services.PermSourceManage)
+	runTasks := controllers.RequirePermission(services.PermTaskRun)
+	manageUsers := controllers.RequirePermission(services.PermUserManage)
+	readAudit := controllers.RequirePermission(services.PermAuditRead)
+	sessionOnly := controllers.RequireSession()
 	{
 		adminAuthed.POST("/logout", controllers.AdminLogout)
-		adminAuthed.POST("/trigger-update", func(c *gin.Context) {
+		adminAuthed.GET("/me", controllers.AdminMe)
+		adminAuthed.POST("/me/password", sessionOnly, controllers.AdminPasswordChange)
+		adminAuthed.POST("/me/oidc/link", sessionOnly, controllers.AdminOIDCLink)
+		adminAuthed.POST("/me/oidc/link/confirm", sessionOnly, controllers.AdminOIDCLinkConfirm)
+		adminAuthed.GET("/me/sessions", sessionOnly, controllers.AdminSessionList)
+		adminAuthed.DELETE("/me/sessions", sessionOnly, controllers.AdminSessionRevokeOthers)
+		adminAuthed.DELETE("/me/sessions/:id", sessionOnly, controllers.AdminSessionRevoke)
+		adminAuthed.GET("/me/totp", sessionOnly, controllers.AdminTOTPStatus)
+		adminAuthed.POST("/me/totp/setup", sessionOnly, controllers.AdminTOTPSetup)
+		adminAuthed.POST("/me/totp/enable", sessionOnly, controllers.AdminTOTPEnable)
+		adminAuthed.POST("/me/totp/disable", sessionOnly, controllers.AdminTOTPDisable)
+		adminAuthed.POST("/me/totp/recovery-codes", sessionOnly, controllers.AdminTOTPRecoveryCodes)
+		adminAuthed.POST("/trigger-update", runTasks, func(c *gin.Context) {
 			go services.RunUpdateTask()
 			c.JSON(200, gin.H{"code": 200, "msg": "success"})
 		})
 
-		adminAuthed.GET("/users", controllers.AdminUserList)
-		adminAuthed.POST("/users", controllers.AdminUserCreate)
-		adminAuthed.PATCH("/users/:id/password", controllers.AdminUserSetPassword)
-		adminAuthed.DELETE("/users/:id", controllers.AdminUserDelete)
-
-		adminAuthed.GET("/site-categories", controllers.AdminSiteCategoryList)
-		adminAuthed.POST("/site-categories", controllers.AdminSiteCategoryCreate)
-		adminAuthed.PATCH("/site-categories/:id", controllers.AdminSiteCategoryUpdate)
-		adminAuthed.DELETE("/site-categories/:id", controllers.AdminSiteCategoryDelete)
-
-		adminAuthed.GET("/sites", controllers.AdminSiteList)
-		adminAuthed.POST("/sites", controllers.AdminSiteCreate)
-		adminAuthed.PATCH("/sites/:id", controllers.AdminSiteUpdate)
-		adminAuthed.DELETE("/sites/:id", controllers.AdminSiteDelete)
-
-		adminAuthed.GET("/batches", controllers.AdminBatchList)
-		adminAuthed.GET("/batches/:id/news", controllers.AdminBatchNewsList)
-		adminAuthed.DELETE("/batches/:id", controllers.AdminBatchDelete)
-
-		adminAuthed.GET("/news", controllers.AdminNewsList)
-		adminAuthed.POST("/news", controllers.AdminNewsCreate)
-		adminAuthed.PATCH("/news/:id", controllers.AdminNewsUpdate)
-		adminAuthed.DELETE("/news/:id", controllers.AdminNewsDelete)
-
-		adminAuthed.GET("/analysis", controllers.AdminAnalysisList)
-		adminAuthed.POST("/analysis", controllers.AdminAnalysisCreate)
-		adminAuthed.PATCH("/analysis/:id", controllers.AdminAnalysisUpdate)
-		adminAuthed.DELETE("/analysis/:id", controllers.AdminAnalysisDelete)
+		adminAuthed.GET("/users", manageUsers, controllers.AdminUserList)
+		adminAuthed.POST("/users", manageUsers, controllers.AdminUserCreate)
+		adminAuthed.PATCH("/users/:id/password", manageUsers, controllers.AdminUserSetPassword)
+		adminAuthed.DELETE("/users/:id", manageUsers, controllers.AdminUserDelete)
+		adminAuthed.PATCH("/users/:id/role", manageUsers, controllers.AdminUserSetRole)
+		adminAuthed.DELETE("/users/:id/totp", manageUsers, controllers.AdminUserResetTOTP)
+		adminAuthed.GET("/security-policy", manageUsers, controllers.AdminSecurityPolicyGet)
+		adminAuthed.PUT("/security-policy", manageUsers, controllers.AdminSecurityPolicyUpdate)
+		adminAuthed.GET("/api-keys", manageUsers, controllers.AdminAPIKeyList)
+		adminAuthed.POST("/api-keys", manageUsers, controllers.AdminAPIKeyCreate)
+		adminAuthed.DELETE("/api-keys/:id", manageUsers, controllers.AdminAPIKeyRevoke)
+
+		adminAuthed.GET("/audit-logs", readAudit, controllers.AdminAuditLogList)
+		adminAuthed.GET("/login-attempts", readAudit, controllers.AdminLoginAttemptList)
+		adminAuthed.GET("/login-attempts/report", readAudit, controllers.AdminLoginReport)
+		adminAuthed.DELETE("/login-locks/:key", manageUsers, controllers.AdminLoginUnlock)
+
+		adminAuthed.GET("/site-categories", read, controllers.AdminSiteCategoryList)
+		adminAuthed.POST("/site-categories", manageSites, controllers.AdminSiteCategoryCreate)
+		adminAuthed.PATCH("/site-categories/:id", manageSites, controllers.AdminSiteCategoryUpdate)
+		adminAuthed.DELETE("/site-categories/:id", manageSites, controllers.AdminSiteCategoryDelete)
+
+		adminAuthed.GET("/sites", read, controllers.AdminSiteList)
+		adminAuthed.POST("/sites", manageSites, controllers.AdminSiteCreate)
+		adminAuthed.PATCH("/sites/:id", manageSites, controllers.AdminSiteUpdate)
+		adminAuthed.DELETE("/sites/:id", manageSites, controllers.AdminSiteDelete)
+
+		adminAuthed.GET("/news-sources", read, controllers.AdminNewsSourceList)
+		adminAuthed.POST("/news-sources", manageSources, controllers.AdminNewsSourceCreate)
+		adminAuthed.PATCH("/news-sources/:id", manageSources, controllers.AdminNewsSourceUpdate)
+		adminAuthed.DELETE("/news-sources/:id", manageSources, controllers.AdminNewsSourceDelete)
+		adminAuthed.POST("/news-sources/:id/fetch", runTasks, controllers.AdminNewsSourceFetch)
+
+		adminAuthed.GET("/batches", read, controllers.AdminBatchList)
+		adminAuthed.GET("/batches/:id/news", read, controllers.AdminBatchNewsList)
+		adminAuthed.DELETE("/batches/:id", remove, controllers.AdminBatchDelete)
+		adminAuthed.POST("/batches/:id/classify", runTasks, controllers.AdminBatchClassify)
+		adminAuthed.POST("/batches/:id/trending", runTasks, controllers.AdminBatchTrending)
+		adminAuthed.POST("/batches/:id/translate", runTasks, controllers.AdminBatchTranslate)
+		adminAuthed.POST("/batches/:id/review", review, controllers.AdminBatchReview)
+		adminAuthed.PUT("/batches/:id/order", edit, controllers.AdminBatchReorder)
+
+		adminAuthed.GET("/news", read, controllers.AdminNewsList)
+		adminAuthed.POST("/news", edit, controllers.AdminNewsCreate)
+		adminAuthed.PATCH("/news/:id", edit, controllers.AdminNewsUpdate)
+		adminAuthed.DELETE("/news/:id", remove, controllers.AdminNewsDelete)
+		adminAuthed.POST("/news/:id/extract", runTasks, controllers.AdminNewsExtract)
+		adminAuthed.PUT("/news/:id/tags", edit, controllers.AdminNewsSetTags)
+		adminAuthed.POST("/news/:id/review", review, controllers.AdminNewsReview)
+
+		adminAuthed.GET("/tags", read, controllers.AdminTagList)
+		adminAuthed.POST("/tags", edit, controllers.AdminTagCreate)
+		adminAuthed.PATCH("/tags/:id", edit, controllers.AdminTagUpdate)
+		adminAuthed.DELETE("/tags/:id", edit, controllers.AdminTagDelete)
+
+		adminAuthed.GET("/analysis", read, controllers.AdminAnalysisList)
+		adminAuthed.POST("/analysis", edit, controllers.AdminAnalysisCreate)
+		adminAuthed.PATCH("/analysis/:id", edit, controllers.AdminAnalysisUpdate)
+		adminAuthed.DELETE("/analysis/:id", remove, controllers.AdminAnalysisDelete)
+		adminAuthed.POST("/analysis/:id/review", review, controllers.AdminAnalysisReview)
 	}
 
 	// 4. Run
@@ -14,27 +14,63 @@ const (
 	BatchEvening BatchType = "evening"
 )
 
+// PublishStatus 批次、新闻和分析的发布状态：draft → in_review → published / rejected
+type PublishStatus string
+
+const (
+	StatusDraft     PublishStatus = "draft"
+	StatusInReview  PublishStatus = "in_review"
+	StatusPublished PublishStatus = "published"
+	StatusRejected  PublishStatus = "rejected"
+)
+
 type BatchLog struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Type      BatchType      `json:"type"` // morning, noon, evening
-	Date      string         `json:"date"` // YYYY-MM-DD
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Type        BatchType      `json:"type"`                                          // morning, noon, evening
+	Date        string         `json:"date"`                                          // YYYY-MM-DD
+	Status      PublishStatus  `gorm:"size:20;index;default:published" json:"status"` // 历史数据默认已发布
+	ReviewNote  string         `gorm:"size:512" json:"review_note"`                   // 审核意见，如驳回原因
+	ReviewedBy  uint           `json:"reviewed_by"`                                   // 最近一次审核的管理员
+	ReviewedAt  *time.Time     `json:"reviewed_at"`
+	PublishedAt *time.Time     `json:"published_at"` // 首次发布时间，群聊推送和订阅邮件只在首次发布时发送
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type NewsItem struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	BatchID   uint           `json:"batch_id"`
-	Title     string         `json:"title"`
-	Content   string         `json:"content"`
-	Url       string         `json:"url"`
-	Source    string         `json:"source"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	BatchID       uint           `json:"batch_id"`
+	Title         string         `json:"title"`
+	Content       string         `json:"content"`
+	Url           string         `json:"url"`
+	Source        string         `json:"source"`
+	Summary       string         `gorm:"type:text" json:"summary"`
+	WordCount     int            `json:"word_count"`                    // 正文字数，中文按字计
+	ExtractedAt   *time.Time     `json:"extracted_at"`                  // 正文抽取时间
+	ExtractStatus string         `gorm:"size:20" json:"extract_status"` // ok, error，未抽取为空
+	PublishedAt   *time.Time     `json:"published_at"`                  // 原始报道发布时间，未知时为空
+	Sentiment     *float64       `json:"sentiment"`                     // 情绪分 -1（利空）~ 1（利好），未评分为空
+	MarketImpact  string         `gorm:"size:10" json:"market_impact"`  // bullish, bearish, neutral
+	Language      string         `gorm:"size:8" json:"language"`        // 原文语言：zh, en
+	Status        PublishStatus  `gorm:"size:20;index;default:published" json:"status"`
+	Pinned        bool           `json:"pinned"`      // 置顶，始终排在批次最前
+	Featured      bool           `json:"featured"`    // 重点推荐，前端突出显示
+	SortRank      int            `json:"sort_rank"`   // 批次内的编辑排序，从 1 开始，越小越靠前
+	TagsManual    bool           `json:"tags_manual"` // 标签由管理员手工设置（包括清空），自动分类不再覆盖
+	Tags          []Tag          `gorm:"many2many:news_item_tags" json:"tags,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// NewsItem.MarketImpact 取值
+const (
+	ImpactBullish = "bullish"
+	ImpactBearish = "bearish"
+	ImpactNeutral = "neutral"
+)
+
 type AnalysisType string
 
 const (
@@ -47,6 +83,7 @@ type Analysis struct {
 	BatchID   uint           `json:"batch_id"`
 	Type      AnalysisType   `json:"type"` // 3_day, 7_day
 	Content   string         `json:"content"`
+	Status    PublishStatus  `gorm:"size:20;index;default:published" json:"status"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -83,18 +120,297 @@ type SiteItem struct {
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// AdminRole 管理员角色，权限见 services.RolePermissions
+type AdminRole string
+
+const (
+	RoleOwner    AdminRole = "owner"    // 全部权限，包括管理员账号
+	RoleEditor   AdminRole = "editor"   // 编辑和审核内容
+	RoleOperator AdminRole = "operator" // 维护新闻源、触发任务
+	RoleViewer   AdminRole = "viewer"   // 只读
+)
+
 type AdminUser struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Username     string         `gorm:"uniqueIndex;size:64" json:"username"`
-	PasswordHash string         `gorm:"size:255" json:"-"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Username           string         `gorm:"uniqueIndex;size:64" json:"username"`
+	PasswordHash       string         `gorm:"size:255" json:"-"`
+	PasswordChangedAt  *time.Time     `json:"password_changed_at"`                     // 升级前的账号为空，按创建时间计算密码年龄
+	MustChangePassword bool           `json:"must_change_password"`                    // 初始化或被重置的密码，登录后须先修改
+	Role               AdminRole      `gorm:"size:20;index;default:owner" json:"role"` // 已有账号升级后为 owner
+	TOTPSecret         string         `gorm:"size:64" json:"-"`                        // base32 密钥，开始绑定后写入，启用前为待确认状态
+	TOTPEnabled        bool           `json:"totp_enabled"`
+	TOTPLastStep       int64          `json:"-"`                           // 最近一次通过校验的时间步，防止验证码重放
+	OIDCIssuer         string         `gorm:"size:255" json:"oidc_issuer"` // 单点登录账号的身份提供方，本地账号为空
+	OIDCSubject        string         `gorm:"size:255;index" json:"-"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type AdminSession struct {
-	Token     string    `gorm:"primaryKey;size:128" json:"token"`
+	TokenHash  string     `gorm:"primaryKey;size:128;column:token" json:"-"` // 令牌的 SHA-256 十六进制，沿用原 token 列
+	UserID     uint       `gorm:"index" json:"user_id"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"` // 绝对过期时间，不随使用延长
+	LastSeenAt *time.Time `gorm:"index" json:"last_seen_at"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	UserAgent  string     `gorm:"size:255" json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AdminRecoveryCode 两步验证恢复码，只保存哈希，每个只能使用一次
+type AdminRecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;index" json:"-"` // SHA-256 十六进制
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AdminLoginChallenge 密码校验通过、等待第二步验证的登录
+type AdminLoginChallenge struct {
+	Token     string    `gorm:"primaryKey;size:128" json:"-"`
 	UserID    uint      `gorm:"index" json:"user_id"`
+	Attempts  int       `json:"attempts"`
 	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// APIKey 自动化脚本调用管理端接口的密钥，只保存哈希；可用权限为 Scopes 与所属管理员角色权限的交集
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"size:100" json:"name"`
+	KeyPrefix  string     `gorm:"size:16" json:"key_prefix"` // 密钥明文的前几位，用于辨认
+	SecretHash string     `gorm:"size:64;uniqueIndex" json:"-"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	CreatedBy  uint       `gorm:"index" json:"created_by"` // 创建密钥的管理员，代他人创建时与 UserID 不同
+	Scopes     string     `gorm:"size:255" json:"scopes"`  // 逗号分隔，如 news:write,run:trigger
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// OIDCLoginState 单点登录跳转前保存的 state、nonce 和 PKCE 校验码，回调时取出并删除
+type OIDCLoginState struct {
+	State        string    `gorm:"primaryKey;size:64" json:"-"`
+	Nonce        string    `gorm:"size:64" json:"-"`
+	CodeVerifier string    `gorm:"size:128" json:"-"`
+	LinkUserID   uint      `json:"-"`                 // 非 0 表示已登录的管理员发起的账号关联，回调后需该管理员确认
+	LinkIssuer   string    `gorm:"size:255" json:"-"` // 回调校验通过、等待确认关联的身份提供方账号
+	LinkSubject  string    `gorm:"size:255" json:"-"`
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AdminSetting 管理端全局设置
+type AdminSetting struct {
+	Key       string    `gorm:"primaryKey;size:64" json:"key"`
+	Value     string    `gorm:"size:255" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SubscriberStatus string
+
+const (
+	SubscriberPending      SubscriberStatus = "pending"
+	SubscriberActive       SubscriberStatus = "active"
+	SubscriberUnsubscribed SubscriberStatus = "unsubscribed"
+)
+
+// SubscriptionAnalysis 订阅每日分析（晚报生成后发送），其余主题与 BatchType 一致
+const SubscriptionAnalysis = "analysis"
+
+// Subscriber 邮件订阅者，需双重确认（double opt-in）
+type Subscriber struct {
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	Email            string           `gorm:"uniqueIndex;size:255" json:"email"`
+	Topics           string           `gorm:"size:255" json:"topics"` // 逗号分隔：morning,noon,evening,analysis
+	PendingTopics    string           `gorm:"size:255" json:"-"`      // 待确认的主题，确认后写入 Topics
+	Status           SubscriberStatus `gorm:"size:20;index" json:"status"`
+	ConfirmToken     string           `gorm:"size:64;index" json:"-"`
+	ConfirmExpiresAt *time.Time       `json:"-"` // 确认链接过期时间
+	ConfirmSentAt    *time.Time       `json:"-"` // 最近一次发送确认邮件的时间，用于限制重复发送
+	UnsubscribeToken string           `gorm:"size:64;index" json:"-"`
+	ConfirmedAt      *time.Time       `json:"confirmed_at"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+}
+
+type EmailStatus string
+
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSending EmailStatus = "sending" // 已被某次队列处理领取，正在发送
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed"
+)
+
+// EmailQueue 待发送邮件队列，失败后按退避时间重试
+type EmailQueue struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	To            string      `gorm:"size:255" json:"to"`
+	Subject       string      `gorm:"size:255" json:"subject"`
+	TextBody      string      `gorm:"type:text" json:"text_body"`
+	HTMLBody      string      `gorm:"type:mediumtext" json:"html_body"`
+	Unsubscribe   string      `gorm:"size:512" json:"unsubscribe"` // List-Unsubscribe 链接
+	Status        EmailStatus `gorm:"size:20;index" json:"status"`
+	Attempts      int         `json:"attempts"`
+	LastError     string      `gorm:"type:text" json:"last_error"`
+	NextAttemptAt time.Time   `gorm:"index" json:"next_attempt_at"`
+	SentAt        *time.Time  `json:"sent_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// NewsSource 管理端维护的 RSS/Atom 新闻源
+type NewsSource struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Name          string         `gorm:"size:100" json:"name"` // 媒体名称，如 新华网
+	Url           string         `gorm:"size:1024" json:"url"`
+	Category      string         `gorm:"size:50" json:"category"`
+	PollInterval  int            `json:"poll_interval"` // 抓取间隔（分钟）
+	Enabled       bool           `json:"enabled"`
+	LastFetchedAt *time.Time     `json:"last_fetched_at"`
+	LastStatus    string         `gorm:"size:20" json:"last_status"` // ok, error
+	LastError     string         `gorm:"type:text" json:"last_error"`
+	LastItemCount int            `json:"last_item_count"` // 最近一次抓取新增条目数
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// NewsSourceItem 新闻源抓取到的原始条目，更新任务从中挑选入库
+type NewsSourceItem struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	SourceID    uint       `gorm:"uniqueIndex:idx_source_guid" json:"source_id"`
+	GUIDHash    string     `gorm:"size:64;uniqueIndex:idx_source_guid" json:"-"` // sha256(guid)
+	Title       string     `gorm:"size:512" json:"title"`
+	Url         string     `gorm:"size:1024" json:"url"`
+	Summary     string     `gorm:"type:text" json:"summary"`
+	PublishedAt *time.Time `json:"published_at"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
+
+// Tag 新闻标签（主题），如 宏观、大宗商品、科技
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Slug      string    `gorm:"uniqueIndex;size:64" json:"slug"` // 用于接口筛选，如 macro
+	Name      string    `gorm:"size:64" json:"name"`
+	Keywords  string    `gorm:"type:text" json:"keywords"` // 逗号分隔，AI 不可用时按关键词分类
+	Sort      int       `json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagSource string
+
+const (
+	TagSourceAI     TagSource = "ai"
+	TagSourceRule   TagSource = "rule"
+	TagSourceManual TagSource = "manual" // 管理员修正，自动分类不再覆盖
+)
+
+// NewsItemTag 新闻与标签的关联表，记录标签来源
+type NewsItemTag struct {
+	NewsItemID uint      `gorm:"primaryKey" json:"news_item_id"`
+	TagID      uint      `gorm:"primaryKey;index" json:"tag_id"`
+	Source     TagSource `gorm:"size:10" json:"source"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type EntityType string
+
+const (
+	EntityCompany   EntityType = "company"
+	EntityTicker    EntityType = "ticker"
+	EntityCountry   EntityType = "country"
+	EntityPerson    EntityType = "person"
+	EntityCommodity EntityType = "commodity"
+)
+
+// Entity 从新闻中识别出的实体（公司、股票代码、国家、人物、大宗商品）
+type Entity struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Type      EntityType `gorm:"size:20;uniqueIndex:idx_entity_type_name" json:"type"`
+	Name      string     `gorm:"size:128;uniqueIndex:idx_entity_type_name" json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// EntityMention 实体在新闻或分析中的一次出现，NewsItemID 与 AnalysisID 二选一
+type EntityMention struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EntityID   uint      `gorm:"uniqueIndex:idx_entity_mention" json:"entity_id"`
+	NewsItemID uint      `gorm:"uniqueIndex:idx_entity_mention;index" json:"news_item_id"`
+	AnalysisID uint      `gorm:"uniqueIndex:idx_entity_mention;index" json:"analysis_id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// TrendingTopic 每个批次的热点话题快照，按突增分数排序
+type TrendingTopic struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BatchID   uint      `gorm:"index" json:"batch_id"`
+	Kind      string    `gorm:"size:10" json:"kind"` // entity, keyword
+	Key       string    `gorm:"size:160" json:"key"` // entity:12、keyword:降准
+	Label     string    `gorm:"size:128" json:"label"`
+	EntityID  uint      `json:"entity_id"`
+	Count     int       `json:"count"`    // 本批次提及新闻数
+	Baseline  float64   `json:"baseline"` // 之前批次的平均提及数
+	Score     float64   `json:"score"`    // 突增分数
+	Rank      int       `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewsTranslation 新闻标题和摘要的译文，原文语言不单独保存
+type NewsTranslation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	NewsItemID uint      `gorm:"uniqueIndex:idx_news_lang" json:"news_item_id"`
+	Lang       string    `gorm:"size:8;uniqueIndex:idx_news_lang" json:"lang"`
+	Title      string    `gorm:"size:512" json:"title"`
+	Summary    string    `gorm:"type:text" json:"summary"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AuditLog 管理端写操作的审计记录
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index" json:"user_id"`
+	Username   string    `gorm:"size:64" json:"username"`
+	APIKeyID   uint      `gorm:"index" json:"api_key_id"`     // 通过 API 密钥调用时的密钥 ID
+	KeyCreator uint      `gorm:"index" json:"key_creator"`    // 该密钥的创建者，代他人创建的密钥据此追溯
+	Action     string    `gorm:"size:64;index" json:"action"` // 如 batch.delete、news.update、user.role
+	Method     string    `gorm:"size:10" json:"method"`
+	Path       string    `gorm:"size:255" json:"path"`
+	TargetType string    `gorm:"size:32;index:idx_audit_target" json:"target_type"` // user, batch, news ...
+	TargetID   uint      `gorm:"index:idx_audit_target" json:"target_id"`
+	TargetKey  string    `gorm:"size:128" json:"target_key"`     // 不以数字 ID 定位的目标，如登录锁定 key、会话 ID
+	Before     string    `gorm:"type:mediumtext" json:"before"`  // 操作前的记录 JSON
+	After      string    `gorm:"type:mediumtext" json:"after"`   // 操作后的记录 JSON，删除后为空
+	Changes    string    `gorm:"type:mediumtext" json:"changes"` // 变化字段：{"title": {"before": ..., "after": ...}}
+	Status     int       `json:"status"`                         // HTTP 状态码
+	IP         string    `gorm:"size:64" json:"ip"`
+	UserAgent  string    `gorm:"size:255" json:"user_agent"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// LoginAttempt 管理端登录记录
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"size:64;index" json:"username"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `gorm:"size:32" json:"reason"` // ok, bad_password, unknown_user, locked
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LoginLock 用户名或 IP 的失败计数和锁定状态
+type LoginLock struct {
+	Key         string     `gorm:"primaryKey;size:128" json:"key"` // user:<用户名> 或 ip:<地址>
+	Failures    int        `json:"failures"`                       // 当前窗口内的失败次数
+	Lockouts    int        `json:"lockouts"`                       // 连续锁定次数，决定下次锁定时长
+	LockedUntil *time.Time `json:"locked_until"`
+	LastFailure time.Time  `json:"last_failure"`
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bre_new_backend/config"
 	"bre_new_backend/models"
 	"crypto/rand"
 	"encoding/base64"
@@ -12,6 +13,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 type SetupAdminRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -41,26 +44,45 @@ func EnsureAdminUser(db *gorm.DB) error {
 		return nil
 	}
 
-	_, err := CreateAdminUser(db, username, password)
+	// 环境变量中的密码可能被多人看到，不校验强度，但首次登录后必须修改
+	_, err := createAdminUser(db, username, password, models.RoleOwner, true)
 	return err
 }
 
-func CreateAdminUser(db *gorm.DB, username, password string) (*models.AdminUser, error) {
+// CreateAdminUser 按密码策略校验后创建管理员；mustChange 表示密码由他人设置，首次登录后须修改
+func CreateAdminUser(db *gorm.DB, username, password string, role models.AdminRole, mustChange bool) (*models.AdminUser, error) {
+	if username == "" || password == "" {
+		return nil, errors.New("username or password empty")
+	}
+	if err := CurrentPasswordPolicy().Check(username, password); err != nil {
+		return nil, err
+	}
+	return createAdminUser(db, username, password, role, mustChange)
+}
+
+func createAdminUser(db *gorm.DB, username, password string, role models.AdminRole, mustChange bool) (*models.AdminUser, error) {
 	if db == nil {
 		return nil, errors.New("db is nil")
 	}
 	if username == "" || password == "" {
 		return nil, errors.New("username or password empty")
 	}
+	if !IsAdminRole(role) {
+		return nil, errors.New("invalid role")
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	user := models.AdminUser{
-		Username:     username,
-		PasswordHash: string(hash),
+		Username:           username,
+		PasswordHash:       string(hash),
+		PasswordChangedAt:  &now,
+		MustChangePassword: mustChange,
+		Role:               role,
 	}
 	if err := db.Create(&user).Error; err != nil {
 		return nil, err
@@ -77,37 +99,53 @@ func ValidateAdminLogin(db *gorm.DB, username, password string) (*models.AdminUs
 		return nil, err
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 	return &user, nil
 }
 
-func CreateAdminSession(db *gorm.DB, userID uint, ttl time.Duration) (*models.AdminSession, error) {
+// newRandomToken 生成 32 字节随机数的 URL 安全编码，用于会话和登录挑战
+func newRandomToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// CreateAdminSession 创建会话并记录登录设备的 IP 和 User-Agent，返回的令牌明文只在此时可见，库中只保存哈希
+func CreateAdminSession(db *gorm.DB, userID uint, ttl time.Duration, ip, userAgent string) (*models.AdminSession, string, error) {
 	if db == nil {
-		return nil, errors.New("db is nil")
+		return nil, "", errors.New("db is nil")
 	}
 
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return nil, err
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, "", err
 	}
+	token = config.AppConfig.Session.TokenPrefix + token
 
+	now := time.Now()
 	session := models.AdminSession{
-		Token:     base64.RawURLEncoding.EncodeToString(tokenBytes),
-		UserID:    userID,
-		ExpiresAt: time.Now().Add(ttl),
+		TokenHash:  HashSessionToken(token),
+		UserID:     userID,
+		ExpiresAt:  now.Add(ttl),
+		LastSeenAt: &now,
+		IP:         ip,
+		UserAgent:  truncateText(userAgent, 255),
 	}
 	if err := db.Create(&session).Error; err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return &session, nil
+	return &session, token, nil
 }
 
-func DeleteAdminSession(db *gorm.DB, token string) error {
+// DeleteAdminSession 按令牌哈希删除会话
+func DeleteAdminSession(db *gorm.DB, tokenHash string) error {
 	if db == nil {
 		return errors.New("db is nil")
 	}
-	return db.Where("token = ?", token).Delete(&models.AdminSession{}).Error
+	return db.Where("token = ?", tokenHash).Delete(&models.AdminSession{}).Error
 }
 
 func SetupAdminUser(db *gorm.DB, req SetupAdminRequest) (*models.AdminUser, error) {
@@ -122,7 +160,7 @@ func SetupAdminUser(db *gorm.DB, req SetupAdminRequest) (*models.AdminUser, erro
 	if count > 0 {
 		return nil, errors.New("already initialized")
 	}
-	return CreateAdminUser(db, req.Username, req.Password)
+	return CreateAdminUser(db, req.Username, req.Password, models.RoleOwner, false)
 }
 
 func GetAdminSessionUser(db *gorm.DB, token string) (*models.AdminUser, *models.AdminSession, error) {
@@ -134,13 +172,16 @@ func GetAdminSessionUser(db *gorm.DB, token string) (*models.AdminUser, *models.
 	}
 
 	var session models.AdminSession
-	if err := db.Where("token = ?", token).First(&session).Error; err != nil {
+	if err := db.Where("token = ?", HashSessionToken(token)).First(&session).Error; err != nil {
 		return nil, nil, err
 	}
-	if time.Now().After(session.ExpiresAt) {
-		_ = DeleteAdminSession(db, token)
+	now := time.Now()
+	policy := CurrentSessionPolicy()
+	if policy.Expired(&session, now) {
+		_ = DeleteAdminSession(db, session.TokenHash)
 		return nil, nil, errors.New("session expired")
 	}
+	touchAdminSession(db, &session, now)
 
 	var user models.AdminUser
 	if err := db.Where("id = ?", session.UserID).First(&user).Error; err != nil {
@@ -4,6 +4,7 @@ import (
 	"bre_new_backend/config"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -196,8 +197,13 @@ func CallAI(prompt string) (string, error) {
 }
 
 type NewsData struct {
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Summary     string     `json:"summary,omitempty"`
+	Source      string     `json:"-"`
+	Category    string     `json:"-"`
+	GUID        string     `json:"-"`
+	PublishedAt *time.Time `json:"-"`
 }
 
 func GetDailyNews() ([]NewsData, error) {
@@ -232,9 +238,78 @@ func GetDailyNews() ([]NewsData, error) {
 	return newsList, nil
 }
 
+// RankNews 让 AI 从候选新闻中挑选并总结，只能引用候选编号，不能新增新闻
+func RankNews(candidates []NewsData, limit int) ([]NewsData, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var sb strings.Builder
+	for i, item := range candidates {
+		sb.WriteString(fmt.Sprintf("[%d] %s", i, item.Title))
+		if item.Source != "" {
+			sb.WriteString(" | 来源: " + item.Source)
+		}
+		if item.PublishedAt != nil {
+			sb.WriteString(" | 时间: " + item.PublishedAt.Format("2006-01-02 15:04"))
+		}
+		if item.Summary != "" {
+			sb.WriteString(" | 摘要: " + truncateText(item.Summary, 300))
+		}
+		sb.WriteString("\n")
+	}
+	prompt := fmt.Sprintf(`以下是编号的候选新闻列表。请合并重复报道，按财经重要性挑选最多 %d 条，
+并为每条写一句不超过 60 字的中文摘要。只能从列表中选择，不要新增或改写事实。
+请严格按照 JSON 对象数组格式输出，每个对象包含 index（候选编号）和 summary 字段，不要包含 Markdown 标记或其他多余文字。
+例如：[{"index": 3, "summary": "..."}]
+候选新闻：
+%s`, limit, sb.String())
+	log.Printf("AI Prompt: %s", prompt)
+
+	response, err := CallAI(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanResponse := strings.TrimSpace(response)
+	start := strings.Index(cleanResponse, "[")
+	end := strings.LastIndex(cleanResponse, "]")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = cleanResponse[start : end+1]
+	}
+	var ranked []struct {
+		Index   int    `json:"index"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(cleanResponse), &ranked); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %v. Response: %s", err, response)
+	}
+
+	used := map[int]bool{}
+	var out []NewsData
+	for _, r := range ranked {
+		if r.Index < 0 || r.Index >= len(candidates) || used[r.Index] {
+			continue
+		}
+		used[r.Index] = true
+		item := candidates[r.Index]
+		if s := strings.TrimSpace(r.Summary); s != "" {
+			item.Summary = s
+		}
+		out = append(out, item)
+		if len(out) >= limit {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("AI returned no valid news index")
+	}
+	return out, nil
+}
+
 func AnalyzeNews(newsContent string, days int) (string, error) {
 	prompt := fmt.Sprintf("以下是过去 %d 天的新闻内容，请进行简要的财经分析，并推荐相关的3个板块及匹配度(只能在我给定的内容中总结分析，不要分散)：\n%s", days, newsContent)
-	log.Println("AI Prompt: %s", prompt)
+	log.Printf("AI Prompt: %s", prompt)
 	return CallAI(prompt)
 }
 
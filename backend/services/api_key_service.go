@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix API 密钥的固定前缀，认证中间件据此区分密钥和会话令牌
+const APIKeyPrefix = "brek_"
+
+// apiKeyTouchInterval 最近使用时间的最小更新间隔
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyScopes 密钥可申请的范围及其对应的权限
+var APIKeyScopes = map[string][]Permission{
+	"news:read":      {PermContentRead},
+	"news:write":     {PermContentRead, PermContentEdit},
+	"news:review":    {PermContentRead, PermContentReview},
+	"sources:manage": {PermContentRead, PermSourceManage},
+	"run:trigger":    {PermTaskRun},
+}
+
+var (
+	ErrUnknownScope  = errors.New("unknown scope")
+	ErrScopeNotOwned = errors.New("scope exceeds your role")
+	ErrAPIKeyInvalid = errors.New("invalid api key")
+)
+
+// hashAPIKey 密钥与会话令牌一样只保存 SHA-256 十六进制
+func hashAPIKey(plain string) string {
+	return HashSessionToken(plain)
+}
+
+// IsAPIKey 判断 Bearer 令牌是否为 API 密钥
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// NormalizeScopes 去重排序并校验范围，返回逗号分隔的形式
+func NormalizeScopes(scopes []string) (string, error) {
+	seen := map[string]bool{}
+	list := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if _, ok := APIKeyScopes[scope]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+		seen[scope] = true
+		list = append(list, scope)
+	}
+	if len(list) == 0 {
+		return "", ErrUnknownScope
+	}
+	sort.Strings(list)
+	return strings.Join(list, ","), nil
+}
+
+// APIKeyAllows 密钥是否拥有权限：需同时在密钥范围和所属管理员角色内
+func APIKeyAllows(key *models.APIKey, role models.AdminRole, perm Permission) bool {
+	if !HasPermission(role, perm) {
+		return false
+	}
+	for _, scope := range strings.Split(key.Scopes, ",") {
+		for _, p := range APIKeyScopes[scope] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CreateAPIKey 为管理员 user 创建密钥，scopes 不能超出其角色权限；createdBy 为操作的管理员；返回的明文只在此时可见
+func CreateAPIKey(db *gorm.DB, user *models.AdminUser, createdBy uint, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	if db == nil {
+		return nil, "", errors.New("db is nil")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name empty")
+	}
+	normalized, err := NormalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, scope := range strings.Split(normalized, ",") {
+		for _, p := range APIKeyScopes[scope] {
+			if !HasPermission(user.Role, p) {
+				return nil, "", fmt.Errorf("%w: %s", ErrScopeNotOwned, scope)
+			}
+		}
+	}
+
+	secret, err := newRandomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	plain := APIKeyPrefix + secret
+	key := models.APIKey{
+		Name:       truncateText(name, 100),
+		KeyPrefix:  plain[:len(APIKeyPrefix)+6],
+		SecretHash: hashAPIKey(plain),
+		UserID:     user.ID,
+		CreatedBy:  createdBy,
+		Scopes:     normalized,
+		ExpiresAt:  expiresAt,
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+	return &key, plain, nil
+}
+
+// AuthenticateAPIKey 校验密钥并返回密钥和所属管理员，已吊销、过期或管理员已删除时返回 ErrAPIKeyInvalid
+func AuthenticateAPIKey(db *gorm.DB, plain, ip string, now time.Time) (*models.APIKey, *models.AdminUser, error) {
+	if db == nil {
+		return nil, nil, errors.New("db is nil")
+	}
+	var key models.APIKey
+	if err := db.Where("secret_hash = ?", hashAPIKey(plain)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAPIKeyInvalid
+		}
+		return nil, nil, err
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	var user models.AdminUser
+	if err := db.First(&user, key.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAPIKeyInvalid
+		}
+		return nil, nil, err
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != ip {
+		err := db.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			fmt.Printf("更新 API 密钥使用时间失败: %v\n", err)
+		}
+	}
+	return &key, &user, nil
+}
+
+// RevokeAPIKey 吊销密钥，已吊销的密钥保持原吊销时间
+func RevokeAPIKey(db *gorm.DB, id uint, now time.Time) (*models.APIKey, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var key models.APIKey
+	if err := db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		if err := db.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+	return &key, nil
+}
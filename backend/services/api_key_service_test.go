@@ -0,0 +1,48 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"testing"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	got, err := NormalizeScopes([]string{"run:trigger", " news:write ", "run:trigger", ""})
+	if err != nil || got != "news:write,run:trigger" {
+		t.Fatalf("NormalizeScopes = %q, %v", got, err)
+	}
+	if _, err := NormalizeScopes([]string{"user:manage"}); !errors.Is(err, ErrUnknownScope) {
+		t.Fatalf("unknown scope should be rejected, got %v", err)
+	}
+	if _, err := NormalizeScopes(nil); !errors.Is(err, ErrUnknownScope) {
+		t.Fatalf("empty scopes should be rejected, got %v", err)
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	key := &models.APIKey{Scopes: "news:write,run:trigger"}
+	cases := []struct {
+		role models.AdminRole
+		perm Permission
+		want bool
+	}{
+		{models.RoleOwner, PermContentEdit, true},
+		{models.RoleOwner, PermTaskRun, true},
+		{models.RoleOwner, PermContentDelete, false}, // 超出密钥范围
+		{models.RoleOwner, PermUserManage, false},
+		{models.RoleEditor, PermContentEdit, true},
+		{models.RoleEditor, PermTaskRun, false}, // 超出所属管理员角色
+		{models.RoleViewer, PermContentRead, true},
+	}
+	for _, tc := range cases {
+		if got := APIKeyAllows(key, tc.role, tc.perm); got != tc.want {
+			t.Fatalf("APIKeyAllows(%s, %s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestIsAPIKey(t *testing.T) {
+	if !IsAPIKey(APIKeyPrefix+"abc") || IsAPIKey("abc") {
+		t.Fatal("api keys are recognized by prefix")
+	}
+}
@@ -0,0 +1,464 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"gorm.io/gorm"
+)
+
+const (
+	maxArticleBodyBytes = 3 << 20
+	minArticleRunes     = 80 // 抽取结果少于该字数视为失败
+	defaultArticleJobs  = 4
+)
+
+var (
+	ErrBlockedAddress = errors.New("refusing to fetch non-public address")
+
+	articleHTTPClient = newPublicHTTPClient(20 * time.Second)
+)
+
+// blockedNetworks 拒绝访问的地址段：共享地址、保留地址和 IPv6 唯一本地地址等
+var blockedNetworks = func() []netip.Prefix {
+	var list []netip.Prefix
+	for _, cidr := range []string{
+		"0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4",
+		"64:ff9b::/96", "fc00::/7", "2001:db8::/32",
+	} {
+		list = append(list, netip.MustParsePrefix(cidr))
+	}
+	return list
+}()
+
+// isPublicIP 是否为公网地址；回环、私有、链路本地（含 169.254.169.254 元数据地址）、组播和未指定地址均不是
+func isPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range blockedNetworks {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newPublicHTTPClient 只连接公网地址的客户端：在 DNS 解析后的拨号阶段校验目标 IP，重定向时同样生效
+func newPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicIP(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // 经代理访问时拨号只能看到代理地址
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to %s", ErrBlockedAddress, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// Article 从网页中抽取的正文
+type Article struct {
+	Title     string
+	Content   string
+	WordCount int
+}
+
+type ArticleFetchFunc func(url string) (*Article, error)
+
+// FetchArticle 下载网页并抽取正文，编码按 Content-Type 和 meta 声明自动识别（支持 GBK/GB2312）
+func FetchArticle(url string) (*Article, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+
+	resp, err := articleHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("fetch failed with status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+	return ExtractArticle(io.LimitReader(resp.Body, maxArticleBodyBytes), contentType)
+}
+
+var (
+	positiveHint = regexp.MustCompile(`(?i)article|body|content|entry|main|post|text|story|detail|zhengwen|正文`)
+	negativeHint = regexp.MustCompile(`(?i)comment|footer|sidebar|\bnav|menu|share|social|advert|\bads?\b|\bad-|banner|related|recommend|hotnews|copyright|breadcrumb|login|toolbar|popup`)
+	unlikelyTags = map[atom.Atom]bool{
+		atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+		atom.Form: true, atom.Nav: true, atom.Header: true, atom.Footer: true,
+		atom.Aside: true, atom.Svg: true, atom.Button: true, atom.Select: true,
+		atom.Input: true, atom.Textarea: true, atom.Head: true,
+	}
+	blockTags = map[atom.Atom]bool{
+		atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+		atom.Li: true, atom.Blockquote: true, atom.Pre: true, atom.Div: true,
+		atom.Section: true, atom.Article: true, atom.Br: true, atom.Tr: true,
+	}
+)
+
+// ExtractArticle 基于 readability 思路抽取正文：去除模板区块，按段落文本量给父节点打分，选出得分最高的容器
+func ExtractArticle(r io.Reader, contentType string) (*Article, error) {
+	reader, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	title := findTitle(doc)
+	removeUnlikely(doc)
+
+	scores := map[*html.Node]float64{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote) {
+			text := strings.TrimSpace(nodeText(n))
+			length := utf8.RuneCountInString(text)
+			if length >= 10 && n.Parent != nil {
+				score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")+strings.Count(text, "。"))
+				score += minFloat(float64(length)/100, 3)
+				addScore(scores, n.Parent, score)
+				if n.Parent.Parent != nil {
+					addScore(scores, n.Parent.Parent, score/2)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		best = findFirst(doc, atom.Body)
+	}
+	if best == nil {
+		return nil, errors.New("no content found")
+	}
+
+	content := blockText(best)
+	article := &Article{Title: title, Content: content, WordCount: CountWords(content)}
+	if utf8.RuneCountInString(content) < minArticleRunes {
+		return article, errors.New("content too short")
+	}
+	return article, nil
+}
+
+func addScore(scores map[*html.Node]float64, n *html.Node, score float64) {
+	if _, ok := scores[n]; !ok {
+		scores[n] = classWeight(n)
+		switch n.DataAtom {
+		case atom.Article:
+			scores[n] += 10
+		case atom.Div, atom.Section, atom.Main:
+			scores[n] += 5
+		case atom.Td, atom.Blockquote:
+			scores[n] += 3
+		case atom.Li, atom.Ul, atom.Ol, atom.Form:
+			scores[n] -= 3
+		}
+	}
+	scores[n] += score
+}
+
+func classWeight(n *html.Node) float64 {
+	hint := attr(n, "class") + " " + attr(n, "id")
+	weight := 0.0
+	if positiveHint.MatchString(hint) {
+		weight += 25
+	}
+	if negativeHint.MatchString(hint) {
+		weight -= 25
+	}
+	return weight
+}
+
+// removeUnlikely 删除脚本、导航、页脚以及 class/id 明显是模板区块的节点
+func removeUnlikely(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode {
+			hint := attr(c, "class") + " " + attr(c, "id")
+			if unlikelyTags[c.DataAtom] || (c.DataAtom != atom.Body && c.DataAtom != atom.Html && c.DataAtom != atom.Article &&
+				negativeHint.MatchString(hint) && !positiveHint.MatchString(hint)) {
+				n.RemoveChild(c)
+			} else {
+				removeUnlikely(c)
+			}
+		}
+		c = next
+	}
+}
+
+func findTitle(doc *html.Node) string {
+	var ogTitle, title string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.DataAtom == atom.Meta && attr(n, "property") == "og:title" && ogTitle == "" {
+				ogTitle = attr(n, "content")
+			}
+			if n.DataAtom == atom.Title && title == "" {
+				title = nodeText(n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if ogTitle != "" {
+		return strings.TrimSpace(ogTitle)
+	}
+	return strings.TrimSpace(title)
+}
+
+func findFirst(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// linkDensity 链接文字占比，导航和推荐列表的占比通常很高
+func linkDensity(n *html.Node) float64 {
+	total := utf8.RuneCountInString(strings.TrimSpace(nodeText(n)))
+	if total == 0 {
+		return 1
+	}
+	linked := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			linked += utf8.RuneCountInString(strings.TrimSpace(nodeText(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(linked) / float64(total)
+}
+
+// blockText 按块级元素换行输出纯文本，合并多余空白
+func blockText(n *html.Node) string {
+	var lines []string
+	var current strings.Builder
+	flush := func() {
+		line := strings.Join(strings.Fields(current.String()), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+		current.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			current.WriteString(n.Data)
+			return
+		}
+		isBlock := n.Type == html.ElementNode && blockTags[n.DataAtom]
+		if isBlock {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if isBlock {
+			flush()
+		}
+	}
+	walk(n)
+	flush()
+	return strings.Join(lines, "\n\n")
+}
+
+// CountWords 统计字数：中日韩文字每字计一，其他语言按空白和标点分词
+func CountWords(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ExtractBatchArticles 并发抓取批次内新闻的原文并写入正文和字数，失败的条目保留原内容
+func ExtractBatchArticles(db *gorm.DB, batchID uint, fetch ArticleFetchFunc, jobs int) {
+	if db == nil {
+		return
+	}
+	if fetch == nil {
+		fetch = FetchArticle
+	}
+	if jobs <= 0 {
+		jobs = defaultArticleJobs
+	}
+
+	var rows []models.NewsItem
+	if err := db.Where("batch_id = ? AND url <> ''", batchID).Find(&rows).Error; err != nil {
+		fmt.Printf("读取批次新闻失败: %v\n", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for _, row := range rows {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(row models.NewsItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := ExtractNewsItem(db, row, fetch); err != nil {
+				fmt.Printf("抽取正文失败 %s: %v\n", row.Url, err)
+			}
+		}(row)
+	}
+	wg.Wait()
+}
+
+// ExtractNewsItem 抓取单条新闻原文并更新
+func ExtractNewsItem(db *gorm.DB, row models.NewsItem, fetch ArticleFetchFunc) error {
+	if fetch == nil {
+		fetch = FetchArticle
+	}
+	now := time.Now()
+	article, err := fetch(row.Url)
+	if err != nil {
+		db.Model(&models.NewsItem{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"extracted_at":   now,
+			"extract_status": "error",
+		})
+		return err
+	}
+	return db.Model(&models.NewsItem{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+		"content":        article.Content,
+		"word_count":     article.WordCount,
+		"extracted_at":   now,
+		"extract_status": "ok",
+	}).Error
+}
+
+// newsExcerpt 取正文开头作为分析上下文，正文未抽取时退回标题
+func newsExcerpt(n models.NewsItem, maxRunes int) string {
+	text := n.Summary
+	if n.ExtractStatus == "ok" && n.Content != "" {
+		text = n.Content
+	}
+	if text == "" {
+		return n.Title
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return n.Title + "：" + text
+	}
+	runes := []rune(text)
+	return n.Title + "：" + string(runes[:maxRunes]) + "…"
+}
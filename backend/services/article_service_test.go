@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// serveTestdata 在本地以指定 Content-Type 提供 testdata 下的 HTML 样例
+func serveTestdata(t *testing.T, contentType string) *httptest.Server {
+	t.Helper()
+	fs := http.FileServer(http.Dir("testdata"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		fs.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	// 测试服务器在回环地址上，临时换用不做地址校验的客户端
+	client := articleHTTPClient
+	articleHTTPClient = srv.Client()
+	t.Cleanup(func() { articleHTTPClient = client })
+	return srv
+}
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":          true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+		"::1":              false,
+		"::":               false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	}
+	for addr, want := range cases {
+		if got := isPublicIP(netip.MustParseAddr(addr)); got != want {
+			t.Fatalf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestFetchArticleBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("loopback server should not be reached")
+	}))
+	defer srv.Close()
+	if _, err := FetchArticle(srv.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestFetchArticleRemovesBoilerplate(t *testing.T) {
+	srv := serveTestdata(t, "text/html; charset=utf-8")
+	article, err := FetchArticle(srv.URL + "/article_utf8.html")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if article.Title != "央行宣布全面降准 0.5 个百分点" {
+		t.Fatalf("unexpected title: %q", article.Title)
+	}
+	for _, want := range []string{"释放长期资金约 1 万亿元", "银行板块有望率先受益"} {
+		if !strings.Contains(article.Content, want) {
+			t.Fatalf("content missing %q:\n%s", want, article.Content)
+		}
+	}
+	for _, unwanted := range []string{"tracking", "首页", "油价连续三日上涨", "网友评论", "版权所有", "编辑注释"} {
+		if strings.Contains(article.Content, unwanted) {
+			t.Fatalf("content contains boilerplate %q:\n%s", unwanted, article.Content)
+		}
+	}
+	if article.WordCount < 100 {
+		t.Fatalf("unexpected word count %d", article.WordCount)
+	}
+}
+
+func TestFetchArticleDetectsGBK(t *testing.T) {
+	// 未在 Content-Type 中声明编码，需要从 meta 中识别 gb2312
+	srv := serveTestdata(t, "text/html")
+	article, err := FetchArticle(srv.URL + "/article_gbk.html")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if article.Title != "沪指收涨 1.2% 成交额突破万亿" || !strings.Contains(article.Content, "北向资金净流入") {
+		t.Fatalf("gbk not decoded: %q\n%s", article.Title, article.Content)
+	}
+	if strings.Contains(article.Content, "风险自担") {
+		t.Fatalf("footer not removed:\n%s", article.Content)
+	}
+}
+
+func TestFetchArticleRejectsNonHTML(t *testing.T) {
+	srv := serveTestdata(t, "application/pdf")
+	if _, err := FetchArticle(srv.URL + "/article_utf8.html"); err == nil {
+		t.Fatal("expected content type error")
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	if n := CountWords("央行降准 50 个基点, Fed cuts rates"); n != 11 {
+		t.Fatalf("expected 11 words, got %d", n)
+	}
+}
+
+func TestNewsExcerpt(t *testing.T) {
+	n := models.NewsItem{Title: "标题", Content: "标题"}
+	if got := newsExcerpt(n, 5); got != "标题" {
+		t.Fatalf("unexpected excerpt %q", got)
+	}
+	n.Content = "一二三四五六七"
+	n.ExtractStatus = "ok"
+	if got := newsExcerpt(n, 5); got != "标题：一二三四五…" {
+		t.Fatalf("unexpected excerpt %q", got)
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// auditTarget 管理端资源路径对应的审计目标
+type auditTarget struct {
+	name     string
+	model    func() interface{}
+	preload  []string
+	keyParam string // 以字符串主键定位记录时的路由参数，如登录锁定的 key
+	fixedKey string // 全局设置这类单条记录的固定主键
+}
+
+// auditTargets 路径中 /admin/ 后的第一段 -> 审计目标
+var auditTargets = map[string]auditTarget{
+	"users":           {"user", func() interface{} { return &models.AdminUser{} }, nil, "", ""},
+	"site-categories": {"site_category", func() interface{} { return &models.SiteCategory{} }, nil, "", ""},
+	"sites":           {"site", func() interface{} { return &models.SiteItem{} }, nil, "", ""},
+	"news-sources":    {"news_source", func() interface{} { return &models.NewsSource{} }, nil, "", ""},
+	"batches":         {"batch", func() interface{} { return &models.BatchLog{} }, nil, "", ""},
+	"news":            {"news", func() interface{} { return &models.NewsItem{} }, []string{"Tags"}, "", ""},
+	"tags":            {"tag", func() interface{} { return &models.Tag{} }, nil, "", ""},
+	"analysis":        {"analysis", func() interface{} { return &models.Analysis{} }, nil, "", ""},
+	"api-keys":        {"api_key", func() interface{} { return &models.APIKey{} }, nil, "", ""},
+	"login-locks":     {"login_lock", func() interface{} { return &models.LoginLock{} }, nil, "key", ""},
+	"security-policy": {"security_policy", func() interface{} { return &models.AdminSetting{} }, nil, "", settingRequire2FA},
+}
+
+// auditIgnoredFields 不参与对比的字段
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// AuditChange 单个字段的变化
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditAction 根据请求方法和路由（如 /api/admin/batches/:id/review）解析审计目标和动作，
+// 返回 batch、batch.review；不属于已知资源的路由以路径作为动作，如 trigger-update、me.sessions.delete，
+// 路由参数的实际值由 AuditTargetKey 记录
+func AuditAction(method, fullPath string) (targetType, action string) {
+	parts := auditRouteParts(fullPath)
+	verb := ""
+	if last := parts[len(parts)-1]; len(parts) > 1 && !strings.HasPrefix(last, ":") {
+		verb = last
+	} else {
+		switch method {
+		case "POST":
+			verb = "create"
+		case "PUT", "PATCH":
+			verb = "update"
+		case "DELETE":
+			verb = "delete"
+		default:
+			verb = strings.ToLower(method)
+		}
+	}
+
+	target, ok := auditTargets[parts[0]]
+	if !ok {
+		var static []string
+		for _, p := range parts {
+			if !strings.HasPrefix(p, ":") {
+				static = append(static, p)
+			}
+		}
+		if len(static) < len(parts) && strings.HasPrefix(parts[len(parts)-1], ":") {
+			static = append(static, verb)
+		}
+		return "", strings.Join(static, ".")
+	}
+	return target.name, target.name + "." + verb
+}
+
+// AuditTargetKey 返回不以数字 ID 定位的审计目标主键：登录锁定取路由中的 key，全局设置取固定主键，
+// 未知资源取路由参数的实际值（多个以逗号分隔）；param 按名称读取路由参数
+func AuditTargetKey(fullPath string, param func(name string) string) string {
+	parts := auditRouteParts(fullPath)
+	if target, ok := auditTargets[parts[0]]; ok {
+		if target.fixedKey != "" {
+			return target.fixedKey
+		}
+		if target.keyParam != "" {
+			return param(target.keyParam)
+		}
+		return ""
+	}
+	var values []string
+	for _, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			values = append(values, param(p[1:]))
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// auditRouteParts 取路由中 /admin/ 之后的各段
+func auditRouteParts(fullPath string) []string {
+	rest := fullPath
+	if i := strings.Index(rest, "/admin/"); i >= 0 {
+		rest = rest[i+len("/admin/"):]
+	}
+	return strings.Split(strings.Trim(rest, "/"), "/")
+}
+
+// LoadAuditSnapshot 读取审计目标的当前记录并转为 JSON 对象，key 非空时按字符串主键读取，记录不存在时返回 nil
+func LoadAuditSnapshot(db *gorm.DB, targetType string, id uint, key string) (map[string]interface{}, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var target *auditTarget
+	for _, t := range auditTargets {
+		if t.name == targetType {
+			t := t
+			target = &t
+			break
+		}
+	}
+	if target == nil || (id == 0 && key == "") {
+		return nil, nil
+	}
+	row := target.model()
+	q := db
+	for _, p := range target.preload {
+		q = q.Preload(p)
+	}
+	var err error
+	if key != "" {
+		err = q.Where(clause.Eq{Column: clause.PrimaryColumn, Value: key}).First(row).Error
+	} else {
+		err = q.First(row, id).Error
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DiffSnapshots 对比操作前后的记录，新增时 before 为 nil，删除时 after 为 nil
+func DiffSnapshots(before, after map[string]interface{}) map[string]AuditChange {
+	changes := map[string]AuditChange{}
+	for key, b := range before {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		a, ok := after[key]
+		if !ok || !reflect.DeepEqual(a, b) {
+			changes[key] = AuditChange{Before: b, After: a}
+		}
+	}
+	for key, a := range after {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if _, ok := before[key]; !ok {
+			changes[key] = AuditChange{After: a}
+		}
+	}
+	return changes
+}
+
+// RecordAudit 保存审计记录，before / after 为 nil 时对应字段留空
+func RecordAudit(db *gorm.DB, entry models.AuditLog, before, after map[string]interface{}) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if before != nil {
+		data, _ := json.Marshal(before)
+		entry.Before = string(data)
+	}
+	if after != nil {
+		data, _ := json.Marshal(after)
+		entry.After = string(data)
+	}
+	if before != nil || after != nil {
+		if changes := DiffSnapshots(before, after); len(changes) > 0 {
+			data, _ := json.Marshal(changes)
+			entry.Changes = string(data)
+		}
+	}
+	entry.UserAgent = truncateText(entry.UserAgent, 255)
+	entry.Path = truncateText(entry.Path, 255)
+	return db.Create(&entry).Error
+}
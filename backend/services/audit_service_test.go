@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestAuditAction(t *testing.T) {
+	cases := []struct {
+		method, path       string
+		targetType, action string
+	}{
+		{"DELETE", "/api/admin/batches/:id", "batch", "batch.delete"},
+		{"POST", "/api/admin/batches/:id/review", "batch", "batch.review"},
+		{"POST", "/api/admin/news", "news", "news.create"},
+		{"PATCH", "/api/admin/news/:id", "news", "news.update"},
+		{"PATCH", "/api/admin/users/:id/role", "user", "user.role"},
+		{"POST", "/api/admin/trigger-update", "", "trigger-update"},
+		{"PUT", "/api/admin/security-policy", "security_policy", "security_policy.update"},
+		{"DELETE", "/api/admin/login-locks/:key", "login_lock", "login_lock.delete"},
+		{"DELETE", "/api/admin/me/sessions/:id", "", "me.sessions.delete"},
+		{"DELETE", "/api/admin/me/sessions", "", "me.sessions"},
+	}
+	for _, tc := range cases {
+		targetType, action := AuditAction(tc.method, tc.path)
+		if targetType != tc.targetType || action != tc.action {
+			t.Fatalf("AuditAction(%s %s) = %s, %s; want %s, %s", tc.method, tc.path, targetType, action, tc.targetType, tc.action)
+		}
+	}
+}
+
+func TestAuditTargetKey(t *testing.T) {
+	params := map[string]string{"key": "ip:203.0.113.9", "id": "a1b2c3"}
+	param := func(name string) string { return params[name] }
+	cases := map[string]string{
+		"/api/admin/login-locks/:key": "ip:203.0.113.9",
+		"/api/admin/security-policy":  settingRequire2FA,
+		"/api/admin/me/sessions/:id":  "a1b2c3",
+		"/api/admin/batches/:id":      "",
+		"/api/admin/trigger-update":   "",
+	}
+	for path, want := range cases {
+		if got := AuditTargetKey(path, param); got != want {
+			t.Fatalf("AuditTargetKey(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before := map[string]interface{}{"id": 1.0, "title": "旧标题", "pinned": false, "updated_at": "a"}
+	after := map[string]interface{}{"id": 1.0, "title": "新标题", "pinned": false, "updated_at": "b", "featured": true}
+	changes := DiffSnapshots(before, after)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if c := changes["title"]; c.Before != "旧标题" || c.After != "新标题" {
+		t.Fatalf("unexpected title change %+v", c)
+	}
+	if c := changes["featured"]; c.Before != nil || c.After != true {
+		t.Fatalf("unexpected featured change %+v", c)
+	}
+
+	deleted := DiffSnapshots(before, nil)
+	if len(deleted) != 3 || deleted["title"].After != nil {
+		t.Fatalf("delete should list every field, got %+v", deleted)
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CORSPolicy 一组接口的跨域策略，方法和请求头均已规范化
+type CORSPolicy struct {
+	AllowAnyOrigin   bool
+	Origins          []string // 精确匹配的来源，scheme://host[:port]，小写
+	WildcardOrigins  []string // 通配子域名，保存为 scheme://.example.com[:port] 形式的后缀
+	Methods          []string
+	Headers          []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var (
+	defaultPublicCORS = config.CORSPolicyConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "HEAD", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Accept-Language", "Cache-Control", "X-Requested-With"},
+	}
+	defaultAdminCORS = config.CORSPolicyConfig{
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization", "X-API-Key", "Cache-Control", "X-Requested-With"},
+	}
+)
+
+// normalizeOrigin 规范化来源为小写的 scheme://host[:port]，省略默认端口；无法解析时返回空
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return ""
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	return scheme + "://" + host
+}
+
+// NewCORSPolicy 由配置生成策略，未配置的方法和请求头使用 defaults；* 与凭据同时配置时关闭凭据
+func NewCORSPolicy(cfg, defaults config.CORSPolicyConfig) CORSPolicy {
+	if cfg.AllowedOrigins == nil {
+		cfg.AllowedOrigins = defaults.AllowedOrigins
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = defaults.AllowedMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = defaults.AllowedHeaders
+	}
+
+	p := CORSPolicy{AllowCredentials: cfg.AllowCredentials, MaxAge: 24 * time.Hour}
+	if cfg.MaxAgeSeconds > 0 {
+		p.MaxAge = time.Duration(cfg.MaxAgeSeconds) * time.Second
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		switch {
+		case origin == "*":
+			p.AllowAnyOrigin = true
+		case strings.Contains(origin, "://*."):
+			// https://*.example.com 先按 https://x.example.com 规范化，再去掉占位的 x
+			normalized := normalizeOrigin(strings.Replace(origin, "://*.", "://x.", 1))
+			if normalized == "" {
+				fmt.Printf("忽略无效的跨域来源: %s\n", origin)
+				continue
+			}
+			p.WildcardOrigins = append(p.WildcardOrigins, strings.Replace(normalized, "://x.", "://.", 1))
+		default:
+			normalized := normalizeOrigin(origin)
+			if normalized == "" {
+				fmt.Printf("忽略无效的跨域来源: %s\n", origin)
+				continue
+			}
+			p.Origins = append(p.Origins, normalized)
+		}
+	}
+	if p.AllowAnyOrigin && p.AllowCredentials {
+		fmt.Println("跨域策略同时配置了 * 和 allow_credentials，已关闭凭据")
+		p.AllowCredentials = false
+	}
+	for _, m := range cfg.AllowedMethods {
+		if m = strings.ToUpper(strings.TrimSpace(m)); m != "" && m != http.MethodOptions {
+			p.Methods = append(p.Methods, m)
+		}
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h = http.CanonicalHeaderKey(strings.TrimSpace(h)); h != "" {
+			p.Headers = append(p.Headers, h)
+		}
+	}
+	return p
+}
+
+// CurrentCORSPolicies 读取配置中的公开接口和管理端接口跨域策略
+func CurrentCORSPolicies() (public, admin CORSPolicy) {
+	cfg := config.AppConfig.CORS
+	return NewCORSPolicy(cfg.Public, defaultPublicCORS), NewCORSPolicy(cfg.Admin, defaultAdminCORS)
+}
+
+// AllowOrigin 来源是否被允许；通配子域名不匹配裸域名本身
+func (p CORSPolicy) AllowOrigin(origin string) bool {
+	if p.AllowAnyOrigin {
+		return true
+	}
+	normalized := normalizeOrigin(origin)
+	if normalized == "" {
+		return false
+	}
+	for _, o := range p.Origins {
+		if o == normalized {
+			return true
+		}
+	}
+	scheme, host, _ := strings.Cut(normalized, "://")
+	for _, w := range p.WildcardOrigins {
+		wScheme, suffix, _ := strings.Cut(w, "://")
+		// suffix 形如 .example.com[:port]，要求 host 在点号前至少还有一段
+		if wScheme == scheme && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMethod 预检请求的方法是否被允许
+func (p CORSPolicy) AllowMethod(method string) bool {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowHeaders 预检请求的 Access-Control-Request-Headers 是否全部被允许
+func (p CORSPolicy) AllowHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		allowed := false
+		for _, a := range p.Headers {
+			if strings.EqualFold(a, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
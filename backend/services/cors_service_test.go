@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicyAllowOrigin(t *testing.T) {
+	p := NewCORSPolicy(config.CORSPolicyConfig{
+		AllowedOrigins: []string{"https://Admin.Example.com", "https://*.wsky.fun", "http://localhost:5173", "not a url"},
+	}, defaultAdminCORS)
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://admin.example.com", true},
+		{"https://admin.example.com:443", true}, // 默认端口
+		{"http://admin.example.com", false},     // scheme 不同
+		{"https://evil.example.com", false},
+		{"https://cj.wsky.fun", true},
+		{"https://a.b.wsky.fun", true},
+		{"https://wsky.fun", false},     // 通配不含裸域名
+		{"https://evilwsky.fun", false}, // 仅后缀相同
+		{"https://cj.wsky.fun:8443", false},
+		{"http://localhost:5173", true},
+		{"http://localhost:3000", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := p.AllowOrigin(tc.origin); got != tc.want {
+			t.Fatalf("AllowOrigin(%q) = %v, want %v", tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestNewCORSPolicyDefaults(t *testing.T) {
+	admin := NewCORSPolicy(config.CORSPolicyConfig{}, defaultAdminCORS)
+	if admin.AllowAnyOrigin || admin.AllowOrigin("https://admin.example.com") {
+		t.Fatal("admin policy without origins should only allow same-origin requests")
+	}
+	if admin.MaxAge != 24*time.Hour || !admin.AllowMethod("patch") || admin.AllowMethod("TRACE") {
+		t.Fatalf("unexpected admin defaults %+v", admin)
+	}
+	if !admin.AllowHeaders("authorization, content-type") || admin.AllowHeaders("Authorization, X-Debug") {
+		t.Fatal("request headers should be matched case-insensitively against the allow list")
+	}
+
+	public := NewCORSPolicy(config.CORSPolicyConfig{AllowCredentials: true, MaxAgeSeconds: 600}, defaultPublicCORS)
+	if !public.AllowAnyOrigin || !public.AllowOrigin("https://anything.example") {
+		t.Fatal("public policy should allow any origin by default")
+	}
+	if public.AllowCredentials {
+		t.Fatal("credentials must be disabled when any origin is allowed")
+	}
+	if public.MaxAge != 10*time.Minute || public.AllowMethod("DELETE") {
+		t.Fatalf("unexpected public policy %+v", public)
+	}
+}
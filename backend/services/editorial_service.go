@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var ErrNewsNotInBatch = errors.New("news not in batch")
+
+// EditorialOrder 计算批次内新闻的新顺序：ids 中的新闻按给定顺序排在前面，
+// 其余保持原有顺序，最后将置顶新闻整体移到最前。items 需已按当前顺序排列
+func EditorialOrder(items []models.NewsItem, ids []uint) ([]uint, error) {
+	byID := make(map[uint]models.NewsItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	listed := map[uint]bool{}
+	var ordered []models.NewsItem
+	for _, id := range ids {
+		item, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrNewsNotInBatch, id)
+		}
+		if listed[id] {
+			continue
+		}
+		listed[id] = true
+		ordered = append(ordered, item)
+	}
+	for _, item := range items {
+		if !listed[item.ID] {
+			ordered = append(ordered, item)
+		}
+	}
+
+	out := make([]uint, 0, len(ordered))
+	for _, pinned := range []bool{true, false} {
+		for _, item := range ordered {
+			if item.Pinned == pinned {
+				out = append(out, item.ID)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ReorderBatchNews 按 ids 重排批次内新闻，未列出的新闻排在后面，置顶新闻始终在最前
+func ReorderBatchNews(db *gorm.DB, batchID uint, ids []uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var items []models.NewsItem
+		if err := tx.Select("id, pinned, sort_rank").Where("batch_id = ?", batchID).Order("sort_rank asc, id asc").Find(&items).Error; err != nil {
+			return err
+		}
+		order, err := EditorialOrder(items, ids)
+		if err != nil {
+			return err
+		}
+		return saveSortRanks(tx, items, order)
+	})
+}
+
+// RenumberBatchNews 重新编号批次内新闻的排序，置顶状态变化或新增新闻后调用
+func RenumberBatchNews(db *gorm.DB, batchID uint) error {
+	return ReorderBatchNews(db, batchID, nil)
+}
+
+// NextSortRank 返回批次内下一条新闻的排序值，新增新闻排在最后
+func NextSortRank(db *gorm.DB, batchID uint) (int, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	var maxRank int
+	if err := db.Model(&models.NewsItem{}).Where("batch_id = ?", batchID).Select("COALESCE(MAX(sort_rank), 0)").Scan(&maxRank).Error; err != nil {
+		return 0, err
+	}
+	return maxRank + 1, nil
+}
+
+// saveSortRanks 只更新排序值有变化的新闻
+func saveSortRanks(tx *gorm.DB, items []models.NewsItem, order []uint) error {
+	current := make(map[uint]int, len(items))
+	for _, item := range items {
+		current[item.ID] = item.SortRank
+	}
+	for i, id := range order {
+		if current[id] == i+1 {
+			continue
+		}
+		if err := tx.Model(&models.NewsItem{}).Where("id = ?", id).UpdateColumn("sort_rank", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
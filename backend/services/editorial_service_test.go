@@ -0,0 +1,29 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEditorialOrder(t *testing.T) {
+	items := []models.NewsItem{{ID: 1}, {ID: 2}, {ID: 3, Pinned: true}, {ID: 4}, {ID: 5}}
+
+	got, err := EditorialOrder(items, []uint{5, 1, 5})
+	if err != nil {
+		t.Fatalf("order: %v", err)
+	}
+	if want := []uint{3, 5, 1, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	got, _ = EditorialOrder(items, nil)
+	if want := []uint{3, 1, 2, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("renumber got %v, want %v", got, want)
+	}
+
+	if _, err := EditorialOrder(items, []uint{1, 9}); !errors.Is(err, ErrNewsNotInBatch) {
+		t.Fatalf("expected ErrNewsNotInBatch, got %v", err)
+	}
+}
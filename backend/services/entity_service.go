@@ -0,0 +1,304 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EntityData 从新闻中抽取出的实体
+type EntityData struct {
+	Type models.EntityType `json:"type"`
+	Name string            `json:"name"`
+}
+
+// ExtractEntitiesFunc 为每条新闻返回实体列表，下标与 items 一致
+type ExtractEntitiesFunc func(items []models.NewsItem) ([][]EntityData, error)
+
+// EntityCount 一段时间内被提及的实体及提及新闻数
+type EntityCount struct {
+	models.Entity
+	Mentions int64 `json:"mentions"`
+}
+
+var entityTypes = map[models.EntityType]bool{
+	models.EntityCompany:   true,
+	models.EntityTicker:    true,
+	models.EntityCountry:   true,
+	models.EntityPerson:    true,
+	models.EntityCommodity: true,
+}
+
+// IsEntityType 判断是否为支持的实体类型
+func IsEntityType(t string) bool {
+	return entityTypes[models.EntityType(t)]
+}
+
+// ExtractEntities 调用 AI 抽取新闻中的公司、股票代码、国家、人物和大宗商品
+func ExtractEntities(items []models.NewsItem) ([][]EntityData, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	var sb strings.Builder
+	for i, item := range items {
+		sb.WriteString(fmt.Sprintf("[%d] %s", i, item.Title))
+		if item.Summary != "" {
+			sb.WriteString(" | 摘要: " + truncateText(item.Summary, 300))
+		}
+		sb.WriteString("\n")
+	}
+	prompt := fmt.Sprintf(`请从以下每条财经新闻中抽取实体，类型只能是 company（公司）、ticker（股票代码）、country（国家或地区）、person（人物）、commodity（大宗商品）。
+名称使用新闻中最常用的中文简称，股票代码使用 600519.SH、0700.HK、AAPL 这样的格式，没有实体时返回空数组。
+请严格按照 JSON 对象数组格式输出，每个对象包含 index（新闻编号）和 entities（由 type 和 name 组成的对象数组）字段，不要包含 Markdown 标记或其他多余文字。
+例如：[{"index": 0, "entities": [{"type": "company", "name": "贵州茅台"}, {"type": "ticker", "name": "600519.SH"}]}]
+新闻列表：
+%s`, sb.String())
+	log.Printf("AI Prompt: %s", prompt)
+
+	response, err := CallAI(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseEntityResponse(response, len(items))
+}
+
+// parseEntityResponse 解析 AI 抽取结果，丢弃越界编号和未知类型
+func parseEntityResponse(response string, n int) ([][]EntityData, error) {
+	cleanResponse := strings.TrimSpace(response)
+	start := strings.Index(cleanResponse, "[")
+	end := strings.LastIndex(cleanResponse, "]")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = cleanResponse[start : end+1]
+	}
+	var extracted []struct {
+		Index    int          `json:"index"`
+		Entities []EntityData `json:"entities"`
+	}
+	if err := json.Unmarshal([]byte(cleanResponse), &extracted); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %v. Response: %s", err, response)
+	}
+
+	out := make([][]EntityData, n)
+	for _, e := range extracted {
+		if e.Index < 0 || e.Index >= n {
+			continue
+		}
+		out[e.Index] = mergeEntities(out[e.Index], e.Entities)
+	}
+	return out, nil
+}
+
+// normalizeEntity 统一实体名称写法，非法实体返回 false
+func normalizeEntity(e EntityData) (EntityData, bool) {
+	e.Type = models.EntityType(strings.ToLower(strings.TrimSpace(string(e.Type))))
+	e.Name = strings.Join(strings.Fields(e.Name), " ")
+	if !entityTypes[e.Type] || e.Name == "" || utf8.RuneCountInString(e.Name) > 64 {
+		return e, false
+	}
+	if e.Type == models.EntityTicker {
+		e.Name = strings.ToUpper(strings.TrimPrefix(e.Name, "$"))
+	}
+	return e, true
+}
+
+// mergeEntities 追加实体并去重
+func mergeEntities(list []EntityData, more []EntityData) []EntityData {
+	for _, e := range more {
+		e, ok := normalizeEntity(e)
+		if !ok {
+			continue
+		}
+		dup := false
+		for _, existing := range list {
+			if existing.Type == e.Type && strings.EqualFold(existing.Name, e.Name) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+var (
+	cnTickerPattern = regexp.MustCompile(`\b\d{6}\.(?:SH|SZ|BJ)\b`)
+	hkTickerPattern = regexp.MustCompile(`\b\d{4,5}\.HK\b`)
+	usTickerPattern = regexp.MustCompile(`(?:\$|(?:NASDAQ|NYSE)[:：]\s*)([A-Z]{1,5})\b`)
+)
+
+// 规则抽取使用的词典：出现的词 -> 实体名称
+var (
+	countryAliases = map[string]string{
+		"中国": "中国", "美国": "美国", "日本": "日本", "韩国": "韩国", "德国": "德国", "法国": "法国",
+		"英国": "英国", "俄罗斯": "俄罗斯", "印度": "印度", "沙特": "沙特阿拉伯", "伊朗": "伊朗",
+		"以色列": "以色列", "乌克兰": "乌克兰", "加拿大": "加拿大", "澳大利亚": "澳大利亚",
+		"巴西": "巴西", "越南": "越南", "墨西哥": "墨西哥", "欧元区": "欧元区",
+	}
+	commodityAliases = map[string]string{
+		"原油": "原油", "油价": "原油", "黄金": "黄金", "金价": "黄金", "白银": "白银", "铜价": "铜",
+		"铁矿石": "铁矿石", "煤炭": "煤炭", "天然气": "天然气", "大豆": "大豆", "玉米": "玉米", "碳酸锂": "碳酸锂",
+	}
+)
+
+// ExtractEntitiesByRules 按股票代码格式和国家、大宗商品词典抽取实体，AI 不可用时兜底
+func ExtractEntitiesByRules(item models.NewsItem) []EntityData {
+	text := item.Title + " " + item.Summary
+	var out []EntityData
+	for _, m := range cnTickerPattern.FindAllString(text, -1) {
+		out = mergeEntities(out, []EntityData{{Type: models.EntityTicker, Name: m}})
+	}
+	for _, m := range hkTickerPattern.FindAllString(text, -1) {
+		out = mergeEntities(out, []EntityData{{Type: models.EntityTicker, Name: m}})
+	}
+	for _, m := range usTickerPattern.FindAllStringSubmatch(text, -1) {
+		out = mergeEntities(out, []EntityData{{Type: models.EntityTicker, Name: m[1]}})
+	}
+	for _, dict := range []struct {
+		t       models.EntityType
+		aliases map[string]string
+	}{{models.EntityCountry, countryAliases}, {models.EntityCommodity, commodityAliases}} {
+		aliases := make([]string, 0, len(dict.aliases))
+		for alias := range dict.aliases {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			if strings.Contains(text, alias) {
+				out = mergeEntities(out, []EntityData{{Type: dict.t, Name: dict.aliases[alias]}})
+			}
+		}
+	}
+	return out
+}
+
+// upsertEntity 按类型和名称查找实体，不存在时创建
+func upsertEntity(db *gorm.DB, e EntityData) (uint, error) {
+	var row models.Entity
+	err := db.Where("type = ? AND name = ?", e.Type, e.Name).First(&row).Error
+	if err == nil {
+		return row.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	row = models.Entity{Type: e.Type, Name: e.Name}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 并发创建，重新读取
+		if err := db.Where("type = ? AND name = ?", e.Type, e.Name).First(&row).Error; err != nil {
+			return 0, err
+		}
+	}
+	return row.ID, nil
+}
+
+func addMention(db *gorm.DB, entityID, newsID, analysisID uint) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.EntityMention{
+		EntityID:   entityID,
+		NewsItemID: newsID,
+		AnalysisID: analysisID,
+	}).Error
+}
+
+// ExtractBatchEntities 抽取批次内新闻的实体并记录提及关系；AI 结果与规则结果合并
+func ExtractBatchEntities(db *gorm.DB, batchID uint, extract ExtractEntitiesFunc) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if extract == nil {
+		extract = ExtractEntities
+	}
+	var items []models.NewsItem
+	if err := db.Where("batch_id = ?", batchID).Order("id asc").Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	aiEntities, err := extract(items)
+	if err != nil {
+		fmt.Printf("AI 实体抽取失败，仅使用规则: %v\n", err)
+		aiEntities = nil
+	}
+	for i, item := range items {
+		var entities []EntityData
+		if i < len(aiEntities) {
+			entities = mergeEntities(entities, aiEntities[i])
+		}
+		entities = mergeEntities(entities, ExtractEntitiesByRules(item))
+		for _, e := range entities {
+			entityID, err := upsertEntity(db, e)
+			if err != nil {
+				return err
+			}
+			if err := addMention(db, entityID, item.ID, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LinkAnalysisEntities 将分析正文中出现的近期实体记录为提及，返回关联的实体数
+func LinkAnalysisEntities(db *gorm.DB, analysis models.Analysis, since time.Time) (int, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	var entities []models.Entity
+	recent := db.Model(&models.EntityMention{}).Select("entity_id").Where("news_item_id > 0 AND created_at >= ?", since)
+	if err := db.Where("id IN (?)", recent).Find(&entities).Error; err != nil {
+		return 0, err
+	}
+	content := strings.ToLower(analysis.Content)
+	linked := 0
+	for _, e := range entities {
+		// 单字名称（如 铜）误匹配太多，不参与正文匹配
+		if utf8.RuneCountInString(e.Name) < 2 || !containsKeyword(content, strings.ToLower(e.Name)) {
+			continue
+		}
+		if err := addMention(db, e.ID, 0, analysis.ID); err != nil {
+			return linked, err
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// TrendingEntities 统计 since 之后被最多新闻提及的实体，entityType 为空时不限类型
+func TrendingEntities(db *gorm.DB, since time.Time, entityType string, limit int) ([]EntityCount, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	q := db.Table("entity_mentions").
+		Select("entities.*, COUNT(DISTINCT entity_mentions.news_item_id) AS mentions").
+		Joins("JOIN entities ON entities.id = entity_mentions.entity_id").
+		Joins("JOIN news_items ON news_items.id = entity_mentions.news_item_id AND news_items.deleted_at IS NULL AND news_items.status = ?", models.StatusPublished).
+		Where("entity_mentions.created_at >= ?", since).
+		Group("entities.id").
+		Order("mentions desc, entities.id asc").
+		Limit(limit)
+	if entityType != "" {
+		q = q.Where("entities.type = ?", entityType)
+	}
+	var rows []EntityCount
+	if err := q.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"reflect"
+	"testing"
+)
+
+func TestExtractEntitiesByRules(t *testing.T) {
+	item := models.NewsItem{
+		Title:   "贵州茅台(600519.SH)股价创新高，腾讯控股 0700.HK 回购",
+		Summary: "国际油价走低，美国科技股领涨，NASDAQ: NVDA 收涨 3%，$aapl 小幅回落",
+	}
+	got := ExtractEntitiesByRules(item)
+	want := []EntityData{
+		{Type: models.EntityTicker, Name: "600519.SH"},
+		{Type: models.EntityTicker, Name: "0700.HK"},
+		{Type: models.EntityTicker, Name: "NVDA"},
+		{Type: models.EntityCountry, Name: "美国"},
+		{Type: models.EntityCommodity, Name: "原油"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseEntityResponse(t *testing.T) {
+	response := `以下是结果：[{"index": 0, "entities": [
+		{"type": "Company", "name": " 贵州 茅台 "},
+		{"type": "company", "name": "贵州 茅台"},
+		{"type": "ticker", "name": "$aapl"},
+		{"type": "sector", "name": "白酒"},
+		{"type": "person", "name": ""}
+	]}, {"index": 3, "entities": [{"type": "country", "name": "美国"}]}]`
+	got, err := parseEntityResponse(response, 2)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := [][]EntityData{
+		{{Type: models.EntityCompany, Name: "贵州 茅台"}, {Type: models.EntityTicker, Name: "AAPL"}},
+		nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+
+	// FeedTagAuthority 用于生成稳定的 tag: URI（RFC 4151），不随访问域名变化
+	FeedTagAuthority = "bre-news,2025"
+)
+
+// Feed 与具体格式无关的订阅源；ID 为空时使用 FeedURL，HomeURL、FeedURL 为空时省略对应链接
+type Feed struct {
+	ID          string
+	Title       string
+	Description string
+	Language    string // 内容语言，如 zh、en，为空时为中文
+	HomeURL     string
+	FeedURL     string
+	Items       []FeedItem
+}
+
+type FeedItem struct {
+	GUID      string
+	Title     string
+	Link      string
+	Content   string
+	Category  string
+	Published time.Time
+	Updated   time.Time
+}
+
+// FeedGUID 生成条目的稳定唯一标识，如 tag:bre-news,2025:news/12
+func FeedGUID(kind string, id uint) string {
+	return "tag:" + FeedTagAuthority + ":" + kind + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// feedID 订阅源的唯一标识，未设置 ID 时使用 FeedURL
+func (f *Feed) feedID() string {
+	if f.ID != "" {
+		return f.ID
+	}
+	return f.FeedURL
+}
+
+// languageTag 订阅源声明的语言，zh 对应 zh-CN
+func (f *Feed) languageTag() string {
+	switch f.Language {
+	case "", "zh":
+		return "zh-CN"
+	}
+	return f.Language
+}
+
+// LastModified 返回所有条目中最新的更新时间
+func (f *Feed) LastModified() time.Time {
+	var latest time.Time
+	for _, item := range f.Items {
+		if item.Updated.After(latest) {
+			latest = item.Updated
+		}
+	}
+	return latest
+}
+
+// ETag 由格式、地址以及每个条目的标识和更新时间计算，内容变化时随之变化
+func (f *Feed) ETag(format string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%d", format, f.feedID(), f.Language, len(f.Items))
+	for _, item := range f.Items {
+		fmt.Fprintf(h, "|%s@%d", item.GUID, item.Updated.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// Render 输出指定格式的内容和 Content-Type
+func (f *Feed) Render(format string) ([]byte, string, error) {
+	switch format {
+	case FeedRSS:
+		body, err := f.renderRSS()
+		return body, "application/rss+xml; charset=utf-8", err
+	case FeedAtom:
+		body, err := f.renderAtom()
+		return body, "application/atom+xml; charset=utf-8", err
+	case FeedJSON:
+		body, err := f.renderJSON()
+		return body, "application/feed+json; charset=utf-8", err
+	}
+	return nil, "", fmt.Errorf("unknown feed format: %s", format)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	Language      string      `xml:"language"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	AtomLink      *rssAtomRef `xml:"atom:link,omitempty"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomRef struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (f *Feed) renderRSS() ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			Language:    strings.ToLower(f.languageTag()),
+		},
+	}
+	if f.FeedURL != "" {
+		doc.Channel.AtomLink = &rssAtomRef{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	if lm := f.LastModified(); !lm.IsZero() {
+		doc.Channel.LastBuildDate = lm.Format(time.RFC1123Z)
+	}
+	for _, item := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Content,
+			Category:    item.Category,
+			GUID:        rssGUID{IsPermaLink: "false", Value: item.GUID},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+		})
+	}
+	return marshalXML(doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Updated   string        `xml:"updated"`
+	Published string        `xml:"published"`
+	Links     []atomLink    `xml:"link,omitempty"`
+	Category  *atomCategory `xml:"category,omitempty"`
+	Content   atomText      `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (f *Feed) renderAtom() ([]byte, error) {
+	updated := f.LastModified()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	doc := atomFeed{
+		ID:      f.feedID(),
+		Title:   f.Title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: f.Title},
+	}
+	if f.FeedURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"})
+	}
+	if f.HomeURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.HomeURL, Rel: "alternate", Type: "text/html"})
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			ID:        item.GUID,
+			Title:     item.Title,
+			Updated:   item.Updated.Format(time.RFC3339),
+			Published: item.Published.Format(time.RFC3339),
+			Content:   atomText{Type: "text", Value: item.Content},
+		}
+		if item.Link != "" {
+			entry.Links = []atomLink{{Href: item.Link, Rel: "alternate"}}
+		}
+		if item.Category != "" {
+			entry.Category = &atomCategory{Term: item.Category}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Language    string         `json:"language"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url,omitempty"`
+	Title         string   `json:"title"`
+	ContentText   string   `json:"content_text"`
+	Tags          []string `json:"tags,omitempty"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+}
+
+func (f *Feed) renderJSON() ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Language:    f.languageTag(),
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range f.Items {
+		row := jsonFeedItem{
+			ID:            item.GUID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentText:   item.Content,
+			DatePublished: item.Published.Format(time.RFC3339),
+			DateModified:  item.Updated.Format(time.RFC3339),
+		}
+		if item.Category != "" {
+			row.Tags = []string{item.Category}
+		}
+		doc.Items = append(doc.Items, row)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() *Feed {
+	t1 := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	return &Feed{
+		Title:   "财经热点",
+		HomeURL: "https://example.com/",
+		FeedURL: "https://example.com/api/feeds/news/rss",
+		Items: []FeedItem{
+			{GUID: FeedGUID("news", 2), Title: "油价 & 金价", Link: "https://example.com/b", Content: "油价", Published: t1, Updated: t1.Add(time.Hour)},
+			{GUID: FeedGUID("news", 1), Title: "央行降准", Link: "https://example.com/a", Content: "降准", Published: t1, Updated: t1},
+		},
+	}
+}
+
+func TestFeedRenderFormats(t *testing.T) {
+	feed := testFeed()
+
+	body, contentType, err := feed.Render(FeedRSS)
+	if err != nil || !strings.HasPrefix(contentType, "application/rss+xml") {
+		t.Fatalf("rss: %v %s", err, contentType)
+	}
+	var rss struct {
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title   string `xml:"title"`
+				GUID    string `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		t.Fatalf("parse rss: %v", err)
+	}
+	if len(rss.Channel.Items) != 2 || rss.Channel.Items[0].Title != "油价 & 金价" || rss.Channel.Items[0].GUID != "tag:bre-news,2025:news/2" {
+		t.Fatalf("unexpected rss items: %+v", rss.Channel.Items)
+	}
+	if rss.Channel.Items[0].PubDate != "Fri, 02 Jan 2026 08:00:00 +0000" || rss.Channel.LastBuildDate != "Fri, 02 Jan 2026 09:00:00 +0000" {
+		t.Fatalf("unexpected dates: %+v", rss.Channel)
+	}
+
+	body, _, err = feed.Render(FeedAtom)
+	if err != nil {
+		t.Fatalf("atom: %v", err)
+	}
+	var atom struct {
+		XMLName xml.Name
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID string `xml:"id"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		t.Fatalf("parse atom: %v", err)
+	}
+	if atom.XMLName.Space != "http://www.w3.org/2005/Atom" || atom.Updated != "2026-01-02T09:00:00Z" || len(atom.Entries) != 2 {
+		t.Fatalf("unexpected atom: %+v", atom)
+	}
+
+	body, _, err = feed.Render(FeedJSON)
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var jf struct {
+		Version string `json:"version"`
+		Items   []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &jf); err != nil || jf.Version != "https://jsonfeed.org/version/1.1" || len(jf.Items) != 2 {
+		t.Fatalf("unexpected json feed: %v %+v", err, jf)
+	}
+
+	if _, _, err := feed.Render("txt"); err == nil {
+		t.Fatal("expected unknown format error")
+	}
+}
+
+func TestFeedETagChangesWithContent(t *testing.T) {
+	feed := testFeed()
+	etag := feed.ETag(FeedRSS)
+	if etag != feed.ETag(FeedRSS) || etag == feed.ETag(FeedAtom) {
+		t.Fatal("etag should be stable per format")
+	}
+	feed.Items[1].Updated = feed.Items[1].Updated.Add(time.Minute)
+	if etag == feed.ETag(FeedRSS) {
+		t.Fatal("etag should change when an item is updated")
+	}
+	// 未配置地址时 FeedURL 为空，不同订阅源靠 ID 区分
+	etag = feed.ETag(FeedRSS)
+	other := *feed
+	feed.FeedURL, other.FeedURL = "", ""
+	feed.ID, other.ID = "tag:bre-news,2025:api/feeds/news/rss", "tag:bre-news,2025:api/feeds/news/rss?type=morning"
+	if feed.ETag(FeedRSS) == other.ETag(FeedRSS) {
+		t.Fatal("etag should differ between feeds")
+	}
+	etag = feed.ETag(FeedRSS)
+	feed.Language = "en"
+	if etag == feed.ETag(FeedRSS) {
+		t.Fatal("etag should differ between languages")
+	}
+	if body, _, _ := feed.Render(FeedRSS); !strings.Contains(string(body), "<language>en</language>") {
+		t.Fatalf("rss language not set: %s", body)
+	}
+}
+
+func TestFeedAtomWithoutURLs(t *testing.T) {
+	feed := testFeed()
+	feed.ID, feed.HomeURL, feed.FeedURL = "tag:bre-news,2025:api/feeds/news/atom", "", ""
+	body, _, err := feed.Render(FeedAtom)
+	if err != nil {
+		t.Fatalf("atom: %v", err)
+	}
+	var atom struct {
+		ID    string `xml:"id"`
+		Links []struct {
+			Rel string `xml:"rel,attr"`
+		} `xml:"link"`
+	}
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		t.Fatalf("parse atom: %v", err)
+	}
+	if atom.ID != feed.ID || len(atom.Links) != 0 {
+		t.Fatalf("unexpected atom head: %+v", atom)
+	}
+	if body, _, _ := feed.Render(FeedRSS); strings.Contains(string(body), `rel="self"`) {
+		t.Fatalf("rss should omit empty self link: %s", body)
+	}
+}
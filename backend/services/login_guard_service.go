@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 登录记录的结果
+const (
+	LoginReasonOK          = "ok"
+	LoginReasonBadPassword = "bad_password"
+	LoginReasonUnknownUser = "unknown_user"
+	LoginReasonLocked      = "locked"
+	LoginReasonMFAPending  = "mfa_pending" // 密码正确，等待两步验证
+	LoginReasonBadCode     = "bad_code"    // 两步验证码或恢复码错误
+	LoginReasonSSODenied   = "sso_denied"  // 单点登录身份有效，但未映射到角色或不允许登录
+)
+
+// loginFailureReasons 计入失败报表的结果
+var loginFailureReasons = []string{LoginReasonBadPassword, LoginReasonUnknownUser, LoginReasonLocked, LoginReasonBadCode, LoginReasonSSODenied}
+
+// LoginPolicy 登录失败计数和锁定策略
+type LoginPolicy struct {
+	MaxUserFailures int
+	MaxIPFailures   int
+	Window          time.Duration // 失败计数窗口，超过后重新计数
+	Lockout         time.Duration // 首次锁定时长，之后每次翻倍
+	MaxLockout      time.Duration // 锁定时长上限，静默超过该时长后锁定次数清零
+}
+
+// LoginLockedError 用户名或 IP 处于锁定中
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed attempts, locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// CurrentLoginPolicy 读取配置中的登录策略，未配置的项使用默认值
+func CurrentLoginPolicy() LoginPolicy {
+	cfg := config.AppConfig.Login
+	orDefault := func(v, def int) int {
+		if v <= 0 {
+			return def
+		}
+		return v
+	}
+	return LoginPolicy{
+		MaxUserFailures: orDefault(cfg.MaxUserFailures, 5),
+		MaxIPFailures:   orDefault(cfg.MaxIPFailures, 20),
+		Window:          time.Duration(orDefault(cfg.WindowMinutes, 15)) * time.Minute,
+		Lockout:         time.Duration(orDefault(cfg.LockoutMinutes, 15)) * time.Minute,
+		MaxLockout:      time.Duration(orDefault(cfg.MaxLockoutMinutes, 1440)) * time.Minute,
+	}
+}
+
+// LoginHistoryRetention 登录记录保留时长，默认 90 天
+func LoginHistoryRetention() time.Duration {
+	days := config.AppConfig.Login.HistoryRetentionDays
+	if days <= 0 {
+		days = 90
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func userLockKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// IPLockKey IP 维度的锁定键；ip 来自 c.ClientIP()，只有配置的可信代理才能通过 X-Forwarded-For 改变它
+func IPLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockDuration 第 n 次锁定的时长：Lockout * 2^(n-1)，不超过 MaxLockout
+func (p LoginPolicy) lockDuration(n int) time.Duration {
+	d := p.Lockout
+	for i := 1; i < n && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// registerFailure 记一次失败，失败次数达到 max 时锁定并清零计数
+func (p LoginPolicy) registerFailure(lock *models.LoginLock, max int, now time.Time) {
+	quiet := now.Sub(lock.LastFailure)
+	if quiet > p.MaxLockout {
+		lock.Lockouts = 0
+	}
+	if quiet > p.Window {
+		lock.Failures = 0
+	}
+	lock.Failures++
+	lock.LastFailure = now
+	if lock.Failures >= max {
+		lock.Lockouts++
+		until := now.Add(p.lockDuration(lock.Lockouts))
+		lock.LockedUntil = &until
+		lock.Failures = 0
+	}
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummyHash 用户不存在时同样做一次 bcrypt 比较，避免通过响应时间判断用户名是否存在
+func compareDummyHash(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func recordLoginAttempt(db *gorm.DB, username, ip, userAgent, reason string, now time.Time) {
+	attempt := models.LoginAttempt{
+		Username:  truncateText(username, 64),
+		IP:        ip,
+		UserAgent: truncateText(userAgent, 255),
+		Success:   reason == LoginReasonOK,
+		Reason:    reason,
+		CreatedAt: now,
+	}
+	if err := db.Create(&attempt).Error; err != nil {
+		fmt.Printf("保存登录记录失败: %v\n", err)
+	}
+}
+
+// AttemptAdminLogin 带失败计数的登录：用户名或 IP 锁定中返回 *LoginLockedError，
+// 用户名不存在和密码错误都返回 ErrInvalidCredentials
+func AttemptAdminLogin(db *gorm.DB, policy LoginPolicy, username, password, ip, userAgent string, now time.Time) (*models.AdminUser, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	userKey, ipKey := userLockKey(username), IPLockKey(ip)
+	if err := checkLoginLocks(db, now, userKey, ipKey); err != nil {
+		var locked *LoginLockedError
+		if errors.As(err, &locked) {
+			recordLoginAttempt(db, username, ip, userAgent, LoginReasonLocked, now)
+		}
+		return nil, err
+	}
+
+	user, err := ValidateAdminLogin(db, username, password)
+	if err == nil {
+		// 启用两步验证的账号在第二步通过后才清除失败计数
+		if user.TOTPEnabled {
+			recordLoginAttempt(db, username, ip, userAgent, LoginReasonMFAPending, now)
+			return user, nil
+		}
+		clearLoginLock(db, userKey)
+		recordLoginAttempt(db, username, ip, userAgent, LoginReasonOK, now)
+		return user, nil
+	}
+
+	reason := LoginReasonBadPassword
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		reason = LoginReasonUnknownUser
+		compareDummyHash(password)
+	case !errors.Is(err, ErrInvalidCredentials):
+		return nil, err
+	}
+
+	if err := registerLoginFailures(db, policy, userKey, ipKey, now); err != nil {
+		return nil, err
+	}
+	recordLoginAttempt(db, username, ip, userAgent, reason, now)
+	return nil, ErrInvalidCredentials
+}
+
+// checkLoginLocks 任一 key 处于锁定中时返回 *LoginLockedError，Until 取最晚的解锁时间
+func checkLoginLocks(db *gorm.DB, now time.Time, keys ...string) error {
+	var locks []models.LoginLock
+	if err := db.Where("`key` IN ?", keys).Find(&locks).Error; err != nil {
+		return err
+	}
+	var until time.Time
+	for _, lock := range locks {
+		if lock.LockedUntil != nil && lock.LockedUntil.After(now) && lock.LockedUntil.After(until) {
+			until = *lock.LockedUntil
+		}
+	}
+	if !until.IsZero() {
+		return &LoginLockedError{Until: until}
+	}
+	return nil
+}
+
+func clearLoginLock(db *gorm.DB, key string) {
+	if err := db.Where("`key` = ?", key).Delete(&models.LoginLock{}).Error; err != nil {
+		fmt.Printf("清除登录锁定失败: %v\n", err)
+	}
+}
+
+// registerLoginFailures 同时为用户名和 IP 记一次失败
+func registerLoginFailures(db *gorm.DB, policy LoginPolicy, userKey, ipKey string, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, k := range []struct {
+			key string
+			max int
+		}{{userKey, policy.MaxUserFailures}, {ipKey, policy.MaxIPFailures}} {
+			lock := models.LoginLock{Key: k.key, LastFailure: now}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("`key` = ?", k.key).First(&lock).Error; err != nil {
+				return err
+			}
+			policy.registerFailure(&lock, k.max, now)
+			if err := tx.Save(&lock).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UnlockLogin 解除用户名或 IP 的锁定，key 形如 user:alice、ip:1.2.3.4
+func UnlockLogin(db *gorm.DB, key string) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	res := db.Where("`key` = ?", key).Delete(&models.LoginLock{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// LoginFailureCount 按用户名或 IP 汇总的失败次数
+type LoginFailureCount struct {
+	Value    string    `json:"value"`
+	Failures int64     `json:"failures"`
+	LastAt   time.Time `json:"last_at"`
+}
+
+// LoginFailureReport 登录失败报表：since 之后失败最多的用户名和 IP，以及当前仍在锁定中的记录
+type LoginFailureReport struct {
+	Since     time.Time           `json:"since"`
+	Attempts  int64               `json:"attempts"`
+	Failures  int64               `json:"failures"`
+	Usernames []LoginFailureCount `json:"usernames"`
+	IPs       []LoginFailureCount `json:"ips"`
+	Locked    []models.LoginLock  `json:"locked"`
+}
+
+// BuildLoginFailureReport 生成登录失败报表，limit 为每个排行的条数
+func BuildLoginFailureReport(db *gorm.DB, since, now time.Time, limit int) (*LoginFailureReport, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	report := &LoginFailureReport{Since: since, Usernames: []LoginFailureCount{}, IPs: []LoginFailureCount{}}
+	base := func() *gorm.DB {
+		return db.Model(&models.LoginAttempt{}).Where("created_at >= ?", since)
+	}
+	if err := base().Count(&report.Attempts).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Where("reason IN ?", loginFailureReasons).Count(&report.Failures).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range []struct {
+		column string
+		dest   *[]LoginFailureCount
+	}{{"username", &report.Usernames}, {"ip", &report.IPs}} {
+		err := base().Where("reason IN ?", loginFailureReasons).
+			Select(g.column + " AS value, COUNT(*) AS failures, MAX(created_at) AS last_at").
+			Group(g.column).Order("failures DESC").Limit(limit).Scan(g.dest).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := db.Where("locked_until > ?", now).Order("locked_until DESC").Find(&report.Locked).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// PurgeLoginHistory 删除 before 之前的登录记录和早已过期的失败计数
+func PurgeLoginHistory(db *gorm.DB, before time.Time) (int64, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	res := db.Where("created_at < ?", before).Delete(&models.LoginAttempt{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	err := db.Where("last_failure < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&models.LoginLock{}).Error
+	return res.RowsAffected, err
+}
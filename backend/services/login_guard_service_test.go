@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"testing"
+	"time"
+)
+
+func testLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxUserFailures: 3,
+		MaxIPFailures:   10,
+		Window:          15 * time.Minute,
+		Lockout:         15 * time.Minute,
+		MaxLockout:      time.Hour,
+	}
+}
+
+func TestLoginLockDuration(t *testing.T) {
+	p := testLoginPolicy()
+	want := []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, time.Hour, time.Hour}
+	for i, w := range want {
+		if got := p.lockDuration(i + 1); got != w {
+			t.Fatalf("lockDuration(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestRegisterLoginFailure(t *testing.T) {
+	p := testLoginPolicy()
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	lock := models.LoginLock{Key: "user:alice", LastFailure: now}
+
+	p.registerFailure(&lock, 3, now)
+	p.registerFailure(&lock, 3, now.Add(time.Minute))
+	if lock.LockedUntil != nil || lock.Failures != 2 {
+		t.Fatalf("should not lock before threshold: %+v", lock)
+	}
+	now = now.Add(2 * time.Minute)
+	p.registerFailure(&lock, 3, now)
+	if lock.LockedUntil == nil || !lock.LockedUntil.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("first lockout should last 15m: %+v", lock)
+	}
+	if lock.Failures != 0 || lock.Lockouts != 1 {
+		t.Fatalf("counter should reset after lockout: %+v", lock)
+	}
+
+	// 解锁后立即再次失败，锁定时长翻倍
+	now = lock.LockedUntil.Add(time.Second)
+	for i := 0; i < 3; i++ {
+		p.registerFailure(&lock, 3, now)
+	}
+	if lock.Lockouts != 2 || !lock.LockedUntil.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("second lockout should last 30m: %+v", lock)
+	}
+
+	// 超过窗口的失败重新计数
+	now = lock.LockedUntil.Add(time.Second)
+	p.registerFailure(&lock, 3, now)
+	p.registerFailure(&lock, 3, now.Add(20*time.Minute))
+	if lock.Failures != 1 {
+		t.Fatalf("failures outside window should reset, got %d", lock.Failures)
+	}
+
+	// 静默超过锁定上限后锁定次数清零
+	now = now.Add(3 * time.Hour)
+	for i := 0; i < 3; i++ {
+		p.registerFailure(&lock, 3, now)
+	}
+	if lock.Lockouts != 1 || !lock.LockedUntil.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("lockouts should reset after a quiet period: %+v", lock)
+	}
+}
+
+func TestLoginLockKeys(t *testing.T) {
+	if got := userLockKey("  Alice "); got != "user:alice" {
+		t.Fatalf("userLockKey = %q", got)
+	}
+	if got := IPLockKey("10.0.0.1"); got != "ip:10.0.0.1" {
+		t.Fatalf("IPLockKey = %q", got)
+	}
+}
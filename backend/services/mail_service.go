@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultMailMaxAttempts = 5
+
+// mailClaimTimeout 领取后超过该时长仍未完成的邮件视为发送中断，重新放回队列
+const mailClaimTimeout = 15 * time.Minute
+
+// MailMessage 一封待发送的邮件
+type MailMessage struct {
+	To          string
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	Unsubscribe string
+}
+
+// MailSender 邮件发送接口，便于测试替换
+type MailSender interface {
+	Send(msg MailMessage) error
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件
+type SMTPSender struct {
+	Config config.SMTPConfig
+}
+
+func NewSMTPSender(cfg config.SMTPConfig) *SMTPSender {
+	return &SMTPSender{Config: cfg}
+}
+
+func (s *SMTPSender) Send(msg MailMessage) error {
+	if s.Config.Host == "" || s.Config.From == "" {
+		return errors.New("smtp not configured")
+	}
+	from, err := mail.ParseAddress(s.Config.From)
+	if err != nil {
+		return err
+	}
+	data, err := BuildMIMEMessage(s.Config.From, msg)
+	if err != nil {
+		return err
+	}
+
+	port := s.Config.Port
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(port))
+
+	var conn net.Conn
+	if s.Config.TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: s.Config.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 30*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(60 * time.Second))
+
+	client, err := smtp.NewClient(conn, s.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !s.Config.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.Config.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.Config.Username != "" {
+		auth := smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// BuildMIMEMessage 生成 multipart/alternative 邮件（纯文本 + HTML）
+func BuildMIMEMessage(from string, msg MailMessage) ([]byte, error) {
+	boundaryBytes := make([]byte, 12)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := "bre_" + hex.EncodeToString(boundaryBytes)
+
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	writeHeader("From", from)
+	writeHeader("To", msg.To)
+	writeHeader("Subject", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+	if msg.Unsubscribe != "" {
+		writeHeader("List-Unsubscribe", "<"+msg.Unsubscribe+">")
+		// RFC 8058 一键退订：邮件客户端直接 POST 到退订链接
+		writeHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	writeHeader("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+
+	writePart := func(contentType, body string) error {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(body)); err != nil {
+			return err
+		}
+		if err := qp.Close(); err != nil {
+			return err
+		}
+		buf.WriteString("\r\n")
+		return nil
+	}
+	if err := writePart("text/plain", msg.TextBody); err != nil {
+		return nil, err
+	}
+	if msg.HTMLBody != "" {
+		if err := writePart("text/html", msg.HTMLBody); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes(), nil
+}
+
+// EnqueueEmail 写入发送队列，由 ProcessEmailQueue 异步发送
+func EnqueueEmail(db *gorm.DB, msg MailMessage) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	row := models.EmailQueue{
+		To:            msg.To,
+		Subject:       msg.Subject,
+		TextBody:      msg.TextBody,
+		HTMLBody:      msg.HTMLBody,
+		Unsubscribe:   msg.Unsubscribe,
+		Status:        models.EmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	return db.Create(&row).Error
+}
+
+// ProcessEmailQueue 发送到期的邮件，失败按指数退避重试，超过最大次数标记为 failed
+func ProcessEmailQueue(db *gorm.DB, sender MailSender, now time.Time, maxAttempts int) {
+	if db == nil || sender == nil {
+		return
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMailMaxAttempts
+	}
+
+	// 进程在发送中退出时邮件会停留在 sending，超时后放回队列
+	if err := db.Model(&models.EmailQueue{}).Where("status = ? AND updated_at < ?", models.EmailSending, now.Add(-mailClaimTimeout)).
+		Update("status", models.EmailPending).Error; err != nil {
+		fmt.Printf("恢复邮件队列失败: %v\n", err)
+	}
+
+	var rows []models.EmailQueue
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.EmailPending, now).
+		Order("id asc").Limit(50).Find(&rows).Error; err != nil {
+		fmt.Printf("读取邮件队列失败: %v\n", err)
+		return
+	}
+
+	for _, row := range rows {
+		// 先领取再发送，上一轮处理还未结束时不会重复发送同一封邮件
+		claim := db.Model(&models.EmailQueue{}).Where("id = ? AND status = ?", row.ID, models.EmailPending).
+			Updates(map[string]interface{}{"status": models.EmailSending, "updated_at": time.Now()})
+		if claim.Error != nil {
+			fmt.Printf("领取邮件 %d 失败: %v\n", row.ID, claim.Error)
+			continue
+		}
+		if claim.RowsAffected != 1 {
+			continue
+		}
+		err := sender.Send(MailMessage{
+			To:          row.To,
+			Subject:     row.Subject,
+			TextBody:    row.TextBody,
+			HTMLBody:    row.HTMLBody,
+			Unsubscribe: row.Unsubscribe,
+		})
+		updates := map[string]interface{}{"attempts": row.Attempts + 1}
+		if err == nil {
+			updates["status"] = models.EmailSent
+			updates["sent_at"] = now
+			updates["last_error"] = ""
+		} else {
+			fmt.Printf("发送邮件 %d 到 %s 失败: %v\n", row.ID, row.To, err)
+			updates["last_error"] = err.Error()
+			if row.Attempts+1 >= maxAttempts {
+				updates["status"] = models.EmailFailed
+			} else {
+				updates["status"] = models.EmailPending
+				updates["next_attempt_at"] = now.Add(mailRetryBackoff(row.Attempts + 1))
+			}
+		}
+		if err := db.Model(&models.EmailQueue{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+			fmt.Printf("更新邮件队列失败: %v\n", err)
+		}
+	}
+}
+
+// mailRetryBackoff 第 n 次失败后的等待时间：1、2、4、8... 分钟，最长 1 小时
+func mailRetryBackoff(attempts int) time.Duration {
+	if attempts > 6 {
+		return time.Hour
+	}
+	return time.Duration(1<<(attempts-1)) * time.Minute
+}
+
+// randomToken 生成 URL 安全的随机令牌
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", errors.New("invalid email")
+	}
+	return strings.ToLower(addr.Address), nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bufio"
+	"mime/quotedprintable"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer 进程内的最小 SMTP 替身，记录收到的信封和正文
+type fakeSMTPServer struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	data     string
+	received chan struct{}
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTPServer{ln: ln, received: make(chan struct{}, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			s.mu.Unlock()
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = sb.String()
+			s.mu.Unlock()
+			reply("250 OK")
+			s.received <- struct{}{}
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSenderSend(t *testing.T) {
+	srv := startFakeSMTPServer(t)
+	host, portStr, _ := net.SplitHostPort(srv.ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	sender := NewSMTPSender(config.SMTPConfig{Host: host, Port: port, From: "News <news@example.com>"})
+	err := sender.Send(MailMessage{
+		To:          "reader@example.com",
+		Subject:     "2026-01-02 早报",
+		TextBody:    "纯文本正文",
+		HTMLBody:    "<p>HTML 正文</p>",
+		Unsubscribe: "https://example.com/api/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	<-srv.received
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.from != "news@example.com" || len(srv.rcpt) != 1 || srv.rcpt[0] != "reader@example.com" {
+		t.Fatalf("unexpected envelope from=%s rcpt=%v", srv.from, srv.rcpt)
+	}
+	if !strings.Contains(srv.data, "multipart/alternative") || !strings.Contains(srv.data, "List-Unsubscribe: <https://example.com/api/unsubscribe?token=abc>") || !strings.Contains(srv.data, "List-Unsubscribe-Post: List-Unsubscribe=One-Click") {
+		t.Fatalf("unexpected headers: %s", srv.data)
+	}
+	decoded := decodeQP(t, srv.data)
+	if !strings.Contains(decoded, "纯文本正文") || !strings.Contains(decoded, "<p>HTML 正文</p>") {
+		t.Fatalf("body parts missing: %s", decoded)
+	}
+}
+
+func decodeQP(t *testing.T, s string) string {
+	t.Helper()
+	var sb strings.Builder
+	r := quotedprintable.NewReader(strings.NewReader(s))
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		sb.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	return sb.String()
+}
+
+func TestRenderDigestEscapesHTML(t *testing.T) {
+	text, html, err := RenderDigest(DigestData{
+		Title:          "2026-01-02 早报",
+		News:           []models.NewsItem{{Title: "A<b>股</b>", Url: "https://example.com/a"}},
+		Analyses:       []models.Analysis{{Type: models.Analysis7Day, Content: "看好黄金"}},
+		UnsubscribeURL: "https://example.com/api/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(text, "1. A<b>股</b>") || !strings.Contains(text, "【7 日财经分析】") {
+		t.Fatalf("unexpected text: %s", text)
+	}
+	if strings.Contains(html, "<b>股</b>") || !strings.Contains(html, "A&lt;b&gt;股&lt;/b&gt;") {
+		t.Fatalf("html not escaped: %s", html)
+	}
+}
+
+func TestNormalizeTopics(t *testing.T) {
+	topics, err := normalizeTopics([]string{"morning", " analysis", "morning"})
+	if err != nil || topics != "morning,analysis" {
+		t.Fatalf("got %q %v", topics, err)
+	}
+	if _, err := normalizeTopics([]string{"midnight"}); err == nil {
+		t.Fatal("expected invalid topic error")
+	}
+	if !hasTopic("morning,analysis", "analysis") || hasTopic("morning,analysis", "noon") {
+		t.Fatal("hasTopic mismatch")
+	}
+}
+
+func TestMailRetryBackoff(t *testing.T) {
+	if mailRetryBackoff(1).Minutes() != 1 || mailRetryBackoff(3).Minutes() != 4 || mailRetryBackoff(10).Hours() != 1 {
+		t.Fatal("unexpected backoff")
+	}
+}
+
+func TestWindowLimiter(t *testing.T) {
+	l := &windowLimiter{hits: map[string][]time.Time{}}
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if !l.Allow("1.2.3.4", 3, time.Hour, now.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+	if l.Allow("1.2.3.4", 3, time.Hour, now.Add(10*time.Minute)) {
+		t.Fatal("fourth request within the window should be limited")
+	}
+	if !l.Allow("5.6.7.8", 3, time.Hour, now.Add(10*time.Minute)) {
+		t.Fatal("other ip should not be affected")
+	}
+	if !l.Allow("1.2.3.4", 3, time.Hour, now.Add(61*time.Minute)) {
+		t.Fatal("oldest request should leave the window after an hour")
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval = 30 // 分钟
+	maxFeedBodyBytes    = 5 << 20
+	userAgent           = "Mozilla/5.0 (compatible; BreNewsBot/1.0)"
+)
+
+var sourceHTTPClient = &http.Client{Timeout: 20 * time.Second}
+
+// NewsSource 新闻来源，返回候选新闻条目
+type NewsSource interface {
+	Fetch() ([]NewsData, error)
+}
+
+// RSSSource 抓取 RSS 2.0 / RSS 1.0 (RDF) / Atom 订阅源
+type RSSSource struct {
+	URL  string
+	Name string
+}
+
+func NewRSSSource(source models.NewsSource) *RSSSource {
+	return &RSSSource{URL: source.Url, Name: source.Name}
+}
+
+func (s *RSSSource) Fetch() ([]NewsData, error) {
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+
+	resp, err := sourceHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("fetch failed with status %d", resp.StatusCode)
+	}
+
+	items, err := ParseFeed(io.LimitReader(resp.Body, maxFeedBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Source = s.Name
+	}
+	return items, nil
+}
+
+type feedXML struct {
+	Channel struct {
+		Items []feedItemXML `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItemXML  `xml:"item"` // RSS 1.0 的 item 与 channel 平级
+	Entries []feedEntryXML `xml:"entry"`
+}
+
+type feedItemXML struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Description string `xml:"description"`
+}
+
+type feedEntryXML struct {
+	Title string `xml:"title"`
+	ID    string `xml:"id"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+}
+
+// ParseFeed 解析 RSS / Atom 文档，自动处理 GBK 等非 UTF-8 编码声明
+func ParseFeed(r io.Reader) ([]NewsData, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+
+	var doc feedXML
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse feed: %w", err)
+	}
+
+	var items []NewsData
+	for _, it := range append(doc.Channel.Items, doc.Items...) {
+		guid := strings.TrimSpace(it.GUID)
+		if guid == "" {
+			guid = strings.TrimSpace(it.Link)
+		}
+		published := parseFeedTime(it.PubDate)
+		if published == nil {
+			published = parseFeedTime(it.Date)
+		}
+		items = append(items, NewsData{
+			Title:       cleanText(it.Title),
+			URL:         strings.TrimSpace(it.Link),
+			GUID:        guid,
+			Summary:     cleanText(it.Description),
+			PublishedAt: published,
+		})
+	}
+	for _, e := range doc.Entries {
+		link := ""
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+		guid := strings.TrimSpace(e.ID)
+		if guid == "" {
+			guid = link
+		}
+		published := parseFeedTime(e.Published)
+		if published == nil {
+			published = parseFeedTime(e.Updated)
+		}
+		summary := e.Summary
+		if summary == "" {
+			summary = e.Content
+		}
+		items = append(items, NewsData{
+			Title:       cleanText(e.Title),
+			URL:         link,
+			GUID:        guid,
+			Summary:     cleanText(summary),
+			PublishedAt: published,
+		})
+	}
+
+	// 丢弃没有标题或标识的条目
+	out := items[:0]
+	for _, it := range items {
+		if it.Title != "" && it.GUID != "" {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+func parseFeedTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// cleanText 去掉 HTML 标签和多余空白
+func cleanText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func guidHash(guid string) string {
+	sum := sha256.Sum256([]byte(guid))
+	return hex.EncodeToString(sum[:])
+}
+
+// FetchNewsSource 抓取单个新闻源，保存新条目并记录抓取状态
+func FetchNewsSource(db *gorm.DB, source models.NewsSource, fetcher NewsSource, now time.Time) (int, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	if fetcher == nil {
+		fetcher = NewRSSSource(source)
+	}
+
+	items, fetchErr := fetcher.Fetch()
+	inserted := 0
+	for _, it := range items {
+		row := models.NewsSourceItem{
+			SourceID:    source.ID,
+			GUIDHash:    guidHash(it.GUID),
+			Title:       truncateText(it.Title, 512),
+			Url:         truncateText(it.URL, 1024),
+			Summary:     it.Summary,
+			PublishedAt: it.PublishedAt,
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if result.Error != nil {
+			fetchErr = result.Error
+			break
+		}
+		inserted += int(result.RowsAffected)
+	}
+
+	updates := map[string]interface{}{
+		"last_fetched_at": now,
+		"last_item_count": inserted,
+		"last_status":     "ok",
+		"last_error":      "",
+	}
+	if fetchErr != nil {
+		updates["last_status"] = "error"
+		updates["last_error"] = fetchErr.Error()
+	}
+	if err := db.Model(&models.NewsSource{}).Where("id = ?", source.ID).Updates(updates).Error; err != nil {
+		return inserted, err
+	}
+	return inserted, fetchErr
+}
+
+// PollNewsSources 抓取所有到期的启用新闻源
+func PollNewsSources(db *gorm.DB, now time.Time) {
+	if db == nil {
+		return
+	}
+	var sources []models.NewsSource
+	if err := db.Where("enabled = ?", true).Find(&sources).Error; err != nil {
+		fmt.Printf("读取新闻源失败: %v\n", err)
+		return
+	}
+	for _, source := range sources {
+		interval := source.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+		if source.LastFetchedAt != nil && now.Before(source.LastFetchedAt.Add(time.Duration(interval)*time.Minute)) {
+			continue
+		}
+		inserted, err := FetchNewsSource(db, source, nil, now)
+		if err != nil {
+			fmt.Printf("抓取新闻源 %s 失败: %v\n", source.Name, err)
+			continue
+		}
+		fmt.Printf("已抓取新闻源 %s，新增 %d 条\n", source.Name, inserted)
+	}
+}
+
+// CollectSourceNews 汇总自上一批次以来新抓取的条目，作为本批次的候选新闻
+func CollectSourceNews(db *gorm.DB, now time.Time) ([]NewsData, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	since := now.Add(-24 * time.Hour)
+	var lastBatch models.BatchLog
+	if err := db.Order("created_at desc").First(&lastBatch).Error; err == nil && lastBatch.CreatedAt.After(since) {
+		since = lastBatch.CreatedAt
+	}
+
+	var rows []struct {
+		models.NewsSourceItem
+		SourceName     string
+		SourceCategory string
+	}
+	err := db.Table("news_source_items").
+		Select("news_source_items.*, news_sources.name AS source_name, news_sources.category AS source_category").
+		Joins("JOIN news_sources ON news_sources.id = news_source_items.source_id AND news_sources.deleted_at IS NULL").
+		Where("news_sources.enabled = ? AND news_source_items.created_at >= ?", true, since).
+		Where("news_source_items.published_at IS NULL OR news_source_items.published_at >= ?", now.Add(-48*time.Hour)).
+		Order("news_source_items.published_at desc, news_source_items.id desc").
+		Limit(200).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]NewsData, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, NewsData{
+			Title:       row.Title,
+			URL:         row.Url,
+			Source:      row.SourceName,
+			Category:    row.SourceCategory,
+			Summary:     row.Summary,
+			PublishedAt: row.PublishedAt,
+		})
+	}
+	return items, nil
+}
+
+// MergeNewsCandidates 合并多个来源的新闻并按链接和标题去重，先出现的优先
+func MergeNewsCandidates(lists ...[]NewsData) []NewsData {
+	seen := map[string]bool{}
+	var out []NewsData
+	for _, list := range lists {
+		for _, item := range list {
+			keys := []string{"t:" + normalizeTitle(item.Title)}
+			if item.URL != "" {
+				keys = append(keys, "u:"+strings.TrimRight(strings.TrimSpace(item.URL), "/"))
+			}
+			dup := false
+			for _, k := range keys {
+				if seen[k] {
+					dup = true
+				}
+			}
+			if dup {
+				continue
+			}
+			for _, k := range keys {
+				seen[k] = true
+			}
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), ""))
+}
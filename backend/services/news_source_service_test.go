@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const testRSS = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>t</title>
+<item><title>央行宣布 &lt;b&gt;降准&lt;/b&gt;</title><link>https://example.com/a</link>
+<guid>a-1</guid><pubDate>Fri, 02 Jan 2026 08:00:00 +0800</pubDate>
+<description><![CDATA[<p>释放长期资金 <b>1 万亿</b></p>]]></description></item>
+<item><title>无标识条目</title></item>
+<item><title></title><link>https://example.com/empty</link></item>
+</channel></rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>t</title>
+<entry><title>Oil rises</title><id>urn:1</id>
+<link rel="alternate" href="https://example.com/oil"/><link rel="self" href="https://example.com/self"/>
+<updated>2026-01-02T09:00:00Z</updated><summary>Brent up 2%</summary></entry>
+</feed>`
+
+func TestParseFeedRSSAndAtom(t *testing.T) {
+	items, err := ParseFeed(strings.NewReader(testRSS))
+	if err != nil {
+		t.Fatalf("parse rss: %v", err)
+	}
+	// 无标题的条目被丢弃，无 guid 的条目以链接为标识，两者都缺失时丢弃
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d: %+v", len(items), items)
+	}
+	it := items[0]
+	if it.Title != "央行宣布 降准" || it.GUID != "a-1" || it.Summary != "释放长期资金 1 万亿" || it.PublishedAt == nil || it.PublishedAt.UTC().Hour() != 0 {
+		t.Fatalf("unexpected rss item: %+v", it)
+	}
+
+	items, err = ParseFeed(strings.NewReader(testAtom))
+	if err != nil {
+		t.Fatalf("parse atom: %v", err)
+	}
+	if len(items) != 1 || items[0].URL != "https://example.com/oil" || items[0].GUID != "urn:1" || items[0].Summary != "Brent up 2%" || items[0].PublishedAt == nil {
+		t.Fatalf("unexpected atom items: %+v", items)
+	}
+}
+
+func TestRSSSourceFetchGBK(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="gbk"?><rss version="2.0"><channel>` +
+		`<item><title>沪指收涨</title><link>https://example.com/gbk</link></item></channel></rss>`
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(encoded))
+	}))
+	defer srv.Close()
+
+	items, err := NewRSSSource(models.NewsSource{Name: "测试源", Url: srv.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "沪指收涨" || items[0].Source != "测试源" || items[0].GUID != "https://example.com/gbk" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+}
+
+func TestMergeNewsCandidates(t *testing.T) {
+	merged := MergeNewsCandidates(
+		[]NewsData{{Title: "央行 降准", URL: "https://example.com/a/", Source: "rss"}},
+		[]NewsData{
+			{Title: "另一标题", URL: "https://example.com/a", Source: "AI Summary"},
+			{Title: "央行降准", URL: "https://example.com/other", Source: "AI Summary"},
+			{Title: "油价上涨", URL: "", Source: "AI Summary"},
+		},
+	)
+	if len(merged) != 2 || merged[0].Source != "rss" || merged[1].Title != "油价上涨" {
+		t.Fatalf("unexpected merge: %+v", merged)
+	}
+}
@@ -0,0 +1,631 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStateTTL      = 10 * time.Minute
+	oidcDiscoveryTTL  = time.Hour
+	oidcClockLeeway   = time.Minute
+	oidcHTTPTimeout   = 10 * time.Second
+	oidcMaxBodyLength = 1 << 20
+)
+
+var (
+	ErrOIDCDisabled       = errors.New("sso is not enabled")
+	ErrOIDCState          = errors.New("sso login expired or invalid, please retry")
+	ErrOIDCToken          = errors.New("invalid id token")
+	ErrOIDCNoRole         = errors.New("no admin role is mapped to this account")
+	ErrOIDCNotProvisioned = errors.New("account is not registered")
+	ErrOIDCUsernameTaken  = errors.New("username already used by a local account, sign in with the password and link sso in account security")
+	ErrOIDCAlreadyLinked  = errors.New("sso account is already linked to an admin")
+	ErrOIDCLastOwner      = errors.New("sso role would demote the last owner, sign in with the password")
+	ErrOIDCEmailDomain    = errors.New("email domain is not allowed")
+)
+
+// OIDCDiscovery 身份提供方 /.well-known/openid-configuration 中用到的字段
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims ID Token 中的声明
+type OIDCClaims map[string]interface{}
+
+// String 读取字符串声明，不存在或类型不符时返回空
+func (c OIDCClaims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings 读取字符串数组声明，兼容单个字符串
+func (c OIDCClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// OIDCClient 单个身份提供方的客户端，缓存发现文档和签名公钥
+type OIDCClient struct {
+	Config config.OIDCConfig
+	HTTP   *http.Client
+
+	mu           sync.Mutex
+	discovery    *OIDCDiscovery
+	discoveredAt time.Time
+	keys         map[string]*rsa.PublicKey
+}
+
+// NewOIDCClient 创建客户端
+func NewOIDCClient(cfg config.OIDCConfig) *OIDCClient {
+	return &OIDCClient{Config: cfg, HTTP: &http.Client{Timeout: oidcHTTPTimeout}}
+}
+
+var (
+	oidcClientOnce sync.Once
+	oidcClient     *OIDCClient
+)
+
+// CurrentOIDCClient 按配置创建的全局客户端，未启用时返回 ErrOIDCDisabled
+func CurrentOIDCClient() (*OIDCClient, error) {
+	cfg := config.AppConfig.OIDC
+	if !cfg.Enabled || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, ErrOIDCDisabled
+	}
+	oidcClientOnce.Do(func() { oidcClient = NewOIDCClient(cfg) })
+	return oidcClient, nil
+}
+
+func (o *OIDCClient) getJSON(ctx context.Context, endpoint string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBodyLength)).Decode(dest)
+}
+
+// Discover 读取并缓存发现文档，issuer 必须与配置一致
+func (o *OIDCClient) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	o.mu.Lock()
+	if o.discovery != nil && time.Since(o.discoveredAt) < oidcDiscoveryTTL {
+		d := o.discovery
+		o.mu.Unlock()
+		return d, nil
+	}
+	o.mu.Unlock()
+
+	issuer := strings.TrimRight(o.Config.Issuer, "/")
+	var d OIDCDiscovery
+	if err := o.getJSON(ctx, issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s", d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("incomplete discovery document")
+	}
+
+	o.mu.Lock()
+	o.discovery = &d
+	o.discoveredAt = time.Now()
+	o.keys = nil
+	o.mu.Unlock()
+	return &d, nil
+}
+
+// pkceChallenge RFC 7636 S256：BASE64URL(SHA256(verifier))
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL 生成跳转到身份提供方的授权地址
+func (o *OIDCClient) AuthCodeURL(d *OIDCDiscovery, state, nonce, verifier string) string {
+	scopes := o.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", o.Config.ClientID)
+	params.Set("redirect_uri", o.Config.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", pkceChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange 用授权码和 PKCE 校验码换取 ID Token
+func (o *OIDCClient) Exchange(ctx context.Context, d *OIDCDiscovery, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", o.Config.RedirectURL)
+	form.Set("client_id", o.Config.ClientID)
+	form.Set("code_verifier", verifier)
+	if o.Config.ClientSecret != "" {
+		form.Set("client_secret", o.Config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBodyLength)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token endpoint: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token endpoint returned no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// publicKey 按 kid 取签名公钥，找不到时重新拉取一次 JWKS，以支持身份提供方轮换密钥
+func (o *OIDCClient) publicKey(ctx context.Context, d *OIDCDiscovery, kid string) (*rsa.PublicKey, error) {
+	o.mu.Lock()
+	key, ok := o.keys[kid]
+	o.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, d.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// 只有一个密钥且令牌未带 kid 时直接使用
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key id %q", ErrOIDCToken, kid)
+}
+
+// VerifyIDToken 校验 RS256 签名以及 iss、aud、exp、nonce，返回声明
+func (o *OIDCClient) VerifyIDToken(ctx context.Context, d *OIDCDiscovery, raw, nonce string, now time.Time) (OIDCClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrOIDCToken
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrOIDCToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrOIDCToken
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrOIDCToken, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrOIDCToken
+	}
+	key, err := o.publicKey(ctx, d, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrOIDCToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrOIDCToken
+	}
+	var claims OIDCClaims
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return nil, ErrOIDCToken
+	}
+
+	if claims.String("iss") != d.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrOIDCToken)
+	}
+	audOK := false
+	for _, aud := range claims.Strings("aud") {
+		if aud == o.Config.ClientID {
+			audOK = true
+		}
+	}
+	if !audOK {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrOIDCToken)
+	}
+	if azp := claims.String("azp"); azp != "" && azp != o.Config.ClientID {
+		return nil, fmt.Errorf("%w: azp mismatch", ErrOIDCToken)
+	}
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrOIDCToken)
+	}
+	expUnix, err := exp.Int64()
+	if err != nil || !now.Before(time.Unix(expUnix, 0).Add(oidcClockLeeway)) {
+		return nil, fmt.Errorf("%w: expired", ErrOIDCToken)
+	}
+	if claims.String("nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCToken)
+	}
+	if claims.String("sub") == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrOIDCToken)
+	}
+	return claims, nil
+}
+
+// oidcRoleRank 匹配多个用户组时取排名靠前的角色
+var oidcRoleRank = []models.AdminRole{models.RoleOwner, models.RoleEditor, models.RoleOperator, models.RoleViewer}
+
+// OIDCRoleFor 根据用户组映射角色，未匹配时使用 DefaultRole，均无时返回 false
+func OIDCRoleFor(cfg config.OIDCConfig, groups []string) (models.AdminRole, bool) {
+	matched := map[models.AdminRole]bool{}
+	for _, g := range groups {
+		if role, ok := cfg.RoleMapping[g]; ok && IsAdminRole(models.AdminRole(role)) {
+			matched[models.AdminRole(role)] = true
+		}
+	}
+	for _, role := range oidcRoleRank {
+		if matched[role] {
+			return role, true
+		}
+	}
+	if cfg.DefaultRole != "" && IsAdminRole(models.AdminRole(cfg.DefaultRole)) {
+		return models.AdminRole(cfg.DefaultRole), true
+	}
+	return "", false
+}
+
+// oidcUsername 取用户名声明，缺失时使用邮箱
+func oidcUsername(cfg config.OIDCConfig, claims OIDCClaims) string {
+	claim := cfg.UsernameClaim
+	if claim == "" {
+		claim = "preferred_username"
+	}
+	if name := strings.TrimSpace(claims.String(claim)); name != "" {
+		return name
+	}
+	return strings.TrimSpace(claims.String("email"))
+}
+
+// checkOIDCEmailDomain 配置了域名白名单时要求邮箱已验证且域名在列表中
+func checkOIDCEmailDomain(cfg config.OIDCConfig, claims OIDCClaims) error {
+	if len(cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	email := strings.ToLower(claims.String("email"))
+	verified, _ := claims["email_verified"].(bool)
+	at := strings.LastIndex(email, "@")
+	if !verified || at < 0 {
+		return ErrOIDCEmailDomain
+	}
+	for _, domain := range cfg.AllowedDomains {
+		if strings.EqualFold(email[at+1:], strings.TrimPrefix(domain, "@")) {
+			return nil
+		}
+	}
+	return ErrOIDCEmailDomain
+}
+
+// syncOIDCRole 将角色同步为身份提供方映射的角色；会降级最后一个 owner 时拒绝登录，避免以 owner 身份继续使用
+func syncOIDCRole(db *gorm.DB, user *models.AdminUser, role models.AdminRole) (*models.AdminUser, error) {
+	if user.Role == role {
+		return user, nil
+	}
+	updated, err := SetAdminRole(db, user.ID, role)
+	if errors.Is(err, ErrLastOwner) {
+		return nil, ErrOIDCLastOwner
+	}
+	return updated, err
+}
+
+// oidcRole 校验邮箱域名并按用户组映射角色
+func oidcRole(cfg config.OIDCConfig, claims OIDCClaims) (models.AdminRole, error) {
+	if err := checkOIDCEmailDomain(cfg, claims); err != nil {
+		return "", err
+	}
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	role, ok := OIDCRoleFor(cfg, claims.Strings(groupsClaim))
+	if !ok {
+		return "", ErrOIDCNoRole
+	}
+	return role, nil
+}
+
+// ProvisionOIDCUser 按 (issuer, sub) 找到或创建管理员，并按用户组同步角色。
+// 不按用户名等声明匹配已有本地账号，本地账号需登录后在账号安全中主动关联
+func ProvisionOIDCUser(db *gorm.DB, cfg config.OIDCConfig, issuer string, claims OIDCClaims) (*models.AdminUser, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	role, err := oidcRole(cfg, claims)
+	if err != nil {
+		return nil, err
+	}
+	subject := claims.String("sub")
+
+	var user models.AdminUser
+	err = db.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error
+	if err == nil {
+		return syncOIDCRole(db, &user, role)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !cfg.AutoProvision {
+		return nil, ErrOIDCNotProvisioned
+	}
+
+	username := truncateText(oidcUsername(cfg, claims), 64)
+	if username == "" {
+		return nil, fmt.Errorf("%w: missing username claim", ErrOIDCToken)
+	}
+	var count int64
+	if err := db.Unscoped().Model(&models.AdminUser{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrOIDCUsernameTaken
+	}
+
+	// 单点登录账号没有本地密码，PasswordHash 为空时密码登录总是失败
+	user = models.AdminUser{
+		Username:    username,
+		Role:        role,
+		OIDCIssuer:  issuer,
+		OIDCSubject: subject,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConfirmOIDCLink 发起关联的管理员确认回调结果，将身份提供方账号关联到该管理员；角色在之后单点登录时同步。
+// 确认令牌只出现在完成回调的浏览器中，且只能由发起关联的管理员使用，防止把他人的身份提供方账号关联到自己
+func ConfirmOIDCLink(db *gorm.DB, token string, userID uint, now time.Time) (*models.AdminUser, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var pending models.OIDCLoginState
+	if err := db.Where("state = ? AND link_subject <> ''", token).First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOIDCState
+		}
+		return nil, err
+	}
+	if pending.LinkUserID != userID {
+		return nil, ErrOIDCState
+	}
+	if res := db.Where("state = ?", pending.State).Delete(&models.OIDCLoginState{}); res.Error != nil || res.RowsAffected == 0 {
+		return nil, ErrOIDCState
+	}
+	if !now.Before(pending.ExpiresAt) {
+		return nil, ErrOIDCState
+	}
+
+	var count int64
+	if err := db.Model(&models.AdminUser{}).Where("oidc_issuer = ? AND oidc_subject = ? AND id <> ?", pending.LinkIssuer, pending.LinkSubject, userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrOIDCAlreadyLinked
+	}
+	var user models.AdminUser
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&user).Updates(map[string]interface{}{"oidc_issuer": pending.LinkIssuer, "oidc_subject": pending.LinkSubject}).Error; err != nil {
+		return nil, err
+	}
+	user.OIDCIssuer, user.OIDCSubject = pending.LinkIssuer, pending.LinkSubject
+	return &user, nil
+}
+
+// StartOIDCLogin 生成 state、nonce 和 PKCE 校验码并保存，返回跳转地址和 state；linkUserID 非 0 时为已登录管理员关联账号。
+// 登录时调用方需把 OIDCStateCookieValue(state) 写入浏览器 Cookie，回调时据此确认是同一浏览器
+func StartOIDCLogin(ctx context.Context, db *gorm.DB, client *OIDCClient, linkUserID uint, now time.Time) (redirectURL, stateValue string, err error) {
+	if db == nil {
+		return "", "", errors.New("db is nil")
+	}
+	d, err := client.Discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	var values [3]string
+	for i := range values {
+		if values[i], err = newRandomToken(); err != nil {
+			return "", "", err
+		}
+	}
+	state := models.OIDCLoginState{
+		State:        values[0],
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		LinkUserID:   linkUserID,
+		ExpiresAt:    now.Add(oidcStateTTL),
+		CreatedAt:    now,
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		fmt.Printf("清理过期单点登录状态失败: %v\n", err)
+	}
+	if err := db.Create(&state).Error; err != nil {
+		return "", "", err
+	}
+	return client.AuthCodeURL(d, state.State, state.Nonce, state.CodeVerifier), state.State, nil
+}
+
+// OIDCStateCookieValue 浏览器 Cookie 中保存的 state 哈希
+func OIDCStateCookieValue(state string) string {
+	return HashSessionToken(state)
+}
+
+// FinishOIDCLogin 处理回调：取出并删除 state，换取并校验 ID Token，返回对应的管理员；
+// 关联账号的回调不返回管理员，而是返回待确认的令牌，由发起关联的管理员调用 ConfirmOIDCLink
+func FinishOIDCLogin(ctx context.Context, db *gorm.DB, client *OIDCClient, stateValue, stateCookie, code, ip, userAgent string, now time.Time) (user *models.AdminUser, linkToken string, err error) {
+	if db == nil {
+		return nil, "", errors.New("db is nil")
+	}
+	var state models.OIDCLoginState
+	if err := db.Where("state = ? AND link_subject = ''", stateValue).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrOIDCState
+		}
+		return nil, "", err
+	}
+	// 登录的 state 须与发起登录的浏览器 Cookie 一致，防止把攻击者的回调地址发给他人完成登录；
+	// 关联账号由前端以 Bearer 令牌发起，没有 Cookie，改由 ConfirmOIDCLink 确认
+	if state.LinkUserID == 0 && subtle.ConstantTimeCompare([]byte(OIDCStateCookieValue(state.State)), []byte(stateCookie)) != 1 {
+		return nil, "", ErrOIDCState
+	}
+	// state 只能使用一次
+	if res := db.Where("state = ?", state.State).Delete(&models.OIDCLoginState{}); res.Error != nil || res.RowsAffected == 0 {
+		return nil, "", ErrOIDCState
+	}
+	if !now.Before(state.ExpiresAt) || code == "" {
+		return nil, "", ErrOIDCState
+	}
+
+	d, err := client.Discover(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	rawIDToken, err := client.Exchange(ctx, d, code, state.CodeVerifier)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := client.VerifyIDToken(ctx, d, rawIDToken, state.Nonce, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if state.LinkUserID != 0 {
+		if _, err := oidcRole(client.Config, claims); err != nil {
+			return nil, "", err
+		}
+		if linkToken, err = newRandomToken(); err != nil {
+			return nil, "", err
+		}
+		pending := models.OIDCLoginState{
+			State:       linkToken,
+			LinkUserID:  state.LinkUserID,
+			LinkIssuer:  d.Issuer,
+			LinkSubject: claims.String("sub"),
+			ExpiresAt:   now.Add(oidcStateTTL),
+			CreatedAt:   now,
+		}
+		if err := db.Create(&pending).Error; err != nil {
+			return nil, "", err
+		}
+		return nil, linkToken, nil
+	}
+	user, err = ProvisionOIDCUser(db, client.Config, d.Issuer, claims)
+	if err != nil {
+		recordLoginAttempt(db, oidcUsername(client.Config, claims), ip, userAgent, LoginReasonSSODenied, now)
+		return nil, "", err
+	}
+	// 启用两步验证的账号还需完成第二步，届时再记录登录成功
+	if !user.TOTPEnabled {
+		recordLoginAttempt(db, user.Username, ip, userAgent, LoginReasonOK, now)
+	}
+	return user, "", nil
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testOIDCProvider 本地模拟的身份提供方：发现文档、JWKS 和令牌端点
+type testOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	claims   map[string]interface{}
+	verifier string
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testOIDCProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                p.server.URL,
+			AuthorizationEndpoint: p.server.URL + "/authorize",
+			TokenEndpoint:         p.server.URL + "/token",
+			JWKSURI:               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || r.Form.Get("code_verifier") != p.verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, "RS256", "k1", p.claims)})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testOIDCProvider) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *testOIDCProvider) client() *OIDCClient {
+	return NewOIDCClient(config.OIDCConfig{
+		Issuer:      p.server.URL,
+		ClientID:    "bre-admin",
+		RedirectURL: "https://admin.example.com/api/admin/oidc/callback",
+	})
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636 附录 B
+	if got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("pkceChallenge = %s", got)
+	}
+}
+
+func TestOIDCExchangeAndVerify(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	client := p.client()
+	ctx := context.Background()
+	now := time.Now()
+
+	d, err := client.Discover(ctx)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	authURL, err := url.Parse(client.AuthCodeURL(d, "st", "n1", "verifier-123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := authURL.Query()
+	if q.Get("code_challenge") != pkceChallenge("verifier-123") || q.Get("code_challenge_method") != "S256" || q.Get("nonce") != "n1" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	p.verifier = "verifier-123"
+	p.claims = map[string]interface{}{
+		"iss": p.server.URL, "aud": "bre-admin", "sub": "u-1", "nonce": "n1",
+		"exp": now.Add(5 * time.Minute).Unix(), "preferred_username": "alice",
+		"groups": []string{"news-editors"},
+	}
+	if _, err := client.Exchange(ctx, d, "good-code", "wrong-verifier"); err == nil {
+		t.Fatal("exchange with wrong verifier should fail")
+	}
+	raw, err := client.Exchange(ctx, d, "good-code", "verifier-123")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	claims, err := client.VerifyIDToken(ctx, d, raw, "n1", now)
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims.String("sub") != "u-1" || len(claims.Strings("groups")) != 1 {
+		t.Fatalf("unexpected claims %v", claims)
+	}
+
+	if _, err := client.VerifyIDToken(ctx, d, raw, "other", now); !errors.Is(err, ErrOIDCToken) {
+		t.Fatalf("nonce mismatch should fail, got %v", err)
+	}
+	if _, err := client.VerifyIDToken(ctx, d, raw, "n1", now.Add(time.Hour)); !errors.Is(err, ErrOIDCToken) {
+		t.Fatalf("expired token should fail, got %v", err)
+	}
+
+	bad := func(mutate func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{}
+		for k, v := range p.claims {
+			c[k] = v
+		}
+		mutate(c)
+		return c
+	}
+	cases := map[string]string{
+		"audience": p.sign(t, "RS256", "k1", bad(func(c map[string]interface{}) { c["aud"] = "other-client" })),
+		"issuer":   p.sign(t, "RS256", "k1", bad(func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" })),
+		"alg":      p.sign(t, "RS384", "k1", p.claims),
+		"kid":      p.sign(t, "RS256", "k2", p.claims),
+		"tampered": raw[:strings.LastIndex(raw, ".")] + "." + base64.RawURLEncoding.EncodeToString([]byte("sig")),
+	}
+	for name, token := range cases {
+		if _, err := client.VerifyIDToken(ctx, d, token, "n1", now); !errors.Is(err, ErrOIDCToken) {
+			t.Fatalf("%s: expected ErrOIDCToken, got %v", name, err)
+		}
+	}
+}
+
+func TestOIDCRoleFor(t *testing.T) {
+	cfg := config.OIDCConfig{RoleMapping: map[string]string{"news-editors": "editor", "ops": "operator", "admins": "owner", "bogus": "root"}}
+	if role, ok := OIDCRoleFor(cfg, []string{"ops", "news-editors"}); !ok || role != models.RoleEditor {
+		t.Fatalf("OIDCRoleFor = %s, %v", role, ok)
+	}
+	if role, ok := OIDCRoleFor(cfg, []string{"ops", "admins"}); !ok || role != models.RoleOwner {
+		t.Fatalf("OIDCRoleFor = %s, %v", role, ok)
+	}
+	if _, ok := OIDCRoleFor(cfg, []string{"bogus", "others"}); ok {
+		t.Fatal("unmapped groups without default role should be rejected")
+	}
+	cfg.DefaultRole = "viewer"
+	if role, ok := OIDCRoleFor(cfg, nil); !ok || role != models.RoleViewer {
+		t.Fatalf("default role = %s, %v", role, ok)
+	}
+}
+
+func TestCheckOIDCEmailDomain(t *testing.T) {
+	cfg := config.OIDCConfig{AllowedDomains: []string{"@example.com"}}
+	cases := []struct {
+		claims OIDCClaims
+		ok     bool
+	}{
+		{OIDCClaims{"email": "a@Example.com", "email_verified": true}, true},
+		{OIDCClaims{"email": "a@example.com", "email_verified": false}, false},
+		{OIDCClaims{"email": "a@example.com.evil.io", "email_verified": true}, false},
+		{OIDCClaims{"email_verified": true}, false},
+	}
+	for _, tc := range cases {
+		if err := checkOIDCEmailDomain(cfg, tc.claims); (err == nil) != tc.ok {
+			t.Fatalf("checkOIDCEmailDomain(%v) = %v", tc.claims, err)
+		}
+	}
+	if err := checkOIDCEmailDomain(config.OIDCConfig{}, OIDCClaims{}); err != nil {
+		t.Fatalf("no allow list should accept any account, got %v", err)
+	}
+}
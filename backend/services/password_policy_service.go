@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWeakPassword           = errors.New("password does not meet the policy")
+	ErrPasswordReused         = errors.New("new password must differ from the current one")
+	ErrNoLocalPassword        = errors.New("account signs in with sso and has no local password")
+	ErrPasswordChangeRequired = errors.New("password change required")
+)
+
+// commonPasswords 内置的常见弱密码，比较时忽略大小写
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1", "password123",
+	"passw0rd", "p@ssw0rd", "p@ssword1", "qwerty", "qwerty123", "qwertyuiop", "1q2w3e4r",
+	"1qaz2wsx", "1qaz@wsx", "abc123", "abcd1234", "admin", "admin123", "admin@123", "administrator",
+	"letmein", "welcome", "welcome1", "iloveyou", "111111", "000000", "88888888", "666666",
+	"a123456", "aa123456", "woaini1314", "zxcvbnm", "changeme", "default",
+}
+
+// PasswordPolicy 密码强度和有效期，MaxAge 为 0 表示不过期
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+	MaxAge     time.Duration
+	Blocklist  map[string]bool
+}
+
+var (
+	blocklistMu    sync.Mutex
+	blocklistCache = map[string]map[string]bool{}
+)
+
+// loadPasswordBlocklist 合并内置列表和配置的文件，按文件路径缓存；文件读取失败时只使用内置列表
+func loadPasswordBlocklist(path string) map[string]bool {
+	blocklistMu.Lock()
+	defer blocklistMu.Unlock()
+	if list, ok := blocklistCache[path]; ok {
+		return list
+	}
+	list := map[string]bool{}
+	for _, p := range commonPasswords {
+		list[p] = true
+	}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf("读取弱密码列表失败: %v\n", err)
+		} else {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				if line := strings.ToLower(strings.TrimSpace(scanner.Text())); line != "" && !strings.HasPrefix(line, "#") {
+					list[line] = true
+				}
+			}
+			f.Close()
+		}
+	}
+	blocklistCache[path] = list
+	return list
+}
+
+// CurrentPasswordPolicy 读取配置中的密码策略，未配置的项使用默认值
+func CurrentPasswordPolicy() PasswordPolicy {
+	cfg := config.AppConfig.Password
+	policy := PasswordPolicy{MinLength: 10, MinClasses: 3, Blocklist: loadPasswordBlocklist(cfg.BlocklistFile)}
+	if cfg.MinLength > 0 {
+		policy.MinLength = cfg.MinLength
+	}
+	if cfg.MinClasses > 0 {
+		policy.MinClasses = cfg.MinClasses
+	}
+	if cfg.MaxAgeDays > 0 {
+		policy.MaxAge = time.Duration(cfg.MaxAgeDays) * 24 * time.Hour
+	}
+	return policy
+}
+
+// passwordClasses 统计包含的字符类别：小写、大写、数字、其他符号
+func passwordClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Check 校验密码强度，不通过时返回包装了 ErrWeakPassword 的错误，说明具体原因
+func (p PasswordPolicy) Check(username, password string) error {
+	if n := len([]rune(password)); n < p.MinLength {
+		return fmt.Errorf("%w: at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	// bcrypt 只使用前 72 字节，更长的部分不参与校验
+	if len(password) > 72 {
+		return fmt.Errorf("%w: at most 72 bytes", ErrWeakPassword)
+	}
+	if passwordClasses(password) < p.MinClasses {
+		return fmt.Errorf("%w: use at least %d of lowercase, uppercase, digits and symbols", ErrWeakPassword, p.MinClasses)
+	}
+	lower := strings.ToLower(password)
+	if p.Blocklist[lower] {
+		return fmt.Errorf("%w: too common", ErrWeakPassword)
+	}
+	if name := strings.ToLower(strings.TrimSpace(username)); len(name) >= 3 && strings.Contains(lower, name) {
+		return fmt.Errorf("%w: must not contain the username", ErrWeakPassword)
+	}
+	return nil
+}
+
+// PasswordChangeRequired 账号须先修改密码：被标记为临时密码，或超过有效期；单点登录账号没有本地密码，不受限制
+func (p PasswordPolicy) PasswordChangeRequired(user *models.AdminUser, now time.Time) bool {
+	if user.PasswordHash == "" {
+		return false
+	}
+	if user.MustChangePassword {
+		return true
+	}
+	if p.MaxAge <= 0 {
+		return false
+	}
+	changed := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changed = *user.PasswordChangedAt
+	}
+	return !now.Before(changed.Add(p.MaxAge))
+}
+
+// SetAdminPassword 校验策略后更新密码；mustChange 表示这是他人设置的临时密码，登录后须修改
+func SetAdminPassword(db *gorm.DB, policy PasswordPolicy, user *models.AdminUser, password string, mustChange bool, now time.Time) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if err := policy.Check(user.Username, password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"password_hash":        string(hash),
+		"password_changed_at":  now,
+		"must_change_password": mustChange,
+	}
+	if err := db.Model(&models.AdminUser{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	user.PasswordHash = string(hash)
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = mustChange
+	return nil
+}
+
+// ChangeOwnPassword 管理员修改自己的密码，需验证旧密码；成功后注销除 keepSessionHash 外的其他会话
+func ChangeOwnPassword(db *gorm.DB, policy PasswordPolicy, user *models.AdminUser, oldPassword, newPassword, keepSessionHash string, now time.Time) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if user.PasswordHash == "" {
+		return ErrNoLocalPassword
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)); err != nil {
+		return ErrInvalidCredentials
+	}
+	if oldPassword == newPassword {
+		return ErrPasswordReused
+	}
+	if err := SetAdminPassword(db, policy, user, newPassword, false, now); err != nil {
+		return err
+	}
+	if _, err := RevokeOtherAdminSessions(db, user.ID, keepSessionHash); err != nil {
+		fmt.Printf("修改密码后注销其他会话失败: %v\n", err)
+	}
+	return nil
+}
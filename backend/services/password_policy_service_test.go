@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, MinClasses: 3, Blocklist: loadPasswordBlocklist("")}
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"Tr0ub4dor&3x", true},
+		{"Short1!", false},                  // 太短
+		{"alllowercaseletters", false},      // 字符类别不足
+		{"Password123", false},              // 常见密码
+		{"P@ssw0rd", false},                 // 太短且在列表中
+		{"Alice-Secret-2024", false},        // 包含用户名
+		{"长密码也可以用中文Ab1", true},              // 中文计入符号类
+		{strings.Repeat("aA1-", 20), false}, // 超过 bcrypt 的 72 字节
+	}
+	for _, tc := range cases {
+		err := policy.Check("alice", tc.password)
+		if (err == nil) != tc.ok {
+			t.Fatalf("Check(%q) = %v, want ok=%v", tc.password, err, tc.ok)
+		}
+		if err != nil && !errors.Is(err, ErrWeakPassword) {
+			t.Fatalf("Check(%q) should wrap ErrWeakPassword, got %v", tc.password, err)
+		}
+	}
+
+	policy.MinLength = 6
+	if err := policy.Check("bob", "Qwerty123"); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("blocklisted password should be rejected regardless of case, got %v", err)
+	}
+}
+
+func TestPasswordChangeRequired(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	old := now.Add(-100 * 24 * time.Hour)
+	recent := now.Add(-10 * 24 * time.Hour)
+	policy := PasswordPolicy{MaxAge: 90 * 24 * time.Hour}
+
+	cases := []struct {
+		name string
+		user models.AdminUser
+		want bool
+	}{
+		{"fresh", models.AdminUser{PasswordHash: "x", PasswordChangedAt: &recent}, false},
+		{"expired", models.AdminUser{PasswordHash: "x", PasswordChangedAt: &old}, true},
+		{"legacy uses created_at", models.AdminUser{PasswordHash: "x", CreatedAt: old}, true},
+		{"temporary", models.AdminUser{PasswordHash: "x", PasswordChangedAt: &recent, MustChangePassword: true}, true},
+		{"sso only", models.AdminUser{CreatedAt: old, MustChangePassword: true}, false},
+	}
+	for _, tc := range cases {
+		if got := policy.PasswordChangeRequired(&tc.user, now); got != tc.want {
+			t.Fatalf("%s: PasswordChangeRequired = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if (PasswordPolicy{}).PasswordChangeRequired(&models.AdminUser{PasswordHash: "x", CreatedAt: old}, now) {
+		t.Fatal("zero max age should never expire")
+	}
+}
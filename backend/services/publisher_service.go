@@ -0,0 +1,482 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PublisherDingTalk = "dingtalk"
+	PublisherFeishu   = "feishu"
+	PublisherWeCom    = "wecom"
+	PublisherTelegram = "telegram"
+
+	defaultTelegramAPIBase = "https://api.telegram.org"
+)
+
+var publisherHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+var batchTypeLabels = map[models.BatchType]string{
+	models.BatchMorning: "早报",
+	models.BatchNoon:    "午报",
+	models.BatchEvening: "晚报",
+}
+
+// BatchTypeLabel 返回批次类型的中文名称，未知类型返回空串
+func BatchTypeLabel(t models.BatchType) string {
+	return batchTypeLabels[t]
+}
+
+// 默认模板：钉钉 / 飞书 / 企业微信均支持 Markdown，标题和链接需转义
+const defaultMarkdownTemplate = `### {{md .Title}}
+{{range $i, $n := .News}}{{inc $i}}. [{{md $n.Title}}]({{mdurl $n.Url}})
+{{end}}{{if .Analysis}}
+#### {{.AnalysisLabel}}
+{{.Analysis.Content}}
+{{end}}`
+
+// Telegram 使用 HTML parse_mode，需要转义
+const defaultTelegramTemplate = `<b>{{html .Title}}</b>
+{{range $i, $n := .News}}{{inc $i}}. <a href="{{html $n.Url}}">{{html $n.Title}}</a>
+{{end}}{{if .Analysis}}
+<b>{{html .AnalysisLabel}}</b>
+{{html .Analysis.Content}}
+{{end}}`
+
+// PublishData 渲染模板时可用的数据
+type PublishData struct {
+	Title         string
+	Batch         models.BatchLog
+	News          []models.NewsItem
+	Analysis      *models.Analysis
+	AnalysisLabel string
+}
+
+// PublishMessage 渲染后的待推送消息
+type PublishMessage struct {
+	Title string
+	Text  string
+}
+
+// Publisher 群聊机器人推送适配器
+type Publisher interface {
+	Name() string
+	Publish(msg PublishMessage) error
+}
+
+// NewPublisher 根据配置创建推送适配器
+func NewPublisher(cfg config.PublisherConfig) (Publisher, error) {
+	switch cfg.Type {
+	case PublisherDingTalk:
+		if cfg.Webhook == "" {
+			return nil, errors.New("dingtalk webhook empty")
+		}
+		return &dingTalkPublisher{cfg: cfg}, nil
+	case PublisherFeishu:
+		if cfg.Webhook == "" {
+			return nil, errors.New("feishu webhook empty")
+		}
+		return &feishuPublisher{cfg: cfg}, nil
+	case PublisherWeCom:
+		if cfg.Webhook == "" {
+			return nil, errors.New("wecom webhook empty")
+		}
+		return &weComPublisher{cfg: cfg}, nil
+	case PublisherTelegram:
+		if cfg.BotToken == "" || cfg.ChatID == "" {
+			return nil, errors.New("telegram bot_token or chat_id empty")
+		}
+		return &telegramPublisher{cfg: cfg}, nil
+	}
+	return nil, fmt.Errorf("unknown publisher type: %s", cfg.Type)
+}
+
+// BuildPublishData 读取批次的新闻条目和最新分析
+func BuildPublishData(db *gorm.DB, batchID uint, analysisType models.AnalysisType) (*PublishData, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var batch models.BatchLog
+	if err := db.Where("id = ?", batchID).First(&batch).Error; err != nil {
+		return nil, err
+	}
+
+	data := &PublishData{
+		Title: fmt.Sprintf("%s %s", batch.Date, batchTypeLabels[batch.Type]),
+		Batch: batch,
+	}
+	if err := db.Where("batch_id = ? AND status = ?", batch.ID, models.StatusPublished).Order("sort_rank asc, id asc").Find(&data.News).Error; err != nil {
+		return nil, err
+	}
+
+	if analysisType == "" {
+		analysisType = models.Analysis3Day
+	}
+	var analysis models.Analysis
+	if err := db.Where("type = ? AND status = ?", analysisType, models.StatusPublished).Order("created_at desc").First(&analysis).Error; err == nil {
+		data.Analysis = &analysis
+	}
+	if analysisType == models.Analysis7Day {
+		data.AnalysisLabel = "7 日财经分析"
+	} else {
+		data.AnalysisLabel = "3 日财经分析"
+	}
+	return data, nil
+}
+
+// RenderPublishMessage 使用渠道模板渲染消息
+func RenderPublishMessage(cfg config.PublisherConfig, data *PublishData) (PublishMessage, error) {
+	text := cfg.Template
+	if text == "" {
+		if cfg.Type == PublisherTelegram {
+			text = defaultTelegramTemplate
+		} else {
+			text = defaultMarkdownTemplate
+		}
+	}
+
+	tmpl, err := template.New(cfg.Name).Funcs(template.FuncMap{
+		"inc":   func(i int) int { return i + 1 },
+		"md":    escapeMarkdown,
+		"mdurl": escapeMarkdownURL,
+	}).Parse(text)
+	if err != nil {
+		return PublishMessage{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return PublishMessage{}, err
+	}
+	return PublishMessage{Title: data.Title, Text: strings.TrimSpace(buf.String())}, nil
+}
+
+// PublishBatch 将批次推送到所有启用的渠道，单个渠道失败不影响其他渠道
+func PublishBatch(db *gorm.DB, batchID uint, channels []config.PublisherConfig) error {
+	var errs []error
+	// 推送数据只与分析类型有关，同一类型只读取一次
+	built := map[models.AnalysisType]*PublishData{}
+	for _, cfg := range channels {
+		if !cfg.Enabled {
+			continue
+		}
+
+		analysisType := models.AnalysisType(cfg.AnalysisType)
+		data, ok := built[analysisType]
+		if !ok {
+			var err error
+			if data, err = BuildPublishData(db, batchID, analysisType); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+				continue
+			}
+			built[analysisType] = data
+		}
+		if !publisherAcceptsBatch(cfg, data.Batch.Type) {
+			continue
+		}
+
+		publisher, err := NewPublisher(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+			continue
+		}
+		msg, err := RenderPublishMessage(cfg, data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+			continue
+		}
+		if err := publisher.Publish(msg); err != nil {
+			fmt.Printf("推送到 %s 失败: %v\n", publisher.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+			continue
+		}
+		fmt.Printf("已推送批次 %d 到 %s\n", batchID, publisher.Name())
+	}
+	return errors.Join(errs...)
+}
+
+func publisherAcceptsBatch(cfg config.PublisherConfig, batchType models.BatchType) bool {
+	if len(cfg.BatchTypes) == 0 {
+		return true
+	}
+	for _, t := range cfg.BatchTypes {
+		if t == string(batchType) {
+			return true
+		}
+	}
+	return false
+}
+
+// postPublisherJSON 发送 JSON 请求并返回响应体
+func postPublisherJSON(endpoint string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := publisherHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// truncateText 按字节截断，保证不切断 UTF-8 字符
+func truncateText(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	const suffix = "\n..."
+	cut := maxBytes - len(suffix)
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + suffix
+}
+
+// markdownEscaper 转义会破坏链接和强调语法的 Markdown 字符
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+)
+
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(text), " "))
+}
+
+// escapeMarkdownURL 编码链接中的空白和括号，避免提前结束 Markdown 链接
+func escapeMarkdownURL(link string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(strings.TrimSpace(link))
+}
+
+var telegramTagPattern = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9-]*)[^>]*>`)
+
+// truncateHTML 截断已转义的 HTML 文本：不切断标签和实体，并补齐未闭合的标签
+func truncateHTML(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	const suffix = "\n..."
+	cut := maxBytes - len(suffix)
+	for cut > 0 {
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		head := text[:cut]
+		if i := strings.LastIndexByte(head, '<'); i > strings.LastIndexByte(head, '>') {
+			head = head[:i]
+		}
+		// 文本中的 & 都已转义为以 ; 结尾的实体，最后一个 ; 之后的 & 说明实体被截断
+		if i := strings.LastIndexByte(head, '&'); i > strings.LastIndexByte(head, ';') {
+			head = head[:i]
+		}
+		var open []string
+		for _, m := range telegramTagPattern.FindAllStringSubmatch(head, -1) {
+			name := strings.ToLower(m[2])
+			if m[1] == "" {
+				open = append(open, name)
+				continue
+			}
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					open = open[:i]
+					break
+				}
+			}
+		}
+		var closing strings.Builder
+		for i := len(open) - 1; i >= 0; i-- {
+			closing.WriteString("</" + open[i] + ">")
+		}
+		if over := len(head) + closing.Len() + len(suffix) - maxBytes; over > 0 {
+			cut = len(head) - over
+			continue
+		}
+		return head + closing.String() + suffix
+	}
+	return ""
+}
+
+func hmacSignBase64(key, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type dingTalkPublisher struct {
+	cfg config.PublisherConfig
+}
+
+func (p *dingTalkPublisher) Name() string { return "dingtalk:" + p.cfg.Name }
+
+func (p *dingTalkPublisher) Publish(msg PublishMessage) error {
+	endpoint := p.cfg.Webhook
+	if p.cfg.Secret != "" {
+		// 钉钉加签：HmacSHA256(timestamp + "\n" + secret)，密钥为 secret
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		sign := hmacSignBase64(p.cfg.Secret, timestamp+"\n"+p.cfg.Secret)
+		endpoint = appendQuery(endpoint, url.Values{"timestamp": {timestamp}, "sign": {sign}})
+	}
+
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": msg.Title,
+			"text":  truncateText(msg.Text, 20000),
+		},
+	}
+	body, err := postPublisherJSON(endpoint, payload)
+	if err != nil {
+		return err
+	}
+	return checkErrcode(body)
+}
+
+type feishuPublisher struct {
+	cfg config.PublisherConfig
+}
+
+func (p *feishuPublisher) Name() string { return "feishu:" + p.cfg.Name }
+
+func (p *feishuPublisher) Publish(msg PublishMessage) error {
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title": map[string]string{"tag": "plain_text", "content": msg.Title},
+			},
+			"elements": []interface{}{
+				map[string]string{"tag": "markdown", "content": truncateText(msg.Text, 20000)},
+			},
+		},
+	}
+	if p.cfg.Secret != "" {
+		// 飞书加签：以 timestamp + "\n" + secret 为密钥对空串做 HmacSHA256
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = hmacSignBase64(timestamp+"\n"+p.cfg.Secret, "")
+	}
+
+	body, err := postPublisherJSON(p.cfg.Webhook, payload)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Code       int    `json:"code"`
+		Msg        string `json:"msg"`
+		StatusCode int    `json:"StatusCode"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 || result.StatusCode != 0 {
+		return fmt.Errorf("feishu error %d: %s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+type weComPublisher struct {
+	cfg config.PublisherConfig
+}
+
+func (p *weComPublisher) Name() string { return "wecom:" + p.cfg.Name }
+
+func (p *weComPublisher) Publish(msg PublishMessage) error {
+	// 企业微信 markdown 内容最长 4096 字节
+	payload := map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": truncateText(msg.Text, 4096)},
+	}
+	body, err := postPublisherJSON(p.cfg.Webhook, payload)
+	if err != nil {
+		return err
+	}
+	return checkErrcode(body)
+}
+
+type telegramPublisher struct {
+	cfg config.PublisherConfig
+}
+
+func (p *telegramPublisher) Name() string { return "telegram:" + p.cfg.Name }
+
+func (p *telegramPublisher) Publish(msg PublishMessage) error {
+	apiBase := strings.TrimRight(p.cfg.APIBase, "/")
+	if apiBase == "" {
+		apiBase = defaultTelegramAPIBase
+	}
+	payload := map[string]interface{}{
+		"chat_id":                  p.cfg.ChatID,
+		"text":                     truncateHTML(msg.Text, 4096),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	body, err := postPublisherJSON(apiBase+"/bot"+p.cfg.BotToken+"/sendMessage", payload)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram error: %s", result.Description)
+	}
+	return nil
+}
+
+// checkErrcode 解析钉钉 / 企业微信的 {errcode, errmsg} 响应
+func checkErrcode(body []byte) error {
+	var result struct {
+		Errcode int    `json:"errcode"`
+		Errmsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if result.Errcode != 0 {
+		return fmt.Errorf("errcode %d: %s", result.Errcode, result.Errmsg)
+	}
+	return nil
+}
+
+func appendQuery(endpoint string, values url.Values) string {
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + values.Encode()
+	}
+	return endpoint + "?" + values.Encode()
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testPublishData() *PublishData {
+	return &PublishData{
+		Title: "2026-01-02 早报",
+		Batch: models.BatchLog{ID: 1, Type: models.BatchMorning, Date: "2026-01-02"},
+		News: []models.NewsItem{
+			{ID: 1, Title: "央行降准 <0.5%>", Url: "https://example.com/a"},
+			{ID: 2, Title: "油价上涨", Url: "https://example.com/b"},
+		},
+		Analysis:      &models.Analysis{ID: 1, Type: models.Analysis3Day, Content: "利好银行板块"},
+		AnalysisLabel: "3 日财经分析",
+	}
+}
+
+// capturePublisherServer 启动本地替身服务器，记录请求并返回固定响应
+func capturePublisherServer(t *testing.T, response string) (*httptest.Server, *map[string]interface{}, *string) {
+	t.Helper()
+	var payload map[string]interface{}
+	var requestURI string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.URL.RequestURI()
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &payload, &requestURI
+}
+
+func TestRenderPublishMessageDefaultTemplates(t *testing.T) {
+	msg, err := RenderPublishMessage(config.PublisherConfig{Type: PublisherDingTalk}, testPublishData())
+	if err != nil {
+		t.Fatalf("render markdown: %v", err)
+	}
+	if !strings.Contains(msg.Text, "1. [央行降准 <0.5%>](https://example.com/a)") || !strings.Contains(msg.Text, "利好银行板块") {
+		t.Fatalf("unexpected markdown text: %s", msg.Text)
+	}
+
+	msg, err = RenderPublishMessage(config.PublisherConfig{Type: PublisherTelegram}, testPublishData())
+	if err != nil {
+		t.Fatalf("render telegram: %v", err)
+	}
+	if !strings.Contains(msg.Text, "央行降准 &lt;0.5%&gt;") {
+		t.Fatalf("telegram text not escaped: %s", msg.Text)
+	}
+
+	msg, err = RenderPublishMessage(config.PublisherConfig{Type: PublisherWeCom, Template: "{{.Title}} 共 {{len .News}} 条"}, testPublishData())
+	if err != nil {
+		t.Fatalf("render custom: %v", err)
+	}
+	if msg.Text != "2026-01-02 早报 共 2 条" {
+		t.Fatalf("unexpected custom text: %q", msg.Text)
+	}
+}
+
+func TestDingTalkPublisher(t *testing.T) {
+	srv, payload, uri := capturePublisherServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	p, err := NewPublisher(config.PublisherConfig{Name: "g", Type: PublisherDingTalk, Webhook: srv.URL + "/robot/send?access_token=x", Secret: "SEC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Publish(PublishMessage{Title: "t", Text: "body"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if (*payload)["msgtype"] != "markdown" {
+		t.Fatalf("unexpected payload: %v", *payload)
+	}
+	if !strings.Contains(*uri, "access_token=x&") || !strings.Contains(*uri, "sign=") || !strings.Contains(*uri, "timestamp=") {
+		t.Fatalf("missing signature in %s", *uri)
+	}
+}
+
+func TestFeishuPublisher(t *testing.T) {
+	srv, payload, _ := capturePublisherServer(t, `{"code":0,"msg":"success"}`)
+	p, _ := NewPublisher(config.PublisherConfig{Name: "g", Type: PublisherFeishu, Webhook: srv.URL, Secret: "SEC"})
+	if err := p.Publish(PublishMessage{Title: "t", Text: "body"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if (*payload)["msg_type"] != "interactive" || (*payload)["sign"] == nil {
+		t.Fatalf("unexpected payload: %v", *payload)
+	}
+
+	srv, _, _ = capturePublisherServer(t, `{"code":19021,"msg":"sign match fail"}`)
+	p, _ = NewPublisher(config.PublisherConfig{Name: "g", Type: PublisherFeishu, Webhook: srv.URL})
+	if err := p.Publish(PublishMessage{Title: "t", Text: "body"}); err == nil {
+		t.Fatal("expected feishu error")
+	}
+}
+
+func TestWeComPublisherTruncates(t *testing.T) {
+	srv, payload, _ := capturePublisherServer(t, `{"errcode":0,"errmsg":"ok"}`)
+	p, _ := NewPublisher(config.PublisherConfig{Name: "g", Type: PublisherWeCom, Webhook: srv.URL})
+	if err := p.Publish(PublishMessage{Title: "t", Text: strings.Repeat("新闻", 2000)}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	content := (*payload)["markdown"].(map[string]interface{})["content"].(string)
+	if len(content) > 4096 || !strings.HasSuffix(content, "...") {
+		t.Fatalf("content not truncated: %d bytes", len(content))
+	}
+}
+
+func TestTelegramPublisher(t *testing.T) {
+	srv, payload, uri := capturePublisherServer(t, `{"ok":true}`)
+	p, _ := NewPublisher(config.PublisherConfig{Name: "g", Type: PublisherTelegram, APIBase: srv.URL, BotToken: "123:abc", ChatID: "-100"})
+	if err := p.Publish(PublishMessage{Title: "t", Text: "body"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if *uri != "/bot123:abc/sendMessage" || (*payload)["chat_id"] != "-100" || (*payload)["parse_mode"] != "HTML" {
+		t.Fatalf("unexpected request %s %v", *uri, *payload)
+	}
+}
+
+func TestMarkdownTitleEscaped(t *testing.T) {
+	data := testPublishData()
+	data.News = []models.NewsItem{{ID: 1, Title: "美股*大涨* [快讯]\n第二行", Url: "https://example.com/a (1)"}}
+	msg, err := RenderPublishMessage(config.PublisherConfig{Type: PublisherFeishu}, data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := `1. [美股\*大涨\* \[快讯\] 第二行](https://example.com/a%20%281%29)`
+	if !strings.Contains(msg.Text, want) {
+		t.Fatalf("title not escaped: %s", msg.Text)
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	text := "<b>标题</b>\n" + strings.Repeat(`1. <a href="https://example.com/a?x=1&amp;y=2">AT&amp;T 新闻</a>`+"\n", 200)
+	for _, max := range []int{60, 100, 150, 4096} {
+		got := truncateHTML(text, max)
+		if len(got) > max {
+			t.Fatalf("max %d: got %d bytes", max, len(got))
+		}
+		if strings.Count(got, "<a ") != strings.Count(got, "</a>") || strings.Count(got, "<b>") != strings.Count(got, "</b>") {
+			t.Fatalf("max %d: unbalanced tags: %s", max, got)
+		}
+		if i, j := strings.LastIndex(got, "&"), strings.LastIndex(got, ";"); i > j {
+			t.Fatalf("max %d: broken entity: %s", max, got)
+		}
+	}
+	if got := truncateHTML("<b>short</b>", 4096); got != "<b>short</b>" {
+		t.Fatalf("short text changed: %s", got)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permission 管理端接口所需的权限
+type Permission string
+
+const (
+	PermContentRead   Permission = "content:read"   // 查看后台数据
+	PermContentEdit   Permission = "content:edit"   // 编辑新闻、分析、标签和排序
+	PermContentReview Permission = "content:review" // 审核、发布和撤回
+	PermContentDelete Permission = "content:delete" // 删除批次、新闻和分析
+	PermSiteManage    Permission = "site:manage"    // 维护网址导航
+	PermSourceManage  Permission = "source:manage"  // 维护新闻源
+	PermTaskRun       Permission = "task:run"       // 触发更新、重新分类、翻译、抽取等任务
+	PermUserManage    Permission = "user:manage"    // 管理员账号和角色
+	PermAuditRead     Permission = "audit:read"     // 查看审计日志
+)
+
+// RolePermissions 各角色拥有的权限
+var RolePermissions = map[models.AdminRole][]Permission{
+	models.RoleOwner: {
+		PermContentRead, PermContentEdit, PermContentReview, PermContentDelete,
+		PermSiteManage, PermSourceManage, PermTaskRun, PermUserManage, PermAuditRead,
+	},
+	models.RoleEditor:   {PermContentRead, PermContentEdit, PermContentReview, PermSiteManage},
+	models.RoleOperator: {PermContentRead, PermSourceManage, PermTaskRun},
+	models.RoleViewer:   {PermContentRead},
+}
+
+var ErrLastOwner = errors.New("cannot remove the last owner")
+
+// IsAdminRole 判断是否为合法角色
+func IsAdminRole(role models.AdminRole) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission 判断角色是否拥有权限
+func HasPermission(role models.AdminRole, perm Permission) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// lockOtherOwners 锁定除 userID 外的 owner 并返回数量，用于防止并发操作移除最后一个 owner
+func lockOtherOwners(tx *gorm.DB, userID uint) (int, error) {
+	var ids []uint
+	err := tx.Model(&models.AdminUser{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND id <> ?", models.RoleOwner, userID).Pluck("id", &ids).Error
+	return len(ids), err
+}
+
+// SetAdminRole 修改管理员角色，不能将最后一个 owner 降级
+func SetAdminRole(db *gorm.DB, userID uint, role models.AdminRole) (*models.AdminUser, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	if !IsAdminRole(role) {
+		return nil, errors.New("invalid role")
+	}
+	var user models.AdminUser
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.Role == models.RoleOwner && role != models.RoleOwner {
+			owners, err := lockOtherOwners(tx, user.ID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return ErrLastOwner
+			}
+		}
+		user.Role = role
+		return tx.Model(&user).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteAdminUser 删除管理员及其会话和恢复码，不能删除最后一个 owner
+func DeleteAdminUser(db *gorm.DB, userID uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.AdminUser
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.Role == models.RoleOwner {
+			owners, err := lockOtherOwners(tx, user.ID)
+			if err != nil {
+				return err
+			}
+			if owners == 0 {
+				return ErrLastOwner
+			}
+		}
+		for _, model := range []interface{}{&models.AdminSession{}, &models.AdminRecoveryCode{}, &models.AdminLoginChallenge{}} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		role models.AdminRole
+		perm Permission
+		want bool
+	}{
+		{models.RoleOwner, PermUserManage, true},
+		{models.RoleEditor, PermContentReview, true},
+		{models.RoleEditor, PermUserManage, false},
+		{models.RoleEditor, PermContentDelete, false},
+		{models.RoleOperator, PermTaskRun, true},
+		{models.RoleOperator, PermContentEdit, false},
+		{models.RoleViewer, PermContentRead, true},
+		{models.RoleViewer, PermContentEdit, false},
+		{"intern", PermContentRead, false},
+	}
+	for _, tc := range cases {
+		if got := HasPermission(tc.role, tc.perm); got != tc.want {
+			t.Fatalf("HasPermission(%s, %s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+	for role := range RolePermissions {
+		if !HasPermission(role, PermContentRead) {
+			t.Fatalf("every role should be able to read, %s cannot", role)
+		}
+	}
+	if IsAdminRole("") {
+		t.Fatal("empty role should be invalid")
+	}
+}
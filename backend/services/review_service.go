@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审核操作
+const (
+	ReviewSubmit   = "submit"   // 提交审核：draft → in_review
+	ReviewApprove  = "approve"  // 通过并发布：in_review → published
+	ReviewReject   = "reject"   // 驳回：in_review → rejected
+	ReviewWithdraw = "withdraw" // 撤回为草稿：in_review / published / rejected → draft
+)
+
+var (
+	ErrUnknownReviewAction = errors.New("unknown review action")
+	ErrInvalidTransition   = errors.New("invalid status transition")
+)
+
+var reviewTransitions = map[string]struct {
+	from []models.PublishStatus
+	to   models.PublishStatus
+}{
+	ReviewSubmit:   {[]models.PublishStatus{models.StatusDraft}, models.StatusInReview},
+	ReviewApprove:  {[]models.PublishStatus{models.StatusInReview}, models.StatusPublished},
+	ReviewReject:   {[]models.PublishStatus{models.StatusInReview}, models.StatusRejected},
+	ReviewWithdraw: {[]models.PublishStatus{models.StatusInReview, models.StatusPublished, models.StatusRejected}, models.StatusDraft},
+}
+
+// ReviewTarget 返回对状态 from 执行 action 后的新状态
+func ReviewTarget(from models.PublishStatus, action string) (models.PublishStatus, error) {
+	t, ok := reviewTransitions[action]
+	if !ok {
+		return "", ErrUnknownReviewAction
+	}
+	if from == "" {
+		from = models.StatusPublished
+	}
+	for _, s := range t.from {
+		if s == from {
+			return t.to, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s cannot %s", ErrInvalidTransition, from, action)
+}
+
+// InitialBatchStatus 新批次的初始状态：需要审核的批次类型进入待审核，否则直接发布
+func InitialBatchStatus(batchType models.BatchType, requireApproval []string) models.PublishStatus {
+	for _, t := range requireApproval {
+		if t == string(batchType) {
+			return models.StatusInReview
+		}
+	}
+	return models.StatusPublished
+}
+
+// cascadeItemStatus 批次审核后批次内一条新闻或分析的状态：单独驳回的条目保持驳回；
+// 驳回批次不改动条目，条目的驳回状态只表示单独驳回，撤回后整批条目可以恢复为草稿重新提交
+func cascadeItemStatus(item models.PublishStatus, action string, target models.PublishStatus) models.PublishStatus {
+	if item == models.StatusRejected || action == ReviewReject {
+		return item
+	}
+	return target
+}
+
+// ReviewBatch 审核批次，并将批次内未被单独驳回的新闻和分析同步为相同状态，驳回批次时条目不变。
+// 状态按读取时的旧值条件更新，并发审核只有一个成功；返回值 deliver 表示批次首次发布，调用方应推送群聊并生成订阅邮件
+func ReviewBatch(db *gorm.DB, batchID uint, action, note string, reviewerID uint, now time.Time) (batch *models.BatchLog, deliver bool, err error) {
+	if db == nil {
+		return nil, false, errors.New("db is nil")
+	}
+	var row models.BatchLog
+	if err := db.First(&row, batchID).Error; err != nil {
+		return nil, false, err
+	}
+	target, err := ReviewTarget(row.Status, action)
+	if err != nil {
+		return nil, false, err
+	}
+
+	updates := map[string]interface{}{
+		"status":      target,
+		"review_note": truncateText(note, 512),
+		"reviewed_by": reviewerID,
+		"reviewed_at": now,
+	}
+	if target == models.StatusPublished && row.PublishedAt == nil {
+		updates["published_at"] = now
+		deliver = true
+	}
+	// 未经审核流程发布的历史批次撤回后再次发布时不重复推送
+	if row.Status == models.StatusPublished && row.PublishedAt == nil {
+		updates["published_at"] = row.CreatedAt
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BatchLog{}).Where("id = ? AND status = ?", row.ID, row.Status).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return fmt.Errorf("%w: batch status changed concurrently", ErrInvalidTransition)
+		}
+		if action == ReviewReject {
+			return nil
+		}
+		for _, model := range []interface{}{&models.NewsItem{}, &models.Analysis{}} {
+			if err := tx.Model(model).Where("batch_id = ? AND status <> ?", row.ID, models.StatusRejected).Update("status", target).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if err := db.First(&row, row.ID).Error; err != nil {
+		return nil, false, err
+	}
+	return &row, deliver, nil
+}
+
+// ReviewNews 单独审核一条新闻，如驳回批次中的幻觉条目
+func ReviewNews(db *gorm.DB, id uint, action string) (models.PublishStatus, error) {
+	return reviewRow(db, &models.NewsItem{}, id, action)
+}
+
+// ReviewAnalysis 单独审核一篇分析
+func ReviewAnalysis(db *gorm.DB, id uint, action string) (models.PublishStatus, error) {
+	return reviewRow(db, &models.Analysis{}, id, action)
+}
+
+func reviewRow(db *gorm.DB, model interface{}, id uint, action string) (models.PublishStatus, error) {
+	if db == nil {
+		return "", errors.New("db is nil")
+	}
+	var statuses []models.PublishStatus
+	if err := db.Model(model).Where("id = ?", id).Pluck("status", &statuses).Error; err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	target, err := ReviewTarget(statuses[0], action)
+	if err != nil {
+		return "", err
+	}
+	res := db.Model(model).Where("id = ? AND status = ?", id, statuses[0]).Update("status", target)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected != 1 {
+		return "", fmt.Errorf("%w: status changed concurrently", ErrInvalidTransition)
+	}
+	return target, nil
+}
+
+// DeliverBatch 推送已发布批次到群聊并生成订阅邮件，withAnalysis 时晚报附带每日分析邮件
+func DeliverBatch(db *gorm.DB, batch models.BatchLog, publishers []config.PublisherConfig, withAnalysis bool) {
+	if err := PublishBatch(db, batch.ID, publishers); err != nil {
+		fmt.Printf("群聊推送失败: %v\n", err)
+	}
+	if err := EnqueueBatchDigest(db, batch.ID); err != nil {
+		fmt.Printf("生成订阅邮件失败: %v\n", err)
+	}
+	if withAnalysis && batch.Type == models.BatchEvening {
+		if err := EnqueueAnalysisDigest(db, batch.ID); err != nil {
+			fmt.Printf("生成分析订阅邮件失败: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"testing"
+)
+
+func TestReviewTarget(t *testing.T) {
+	cases := []struct {
+		from   models.PublishStatus
+		action string
+		want   models.PublishStatus
+	}{
+		{models.StatusDraft, ReviewSubmit, models.StatusInReview},
+		{models.StatusInReview, ReviewApprove, models.StatusPublished},
+		{models.StatusInReview, ReviewReject, models.StatusRejected},
+		{models.StatusPublished, ReviewWithdraw, models.StatusDraft},
+		{"", ReviewWithdraw, models.StatusDraft}, // 历史数据视为已发布
+	}
+	for _, tc := range cases {
+		got, err := ReviewTarget(tc.from, tc.action)
+		if err != nil || got != tc.want {
+			t.Fatalf("ReviewTarget(%s, %s) = %s, %v; want %s", tc.from, tc.action, got, err, tc.want)
+		}
+	}
+
+	if _, err := ReviewTarget(models.StatusDraft, ReviewApprove); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("draft should not be approved directly, got %v", err)
+	}
+	if _, err := ReviewTarget(models.StatusPublished, ReviewReject); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("published should be withdrawn before rejecting, got %v", err)
+	}
+	if _, err := ReviewTarget(models.StatusDraft, "publish"); !errors.Is(err, ErrUnknownReviewAction) {
+		t.Fatalf("expected unknown action, got %v", err)
+	}
+}
+
+func TestInitialBatchStatus(t *testing.T) {
+	if got := InitialBatchStatus(models.BatchMorning, []string{"morning", "evening"}); got != models.StatusInReview {
+		t.Fatalf("morning should require approval, got %s", got)
+	}
+	if got := InitialBatchStatus(models.BatchNoon, []string{"morning", "evening"}); got != models.StatusPublished {
+		t.Fatalf("noon should be published directly, got %s", got)
+	}
+	if got := InitialBatchStatus(models.BatchEvening, nil); got != models.StatusPublished {
+		t.Fatalf("expected published without review config, got %s", got)
+	}
+}
+
+func TestBatchReviewCascade(t *testing.T) {
+	// 批次内两条新闻，第二条先被单独驳回
+	batch := models.StatusInReview
+	items := []models.PublishStatus{models.StatusInReview, models.StatusRejected}
+	for _, action := range []string{ReviewReject, ReviewWithdraw, ReviewSubmit, ReviewApprove} {
+		target, err := ReviewTarget(batch, action)
+		if err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+		batch = target
+		for i := range items {
+			items[i] = cascadeItemStatus(items[i], action, target)
+		}
+		if action == ReviewReject && items[0] != models.StatusInReview {
+			t.Fatalf("rejecting the batch should not reject its items, got %s", items[0])
+		}
+	}
+	if batch != models.StatusPublished || items[0] != models.StatusPublished {
+		t.Fatalf("reject → withdraw → submit → approve should publish the items, got batch %s items %v", batch, items)
+	}
+	if items[1] != models.StatusRejected {
+		t.Fatalf("individually rejected item should stay rejected, got %s", items[1])
+	}
+}
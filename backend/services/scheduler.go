@@ -11,14 +11,24 @@ import (
 )
 
 func RunUpdateTask() {
-	RunUpdateTaskWithDeps(config.DB, time.Now, GetDailyNews, AnalyzeNews, true)
+	RunUpdateTaskWithDeps(config.DB, time.Now, GetDailyNews, AnalyzeNews, CollectSourceNews, RankNews, true)
+}
+
+// RunNewsSourcePoll 抓取到期的 RSS/Atom 新闻源
+func RunNewsSourcePoll() {
+	PollNewsSources(config.DB, time.Now())
 }
 
 type NowFunc func() time.Time
 type GetDailyNewsFunc func() ([]NewsData, error)
 type AnalyzeNewsFunc func(newsContent string, days int) (string, error)
+type CollectSourceNewsFunc func(db *gorm.DB, now time.Time) ([]NewsData, error)
+type RankNewsFunc func(candidates []NewsData, limit int) ([]NewsData, error)
 
-func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNewsFunc, analyzeNews AnalyzeNewsFunc, runAnalysis bool) {
+// MaxBatchNews 每批次保存的新闻条数上限
+const MaxBatchNews = 20
+
+func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNewsFunc, analyzeNews AnalyzeNewsFunc, collectSourceNews CollectSourceNewsFunc, rankNews RankNewsFunc, runAnalysis bool) {
 	fmt.Println("开始执行定时更新任务...")
 
 	// 1. 确定批次类型 (早/中/晚)
@@ -35,6 +45,12 @@ func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNews
 	if analyzeNews == nil {
 		analyzeNews = AnalyzeNews
 	}
+	if collectSourceNews == nil {
+		collectSourceNews = CollectSourceNews
+	}
+	if rankNews == nil {
+		rankNews = RankNews
+	}
 
 	now := nowFn()
 	hour := now.Hour()
@@ -47,33 +63,73 @@ func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNews
 		batchType = models.BatchEvening // 晚报
 	}
 
-	// 3. 获取新闻数据
+	// 3. 获取新闻数据：RSS/Atom 新闻源 + AI 联网搜索
+	sourceItems, err := collectSourceNews(db, now)
+	if err != nil {
+		fmt.Printf("读取新闻源条目失败: %v\n", err)
+	}
+	fmt.Printf("新闻源候选 %d 条\n", len(sourceItems))
+
 	fmt.Println("正在从 AI 获取今日热点新闻...")
-	newsItems, err := getDailyNews()
+	aiItems, err := getDailyNews()
 	if err != nil {
 		fmt.Printf("获取新闻失败: %v\n", err)
+		if len(sourceItems) == 0 {
+			return
+		}
+	}
+	for i := range aiItems {
+		if aiItems[i].Source == "" {
+			aiItems[i].Source = "AI Summary"
+		}
+	}
+
+	// 新闻源的链接真实可靠，排在 AI 结果之前；有新闻源时由 AI 对合并后的候选排序摘要
+	newsItems := MergeNewsCandidates(sourceItems, aiItems)
+	if len(sourceItems) > 0 {
+		ranked, err := rankNews(newsItems, MaxBatchNews)
+		if err != nil {
+			fmt.Printf("AI 排序失败，按原顺序保存: %v\n", err)
+		} else {
+			newsItems = ranked
+		}
+	}
+	if len(newsItems) > MaxBatchNews {
+		newsItems = newsItems[:MaxBatchNews]
+	}
+	if len(newsItems) == 0 {
+		fmt.Println("没有可保存的新闻")
 		return
 	}
 
-	// 2. 创建批次记录
+	// 2. 创建批次记录，需要审核的批次类型在管理员通过前不对外发布
 	batch := models.BatchLog{
-		Type: batchType,
-		Date: now.Format("2006-01-02"),
+		Type:   batchType,
+		Date:   now.Format("2006-01-02"),
+		Status: InitialBatchStatus(batchType, config.AppConfig.Review.RequireApproval),
+	}
+	if batch.Status == models.StatusPublished {
+		batch.PublishedAt = &now
 	}
 	if err := db.Create(&batch).Error; err != nil {
 		fmt.Printf("创建批次记录失败: %v\n", err)
 		return
 	}
-	fmt.Printf("已创建批次 %d (%s)\n", batch.ID, batchType)
+	fmt.Printf("已创建批次 %d (%s, %s)\n", batch.ID, batchType, batch.Status)
 
-	// 保存新闻条目
-	for _, item := range newsItems {
+	// 保存新闻条目，排序值沿用 AI 排序后的顺序
+	for i, item := range newsItems {
 		news := models.NewsItem{
-			BatchID: batch.ID,
-			Title:   item.Title,
-			Content: item.Title,
-			Url:     item.URL,
-			Source:  "AI Summary",
+			BatchID:     batch.ID,
+			Title:       item.Title,
+			Content:     item.Title,
+			Summary:     item.Summary,
+			Url:         item.URL,
+			Source:      item.Source,
+			PublishedAt: item.PublishedAt,
+			Language:    DetectLanguage(item.Title),
+			Status:      batch.Status,
+			SortRank:    i + 1,
 		}
 		if err := db.Create(&news).Error; err != nil {
 			fmt.Printf("保存新闻失败: %v\n", err)
@@ -84,6 +140,41 @@ func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNews
 
 	fmt.Println("新闻条目已保存")
 
+	// 抓取原文正文，供分析使用
+	if config.AppConfig.Article.Enabled {
+		fmt.Println("正在抽取新闻正文...")
+		ExtractBatchArticles(db, batch.ID, FetchArticle, config.AppConfig.Article.Jobs)
+	}
+
+	// 自动打标签
+	if err := TagBatchNews(db, batch.ID, nil); err != nil {
+		fmt.Printf("新闻分类失败: %v\n", err)
+	}
+
+	// 抽取实体
+	if err := ExtractBatchEntities(db, batch.ID, nil); err != nil {
+		fmt.Printf("实体抽取失败: %v\n", err)
+	}
+
+	// 情绪评分
+	if err := ScoreBatchSentiment(db, batch.ID, nil); err != nil {
+		fmt.Printf("情绪评分失败: %v\n", err)
+	}
+
+	// 翻译标题和摘要
+	if config.AppConfig.Translation.Enabled {
+		if err := TranslateBatchNews(db, batch.ID, config.AppConfig.Translation.Languages, nil); err != nil {
+			fmt.Printf("翻译新闻失败: %v\n", err)
+		}
+	}
+
+	// 热点话题
+	if topics, err := UpdateTrendingTopics(db, batch.ID); err != nil {
+		fmt.Printf("计算热点话题失败: %v\n", err)
+	} else {
+		fmt.Printf("识别热点话题 %d 个\n", len(topics))
+	}
+
 	// 4. 执行 3 天财经分析
 	if runAnalysis {
 		analyzeAndSaveWithDeps(db, analyzeNews, 3, batch.ID, now)
@@ -94,19 +185,53 @@ func RunUpdateTaskWithDeps(db *gorm.DB, nowFn NowFunc, getDailyNews GetDailyNews
 		analyzeAndSaveWithDeps(db, analyzeNews, 7, batch.ID, now)
 	}
 
+	// 6. 推送到群聊机器人并生成订阅邮件，待审核的批次在审核通过时发送
+	if batch.Status == models.StatusPublished {
+		DeliverBatch(db, batch, config.AppConfig.Publishers, runAnalysis)
+	} else {
+		fmt.Printf("批次 %d 等待人工审核\n", batch.ID)
+	}
+
 	fmt.Println("更新任务完成")
 }
 
+// RunEmailQueue 发送队列中到期的邮件
+func RunEmailQueue() {
+	ProcessEmailQueue(config.DB, NewSMTPSender(config.AppConfig.SMTP), time.Now(), config.AppConfig.Subscription.MaxAttempts)
+}
+
+func RunLoginHistoryPurge() {
+	deleted, err := PurgeLoginHistory(config.DB, time.Now().Add(-LoginHistoryRetention()))
+	if err != nil {
+		fmt.Printf("清理登录记录失败: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		fmt.Printf("已清理 %d 条过期登录记录\n", deleted)
+	}
+}
+
+func RunSessionSweep() {
+	deleted, err := PurgeExpiredSessions(config.DB, CurrentSessionPolicy(), time.Now())
+	if err != nil {
+		fmt.Printf("清理过期会话失败: %v\n", err)
+		return
+	}
+	if deleted > 0 {
+		fmt.Printf("已清理 %d 个过期会话\n", deleted)
+	}
+}
+
 func analyzeAndSave(days int, batchID uint) {
 	analyzeAndSaveWithDeps(config.DB, AnalyzeNews, days, batchID, time.Now())
 }
 
 func analyzeAndSaveWithDeps(db *gorm.DB, analyzeNews AnalyzeNewsFunc, days int, batchID uint, now time.Time) {
 	fmt.Printf("开始 %d 天财经分析...\n", days)
-	// 获取过去 N 天的新闻
+	// 获取过去 N 天的新闻，分析随批次审核，因此包含未发布但未被驳回的新闻，情绪统计使用相同范围
 	cutoff := now.AddDate(0, 0, -days)
 	var recentNews []models.NewsItem
-	db.Where("created_at >= ?", cutoff).Find(&recentNews)
+	scopeNewsStatus(db.Model(&models.NewsItem{}), true).Where("news_items.created_at >= ?", cutoff).Find(&recentNews)
 
 	if len(recentNews) == 0 {
 		fmt.Println("未找到分析所需的新闻数据")
@@ -115,7 +240,12 @@ func analyzeAndSaveWithDeps(db *gorm.DB, analyzeNews AnalyzeNewsFunc, days int,
 
 	var sb strings.Builder
 	for _, n := range recentNews {
-		sb.WriteString(fmt.Sprintf("- %s-%s\n", n.Content, n.Url))
+		sb.WriteString(fmt.Sprintf("- %s-%s\n", newsExcerpt(n, 200), n.Url))
+	}
+	if summary, err := SentimentSummary(db, cutoff, now.Add(time.Second), true); err != nil {
+		fmt.Printf("统计新闻情绪失败: %v\n", err)
+	} else if summary != "" {
+		sb.WriteString("\n" + summary)
 	}
 
 	// 调用 AI 进行分析
@@ -130,12 +260,22 @@ func analyzeAndSaveWithDeps(db *gorm.DB, analyzeNews AnalyzeNewsFunc, days int,
 		analysisType = models.Analysis7Day
 	}
 
-	// 保存分析结果
+	// 保存分析结果，状态与所属批次一致
+	status := models.StatusPublished
+	var batch models.BatchLog
+	if err := db.First(&batch, batchID).Error; err == nil && batch.Status != "" {
+		status = batch.Status
+	}
 	analysis := models.Analysis{
 		BatchID: batchID,
 		Type:    analysisType,
 		Content: analysisContent,
+		Status:  status,
 	}
 	db.Create(&analysis)
 	fmt.Printf("已保存 %d 天分析结果\n", days)
+
+	if _, err := LinkAnalysisEntities(db, analysis, cutoff); err != nil {
+		fmt.Printf("关联分析实体失败: %v\n", err)
+	}
 }
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newCaptureDB 返回不连接数据库的 DryRun 会话，记录写入的批次和新闻；查询均返回空结果
+func newCaptureDB(t *testing.T) (*gorm.DB, *[]models.NewsItem) {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []models.NewsItem
+	err = db.Callback().Create().Before("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		switch v := tx.Statement.Dest.(type) {
+		case *models.BatchLog:
+			v.ID = 1
+		case *models.NewsItem:
+			saved = append(saved, *v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, &saved
+}
+
+func TestRunUpdateTaskMergesSourceAndAINews(t *testing.T) {
+	now := func() time.Time { return time.Date(2026, 1, 2, 8, 0, 0, 0, time.Local) }
+	collect := func(*gorm.DB, time.Time) ([]NewsData, error) {
+		return []NewsData{{Title: "央行降准", URL: "https://example.com/a", Source: "新华社"}}, nil
+	}
+	rank := func(candidates []NewsData, limit int) ([]NewsData, error) { return candidates, nil }
+
+	db, saved := newCaptureDB(t)
+	getDaily := func() ([]NewsData, error) {
+		return []NewsData{
+			{Title: "央行降准", URL: "https://example.com/dup"}, // 与新闻源重复
+			{Title: "油价上涨", URL: "https://example.com/b"},
+		}, nil
+	}
+	RunUpdateTaskWithDeps(db, now, getDaily, nil, collect, rank, false)
+	if len(*saved) != 2 || (*saved)[0].Source != "新华社" || (*saved)[1].Title != "油价上涨" || (*saved)[1].Source != "AI Summary" {
+		t.Fatalf("expected source item followed by AI item, got %+v", *saved)
+	}
+	if (*saved)[0].SortRank != 1 || (*saved)[1].SortRank != 2 || (*saved)[0].BatchID != 1 {
+		t.Fatalf("unexpected rank or batch: %+v", *saved)
+	}
+
+	// AI 搜索失败时仍保存新闻源条目
+	db, saved = newCaptureDB(t)
+	failing := func() ([]NewsData, error) { return nil, errors.New("ai unavailable") }
+	RunUpdateTaskWithDeps(db, now, failing, nil, collect, rank, false)
+	if len(*saved) != 1 || (*saved)[0].Url != "https://example.com/a" {
+		t.Fatalf("source items should be kept when AI fails, got %+v", *saved)
+	}
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	SearchKindNews     = "news"
+	SearchKindAnalysis = "analysis"
+	MaxSearchPageSize  = 100
+
+	newsFullTextIndex     = "ft_news_items_title_content"
+	analysisFullTextIndex = "ft_analyses_content"
+	searchSnippetRunes    = 120
+)
+
+// SearchQuery 搜索参数，空值表示不过滤
+type SearchQuery struct {
+	Q         string
+	Kind      string // news, analysis
+	BatchType string
+	Source    string
+	DateStart *time.Time
+	DateEnd   *time.Time
+	Page      int
+	PageSize  int
+}
+
+type SearchHit struct {
+	Kind      string    `json:"kind"`
+	ID        uint      `json:"id"`
+	BatchID   uint      `json:"batch_id"`
+	BatchType string    `json:"batch_type,omitempty"`
+	Type      string    `json:"type,omitempty"` // 分析类型
+	Title     string    `json:"title"`
+	Highlight string    `json:"highlight"` // 已转义的 HTML，命中词用 <em> 标记
+	Url       string    `json:"url,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type SearchResult struct {
+	Rows   []SearchHit              `json:"rows"`
+	Total  int64                    `json:"total"`
+	Facets map[string][]SearchFacet `json:"facets"`
+}
+
+// EnsureSearchIndexes 为 MySQL 创建使用 ngram 分词的 FULLTEXT 索引，适配中文检索
+func EnsureSearchIndexes(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if !supportsFullText(db) {
+		return nil
+	}
+	indexes := []struct {
+		model interface{}
+		name  string
+		sql   string
+	}{
+		{&models.NewsItem{}, newsFullTextIndex, "ALTER TABLE news_items ADD FULLTEXT INDEX " + newsFullTextIndex + " (title, content) WITH PARSER ngram"},
+		{&models.Analysis{}, analysisFullTextIndex, "ALTER TABLE analyses ADD FULLTEXT INDEX " + analysisFullTextIndex + " (content) WITH PARSER ngram"},
+	}
+	for _, idx := range indexes {
+		if db.Migrator().HasIndex(idx.model, idx.name) {
+			continue
+		}
+		if err := db.Exec(idx.sql).Error; err != nil {
+			return fmt.Errorf("create %s: %w", idx.name, err)
+		}
+	}
+	return nil
+}
+
+func supportsFullText(db *gorm.DB) bool {
+	return db.Dialector.Name() == "mysql"
+}
+
+// useFullText ngram 默认按两个字切分，单字关键词无法命中 FULLTEXT 索引，改用 LIKE
+func useFullText(db *gorm.DB, terms []string) bool {
+	if !supportsFullText(db) {
+		return false
+	}
+	for _, t := range terms {
+		if utf8.RuneCountInString(t) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchTerms 按空白拆分关键词并去重
+func SearchTerms(q string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range strings.Fields(q) {
+		key := strings.ToLower(t)
+		if !seen[key] {
+			seen[key] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// booleanQuery 生成 BOOLEAN MODE 查询串，每个关键词都必须作为短语出现
+func booleanQuery(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		t = strings.NewReplacer(`"`, " ", `\`, " ").Replace(t)
+		parts = append(parts, `+"`+t+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Search 按类型检索新闻或分析，返回当前页结果、总数和分面统计
+func Search(db *gorm.DB, q SearchQuery) (*SearchResult, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	terms := SearchTerms(q.Q)
+	if len(terms) == 0 {
+		return nil, errors.New("query empty")
+	}
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = 20
+	}
+	if q.PageSize > MaxSearchPageSize {
+		q.PageSize = MaxSearchPageSize
+	}
+	if q.Kind == SearchKindAnalysis {
+		return searchAnalyses(db, q, terms)
+	}
+	return searchNews(db, q, terms)
+}
+
+// likeEscaper 转义 LIKE 通配符，配合 ESCAPE '\\' 使用
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likeContains 生成包含关键词的 LIKE 模式，关键词中的 % 和 _ 按字面匹配
+func likeContains(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+func searchNews(db *gorm.DB, q SearchQuery, terms []string) (*SearchResult, error) {
+	fullText := useFullText(db, terms)
+	base := func() *gorm.DB {
+		tx := db.Table("news_items").
+			Joins("JOIN batch_logs ON batch_logs.id = news_items.batch_id AND batch_logs.deleted_at IS NULL AND batch_logs.status = ?", models.StatusPublished).
+			Where("news_items.deleted_at IS NULL AND news_items.status = ?", models.StatusPublished)
+		if fullText {
+			tx = tx.Where("MATCH(news_items.title, news_items.content) AGAINST(? IN BOOLEAN MODE)", booleanQuery(terms))
+		} else {
+			for _, t := range terms {
+				pattern := likeContains(t)
+				tx = tx.Where(`(news_items.title LIKE ? ESCAPE '\\' OR news_items.content LIKE ? ESCAPE '\\')`, pattern, pattern)
+			}
+		}
+		if q.DateStart != nil {
+			tx = tx.Where("news_items.created_at >= ?", *q.DateStart)
+		}
+		if q.DateEnd != nil {
+			tx = tx.Where("news_items.created_at <= ?", *q.DateEnd)
+		}
+		return tx
+	}
+	// 分面统计忽略自身维度的过滤条件，便于前端切换
+	filtered := func(skip string) *gorm.DB {
+		tx := base()
+		if q.BatchType != "" && skip != "batch_type" {
+			tx = tx.Where("batch_logs.type = ?", q.BatchType)
+		}
+		if q.Source != "" && skip != "source" {
+			tx = tx.Where("news_items.source = ?", q.Source)
+		}
+		return tx
+	}
+
+	result := &SearchResult{Rows: []SearchHit{}, Facets: map[string][]SearchFacet{}}
+	if err := filtered("").Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	scoreExpr := "0"
+	var scoreArgs []interface{}
+	if fullText {
+		scoreExpr = "MATCH(news_items.title, news_items.content) AGAINST(? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, booleanQuery(terms))
+	}
+	var rows []struct {
+		models.NewsItem
+		BatchType string
+		Score     float64
+	}
+	err := filtered("").
+		Select("news_items.*, batch_logs.type AS batch_type, "+scoreExpr+" AS score", scoreArgs...).
+		Order("score desc, news_items.created_at desc, news_items.id desc").
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		text := row.Content
+		if text == row.Title && row.Summary != "" {
+			text = row.Summary
+		}
+		result.Rows = append(result.Rows, SearchHit{
+			Kind:      SearchKindNews,
+			ID:        row.ID,
+			BatchID:   row.BatchID,
+			BatchType: row.BatchType,
+			Title:     row.Title,
+			Highlight: Highlight(text, terms, searchSnippetRunes),
+			Url:       row.Url,
+			Source:    row.Source,
+			Score:     row.Score,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	facets := []struct {
+		name string
+		expr string
+	}{
+		{"date", "DATE(news_items.created_at)"},
+		{"batch_type", "batch_logs.type"},
+		{"source", "news_items.source"},
+	}
+	for _, f := range facets {
+		values, err := facetCounts(filtered(f.name), f.expr)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets[f.name] = values
+	}
+	return result, nil
+}
+
+func searchAnalyses(db *gorm.DB, q SearchQuery, terms []string) (*SearchResult, error) {
+	fullText := useFullText(db, terms)
+	base := func() *gorm.DB {
+		tx := db.Table("analyses").Where("analyses.deleted_at IS NULL AND analyses.status = ?", models.StatusPublished)
+		if fullText {
+			tx = tx.Where("MATCH(analyses.content) AGAINST(? IN BOOLEAN MODE)", booleanQuery(terms))
+		} else {
+			for _, t := range terms {
+				tx = tx.Where(`analyses.content LIKE ? ESCAPE '\\'`, likeContains(t))
+			}
+		}
+		if q.DateStart != nil {
+			tx = tx.Where("analyses.created_at >= ?", *q.DateStart)
+		}
+		if q.DateEnd != nil {
+			tx = tx.Where("analyses.created_at <= ?", *q.DateEnd)
+		}
+		return tx
+	}
+
+	result := &SearchResult{Rows: []SearchHit{}, Facets: map[string][]SearchFacet{}}
+	if err := base().Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	scoreExpr := "0"
+	var scoreArgs []interface{}
+	if fullText {
+		scoreExpr = "MATCH(analyses.content) AGAINST(? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, booleanQuery(terms))
+	}
+	var rows []struct {
+		models.Analysis
+		Score float64
+	}
+	err := base().
+		Select("analyses.*, "+scoreExpr+" AS score", scoreArgs...).
+		Order("score desc, analyses.created_at desc, analyses.id desc").
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result.Rows = append(result.Rows, SearchHit{
+			Kind:      SearchKindAnalysis,
+			ID:        row.ID,
+			BatchID:   row.BatchID,
+			Type:      string(row.Type),
+			Title:     AnalysisTitle(row.Analysis),
+			Highlight: Highlight(row.Content, terms, searchSnippetRunes),
+			Score:     row.Score,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
+	for name, expr := range map[string]string{"date": "DATE(analyses.created_at)", "type": "analyses.type"} {
+		values, err := facetCounts(base(), expr)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets[name] = values
+	}
+	return result, nil
+}
+
+// AnalysisTitle 分析没有标题，用类型和生成时间拼出展示标题
+func AnalysisTitle(a models.Analysis) string {
+	label := "3 日财经分析"
+	if a.Type == models.Analysis7Day {
+		label = "7 日财经分析"
+	}
+	return label + " " + a.CreatedAt.Format("2006-01-02 15:04")
+}
+
+func facetCounts(tx *gorm.DB, expr string) ([]SearchFacet, error) {
+	var rows []struct {
+		Value string
+		Count int64
+	}
+	err := tx.Select(expr + " AS value, COUNT(*) AS count").
+		Group(expr).Order("count desc").Limit(20).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	facets := make([]SearchFacet, 0, len(rows))
+	for _, r := range rows {
+		if len(r.Value) >= 10 && strings.Contains(r.Value, "T") {
+			// 部分驱动会把 DATE() 解析为时间，统一为 YYYY-MM-DD
+			r.Value = r.Value[:10]
+		}
+		facets = append(facets, SearchFacet{Value: r.Value, Count: r.Count})
+	}
+	return facets, nil
+}
+
+// Highlight 截取第一个命中词附近的片段，转义 HTML 后用 <em> 标记命中词
+func Highlight(text string, terms []string, maxRunes int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, t := range terms {
+		if t != "" {
+			lowerTerms = append(lowerTerms, []rune(strings.ToLower(t)))
+		}
+	}
+
+	// 标记所有命中位置
+	marked := make([]bool, len(runes))
+	first := -1
+	for i := range lower {
+		for _, t := range lowerTerms {
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == string(t) {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+				if first == -1 {
+					first = i
+				}
+			}
+		}
+	}
+
+	start := 0
+	if first > maxRunes/3 {
+		start = first - maxRunes/3
+	}
+	end := start + maxRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] && !inMark {
+			sb.WriteString("<em>")
+			inMark = true
+		} else if !marked[i] && inMark {
+			sb.WriteString("</em>")
+			inMark = false
+		}
+		sb.WriteString(html.EscapeString(string(runes[i])))
+	}
+	if inMark {
+		sb.WriteString("</em>")
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
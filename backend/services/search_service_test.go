@@ -0,0 +1,38 @@
+package services
+
+import "testing"
+
+func TestSearchTermsAndBooleanQuery(t *testing.T) {
+	terms := SearchTerms("  降准 Fed  fed 银\"行 ")
+	if len(terms) != 3 || terms[0] != "降准" || terms[1] != "Fed" {
+		t.Fatalf("unexpected terms: %q", terms)
+	}
+	if got := booleanQuery(terms); got != `+"降准" +"Fed" +"银 行"` {
+		t.Fatalf("unexpected boolean query: %s", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("央行宣布降准，<b>银行</b>板块 FED 跟进", []string{"降准", "fed"}, 100)
+	want := "央行宣布<em>降准</em>，&lt;b&gt;银行&lt;/b&gt;板块 <em>FED</em> 跟进"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// 命中词靠后时截取其附近的片段
+	long := "开头一二三四五六七八九十一二三四五六七八九十黄金结尾一二三四五六七八九十"
+	got = Highlight(long, []string{"黄金"}, 12)
+	if got != "…七八九十<em>黄金</em>结尾一二三四…" {
+		t.Fatalf("unexpected snippet: %s", got)
+	}
+
+	if got := Highlight("没有命中", []string{"黄金"}, 3); got != "没有命…" {
+		t.Fatalf("unexpected snippet without match: %s", got)
+	}
+}
+
+func TestLikeContains(t *testing.T) {
+	if got := likeContains(`50%_涨\跌`); got != `%50\%\_涨\\跌%` {
+		t.Fatalf("unexpected pattern: %s", got)
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sentimentChunkSize 每次请求 AI 评分的新闻条数
+const sentimentChunkSize = 20
+
+// SentimentData 单条新闻的情绪评分
+type SentimentData struct {
+	Score  float64 `json:"score"`  // -1 ~ 1
+	Impact string  `json:"impact"` // bullish, bearish, neutral
+}
+
+// ScoreSentimentFunc 为每条新闻返回评分，下标与 items 一致，无法评分的为 nil
+type ScoreSentimentFunc func(items []models.NewsItem) ([]*SentimentData, error)
+
+// SentimentPoint 某一天（某个主题）的情绪汇总
+type SentimentPoint struct {
+	Date    string  `json:"date"`          // YYYY-MM-DD
+	Tag     string  `json:"tag,omitempty"` // 主题 slug，整体汇总为空
+	Score   float64 `json:"score"`         // 平均情绪分
+	Count   int64   `json:"count"`
+	Bullish int64   `json:"bullish"`
+	Bearish int64   `json:"bearish"`
+	Neutral int64   `json:"neutral"`
+}
+
+// ScoreSentiment 调用 AI 对新闻进行情绪评分，按 sentimentChunkSize 分批请求
+func ScoreSentiment(items []models.NewsItem) ([]*SentimentData, error) {
+	out := make([]*SentimentData, 0, len(items))
+	for start := 0; start < len(items); start += sentimentChunkSize {
+		end := min(start+sentimentChunkSize, len(items))
+		scores, err := scoreSentimentChunk(items[start:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, scores...)
+	}
+	return out, nil
+}
+
+func scoreSentimentChunk(items []models.NewsItem) ([]*SentimentData, error) {
+	var sb strings.Builder
+	for i, item := range items {
+		sb.WriteString(fmt.Sprintf("[%d] %s", i, item.Title))
+		if item.Summary != "" {
+			sb.WriteString(" | 摘要: " + truncateText(item.Summary, 300))
+		}
+		sb.WriteString("\n")
+	}
+	prompt := fmt.Sprintf(`请评估以下每条财经新闻对中国金融市场的情绪影响。score 为 -1（明显利空）到 1（明显利好）之间的小数，0 表示中性；
+impact 为 bullish（利好）、bearish（利空）或 neutral（中性）。
+请严格按照 JSON 对象数组格式输出，每个对象包含 index（新闻编号）、score 和 impact 字段，不要包含 Markdown 标记或其他多余文字。
+例如：[{"index": 0, "score": 0.6, "impact": "bullish"}]
+新闻列表：
+%s`, sb.String())
+	log.Printf("AI Prompt: %s", prompt)
+
+	response, err := CallAI(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseSentimentResponse(response, len(items))
+}
+
+// parseSentimentResponse 解析 AI 评分结果，分数截断到 [-1, 1]，缺失的 impact 按分数推断
+func parseSentimentResponse(response string, n int) ([]*SentimentData, error) {
+	cleanResponse := strings.TrimSpace(response)
+	start := strings.Index(cleanResponse, "[")
+	end := strings.LastIndex(cleanResponse, "]")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = cleanResponse[start : end+1]
+	}
+	var scored []struct {
+		Index  int      `json:"index"`
+		Score  *float64 `json:"score"`
+		Impact string   `json:"impact"`
+	}
+	if err := json.Unmarshal([]byte(cleanResponse), &scored); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %v. Response: %s", err, response)
+	}
+
+	out := make([]*SentimentData, n)
+	for _, s := range scored {
+		if s.Index < 0 || s.Index >= n || s.Score == nil || math.IsNaN(*s.Score) {
+			continue
+		}
+		score := math.Max(-1, math.Min(1, *s.Score))
+		impact := strings.ToLower(strings.TrimSpace(s.Impact))
+		if impact != models.ImpactBullish && impact != models.ImpactBearish && impact != models.ImpactNeutral {
+			impact = impactForScore(score)
+		}
+		out[s.Index] = &SentimentData{Score: math.Round(score*100) / 100, Impact: impact}
+	}
+	return out, nil
+}
+
+func impactForScore(score float64) string {
+	switch {
+	case score >= 0.2:
+		return models.ImpactBullish
+	case score <= -0.2:
+		return models.ImpactBearish
+	}
+	return models.ImpactNeutral
+}
+
+// ScoreBatchSentiment 为批次内尚未评分的新闻打情绪分
+func ScoreBatchSentiment(db *gorm.DB, batchID uint, score ScoreSentimentFunc) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if score == nil {
+		score = ScoreSentiment
+	}
+	var items []models.NewsItem
+	if err := db.Where("batch_id = ? AND sentiment IS NULL", batchID).Order("id asc").Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	scores, err := score(items)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		if i >= len(scores) || scores[i] == nil {
+			continue
+		}
+		updates := map[string]interface{}{"sentiment": scores[i].Score, "market_impact": scores[i].Impact}
+		if err := db.Model(&models.NewsItem{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeNewsStatus 限定参与统计的新闻：默认只取已发布批次中已发布的新闻；
+// includeUnpublished 为 true 时改为排除被驳回的新闻和批次，与生成分析时选取的新闻一致
+func scopeNewsStatus(q *gorm.DB, includeUnpublished bool) *gorm.DB {
+	if includeUnpublished {
+		return q.Joins("JOIN batch_logs ON batch_logs.id = news_items.batch_id AND batch_logs.deleted_at IS NULL AND batch_logs.status <> ?", models.StatusRejected).
+			Where("news_items.status <> ?", models.StatusRejected)
+	}
+	return q.Joins("JOIN batch_logs ON batch_logs.id = news_items.batch_id AND batch_logs.deleted_at IS NULL AND batch_logs.status = ?", models.StatusPublished).
+		Where("news_items.status = ?", models.StatusPublished)
+}
+
+// SentimentTimeSeries 按天汇总 [start, end) 内新闻的情绪；byTag 为 true 时按主题分组，tag 非空时只统计该主题
+func SentimentTimeSeries(db *gorm.DB, start, end time.Time, byTag bool, tag string, includeUnpublished bool) ([]SentimentPoint, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	selects := []string{
+		"DATE_FORMAT(news_items.created_at, '%Y-%m-%d') AS `date`",
+		"AVG(news_items.sentiment) AS score",
+		"COUNT(*) AS count",
+		"SUM(news_items.market_impact = 'bullish') AS bullish",
+		"SUM(news_items.market_impact = 'bearish') AS bearish",
+		"SUM(news_items.market_impact = 'neutral') AS neutral",
+	}
+	group := "`date`"
+	q := scopeNewsStatus(db.Table("news_items"), includeUnpublished).
+		Where("news_items.deleted_at IS NULL AND news_items.sentiment IS NOT NULL").
+		Where("news_items.created_at >= ? AND news_items.created_at < ?", start, end)
+	if byTag || tag != "" {
+		selects = append(selects, "tags.slug AS tag")
+		group = "`date`, tags.slug"
+		q = q.Joins("JOIN news_item_tags ON news_item_tags.news_item_id = news_items.id").
+			Joins("JOIN tags ON tags.id = news_item_tags.tag_id")
+		if tag != "" {
+			q = q.Where("tags.slug = ?", tag)
+		}
+	}
+
+	var rows []SentimentPoint
+	err := q.Select(strings.Join(selects, ", ")).Group(group).Order(group).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Score = math.Round(rows[i].Score*100) / 100
+	}
+	return rows, nil
+}
+
+// SentimentSummary 生成分析提示词使用的情绪汇总，没有评分数据时返回空
+func SentimentSummary(db *gorm.DB, start, end time.Time, includeUnpublished bool) (string, error) {
+	overall, err := SentimentTimeSeries(db, start, end, false, "", includeUnpublished)
+	if err != nil {
+		return "", err
+	}
+	topics, err := SentimentTimeSeries(db, start, end, true, "", includeUnpublished)
+	if err != nil {
+		return "", err
+	}
+	var tags []models.Tag
+	if err := db.Find(&tags).Error; err != nil {
+		return "", err
+	}
+	names := map[string]string{}
+	for _, t := range tags {
+		names[t.Slug] = t.Name
+	}
+	return formatSentimentSummary(overall, topics, names), nil
+}
+
+// formatSentimentSummary 汇总整体、每日和各主题的平均情绪
+func formatSentimentSummary(overall, topics []SentimentPoint, tagNames map[string]string) string {
+	if len(overall) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("新闻情绪统计（-1 利空 ~ 1 利好）：\n")
+
+	total := mergeSentimentPoints(overall)
+	sb.WriteString(fmt.Sprintf("- 整体：%.2f（利好 %d / 利空 %d / 中性 %d，共 %d 条）\n", total.Score, total.Bullish, total.Bearish, total.Neutral, total.Count))
+	var days []string
+	for _, p := range overall {
+		days = append(days, fmt.Sprintf("%s %.2f", p.Date, p.Score))
+	}
+	sb.WriteString("- 每日：" + strings.Join(days, "，") + "\n")
+
+	byTag := map[string][]SentimentPoint{}
+	var order []string
+	for _, p := range topics {
+		if _, ok := byTag[p.Tag]; !ok {
+			order = append(order, p.Tag)
+		}
+		byTag[p.Tag] = append(byTag[p.Tag], p)
+	}
+	var parts []string
+	for _, slug := range order {
+		name := tagNames[slug]
+		if name == "" {
+			name = slug
+		}
+		t := mergeSentimentPoints(byTag[slug])
+		parts = append(parts, fmt.Sprintf("%s %.2f（%d 条）", name, t.Score, t.Count))
+	}
+	if len(parts) > 0 {
+		sb.WriteString("- 主题：" + strings.Join(parts, "，") + "\n")
+	}
+	return sb.String()
+}
+
+// mergeSentimentPoints 按条数加权合并多天的汇总
+func mergeSentimentPoints(points []SentimentPoint) SentimentPoint {
+	var total SentimentPoint
+	var sum float64
+	for _, p := range points {
+		sum += p.Score * float64(p.Count)
+		total.Count += p.Count
+		total.Bullish += p.Bullish
+		total.Bearish += p.Bearish
+		total.Neutral += p.Neutral
+	}
+	if total.Count > 0 {
+		total.Score = math.Round(sum/float64(total.Count)*100) / 100
+	}
+	return total
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"strings"
+	"testing"
+)
+
+func TestParseSentimentResponse(t *testing.T) {
+	response := `[{"index": 0, "score": 1.7, "impact": "BULLISH"}, {"index": 1, "score": -0.456}, {"index": 2, "impact": "neutral"}, {"index": 9, "score": 0.1}]`
+	got, err := parseSentimentResponse(response, 3)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got[0] == nil || got[0].Score != 1 || got[0].Impact != models.ImpactBullish {
+		t.Fatalf("unexpected first score %+v", got[0])
+	}
+	if got[1] == nil || got[1].Score != -0.46 || got[1].Impact != models.ImpactBearish {
+		t.Fatalf("unexpected second score %+v", got[1])
+	}
+	if got[2] != nil {
+		t.Fatalf("item without score should be skipped, got %+v", got[2])
+	}
+}
+
+func TestFormatSentimentSummary(t *testing.T) {
+	overall := []SentimentPoint{
+		{Date: "2025-03-01", Score: 0.5, Count: 2, Bullish: 2},
+		{Date: "2025-03-02", Score: -0.4, Count: 4, Bearish: 3, Neutral: 1},
+	}
+	topics := []SentimentPoint{
+		{Date: "2025-03-01", Tag: "macro", Score: 0.5, Count: 1},
+		{Date: "2025-03-02", Tag: "macro", Score: -0.5, Count: 3},
+		{Date: "2025-03-02", Tag: "tech", Score: 0.2, Count: 1},
+	}
+	got := formatSentimentSummary(overall, topics, map[string]string{"macro": "宏观"})
+	for _, want := range []string{
+		"整体：-0.10（利好 2 / 利空 3 / 中性 1，共 6 条）",
+		"每日：2025-03-01 0.50，2025-03-02 -0.40",
+		"主题：宏观 -0.25（4 条），tech 0.20（1 条）",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("summary missing %q:\n%s", want, got)
+		}
+	}
+	if formatSentimentSummary(nil, nil, nil) != "" {
+		t.Fatal("expected empty summary without data")
+	}
+}
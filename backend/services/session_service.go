@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval 最近访问时间的最小更新间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+// SessionPolicy 会话的绝对有效期和空闲超时，Idle 为 0 表示不限制空闲时间
+type SessionPolicy struct {
+	TTL  time.Duration
+	Idle time.Duration
+}
+
+// CurrentSessionPolicy 读取配置中的会话策略，未配置的项使用默认值
+func CurrentSessionPolicy() SessionPolicy {
+	cfg := config.AppConfig.Session
+	policy := SessionPolicy{TTL: 720 * time.Hour, Idle: 4320 * time.Minute}
+	if cfg.TTLHours > 0 {
+		policy.TTL = time.Duration(cfg.TTLHours) * time.Hour
+	}
+	switch {
+	case cfg.IdleMinutes < 0:
+		policy.Idle = 0
+	case cfg.IdleMinutes > 0:
+		policy.Idle = time.Duration(cfg.IdleMinutes) * time.Minute
+	}
+	return policy
+}
+
+// lastSeen 最近一次使用时间，升级前创建的会话没有记录时取创建时间
+func lastSeen(s *models.AdminSession) time.Time {
+	if s.LastSeenAt != nil {
+		return *s.LastSeenAt
+	}
+	return s.CreatedAt
+}
+
+// Expired 会话超过绝对有效期或空闲超时
+func (p SessionPolicy) Expired(s *models.AdminSession, now time.Time) bool {
+	if !now.Before(s.ExpiresAt) {
+		return true
+	}
+	return p.Idle > 0 && now.Sub(lastSeen(s)) >= p.Idle
+}
+
+// touchAdminSession 顺延空闲超时，距上次更新不足一分钟时跳过
+func touchAdminSession(db *gorm.DB, s *models.AdminSession, now time.Time) {
+	if now.Sub(lastSeen(s)) < sessionTouchInterval {
+		return
+	}
+	if err := db.Model(&models.AdminSession{}).Where("token = ?", s.TokenHash).Update("last_seen_at", now).Error; err != nil {
+		fmt.Printf("更新会话访问时间失败: %v\n", err)
+		return
+	}
+	s.LastSeenAt = &now
+}
+
+// HashSessionToken 会话令牌在库中保存的形式：SHA-256 十六进制
+func HashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// isSessionTokenHash 判断是否已是 64 位十六进制哈希；旧版明文令牌为 43 位 base64url，不会混淆
+func isSessionTokenHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// SessionID 会话对外展示的编号，取令牌哈希的前 16 位
+func SessionID(s *models.AdminSession) string {
+	if len(s.TokenHash) < 16 {
+		return s.TokenHash
+	}
+	return s.TokenHash[:16]
+}
+
+// MigrateSessionTokens 将升级前以明文保存的会话令牌转换为哈希，已转换的记录跳过，可重复执行
+func MigrateSessionTokens(db *gorm.DB) (int, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	var tokens []string
+	if err := db.Model(&models.AdminSession{}).Where("CHAR_LENGTH(token) <> ?", sha256.Size*2).Pluck("token", &tokens).Error; err != nil {
+		return 0, err
+	}
+	converted := 0
+	for _, token := range tokens {
+		if isSessionTokenHash(token) {
+			continue
+		}
+		err := db.Model(&models.AdminSession{}).Where("token = ?", token).Update("token", HashSessionToken(token)).Error
+		if err != nil {
+			return converted, err
+		}
+		converted++
+	}
+	return converted, nil
+}
+
+// SessionInfo 会话列表中的一项
+type SessionInfo struct {
+	ID         string     `json:"id"`
+	Current    bool       `json:"current"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// ListAdminSessions 管理员的有效会话，按最近使用时间倒序，currentHash 对应的会话标记为当前
+func ListAdminSessions(db *gorm.DB, policy SessionPolicy, userID uint, currentHash string, now time.Time) ([]SessionInfo, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var rows []models.AdminSession
+	if err := db.Where("user_id = ?", userID).Order("last_seen_at DESC, created_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	items := make([]SessionInfo, 0, len(rows))
+	for i := range rows {
+		s := &rows[i]
+		if policy.Expired(s, now) {
+			continue
+		}
+		items = append(items, SessionInfo{
+			ID:         SessionID(s),
+			Current:    s.TokenHash == currentHash,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+		})
+	}
+	return items, nil
+}
+
+// RevokeAdminSession 按编号注销管理员自己的一个会话
+func RevokeAdminSession(db *gorm.DB, userID uint, sessionID string) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var rows []models.AdminSession
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		if SessionID(&rows[i]) == sessionID {
+			return db.Where("token = ?", rows[i].TokenHash).Delete(&models.AdminSession{}).Error
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// RevokeOtherAdminSessions 注销管理员除 keepHash 外的所有会话，返回注销数量
+func RevokeOtherAdminSessions(db *gorm.DB, userID uint, keepHash string) (int64, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	res := db.Where("user_id = ? AND token <> ?", userID, keepHash).Delete(&models.AdminSession{})
+	return res.RowsAffected, res.Error
+}
+
+// PurgeExpiredSessions 删除过期和空闲超时的会话以及过期的登录挑战
+func PurgeExpiredSessions(db *gorm.DB, policy SessionPolicy, now time.Time) (int64, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	q := db.Where("expires_at <= ?", now)
+	if policy.Idle > 0 {
+		cutoff := now.Add(-policy.Idle)
+		q = q.Or("COALESCE(last_seen_at, created_at) <= ?", cutoff)
+	}
+	res := q.Delete(&models.AdminSession{})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	err := db.Where("expires_at <= ?", now).Delete(&models.AdminLoginChallenge{}).Error
+	return res.RowsAffected, err
+}
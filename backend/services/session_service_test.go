@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"testing"
+	"time"
+)
+
+func TestSessionPolicyExpired(t *testing.T) {
+	p := SessionPolicy{TTL: 24 * time.Hour, Idle: time.Hour}
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	s := &models.AdminSession{CreatedAt: created, ExpiresAt: created.Add(p.TTL)}
+
+	// 升级前的会话没有访问时间，按创建时间计算空闲
+	if p.Expired(s, created.Add(59*time.Minute)) {
+		t.Fatal("session should still be valid within the idle window")
+	}
+	if !p.Expired(s, created.Add(61*time.Minute)) {
+		t.Fatal("session should expire after the idle timeout")
+	}
+
+	// 使用后空闲窗口顺延，但不超过绝对有效期
+	seen := created.Add(23*time.Hour + 30*time.Minute)
+	s.LastSeenAt = &seen
+	if p.Expired(s, seen.Add(20*time.Minute)) {
+		t.Fatal("recent use should extend the idle window")
+	}
+	if !p.Expired(s, created.Add(p.TTL)) {
+		t.Fatal("session must expire at the absolute TTL")
+	}
+
+	noIdle := SessionPolicy{TTL: p.TTL}
+	s.LastSeenAt = nil
+	if noIdle.Expired(s, created.Add(20*time.Hour)) {
+		t.Fatal("zero idle timeout should disable idle expiry")
+	}
+}
+
+func TestCurrentSessionPolicy(t *testing.T) {
+	old := config.AppConfig.Session
+	defer func() { config.AppConfig.Session = old }()
+
+	config.AppConfig.Session = config.SessionConfig{}
+	if p := CurrentSessionPolicy(); p.TTL != 720*time.Hour || p.Idle != 72*time.Hour {
+		t.Fatalf("unexpected defaults: %+v", p)
+	}
+	config.AppConfig.Session = config.SessionConfig{TTLHours: 12, IdleMinutes: -1}
+	if p := CurrentSessionPolicy(); p.TTL != 12*time.Hour || p.Idle != 0 {
+		t.Fatalf("unexpected policy: %+v", p)
+	}
+}
+
+func TestSessionTokenHash(t *testing.T) {
+	hash := HashSessionToken("bres_abc")
+	if len(hash) != 64 || hash != HashSessionToken("bres_abc") || hash == HashSessionToken("bres_abd") {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+	if !isSessionTokenHash(hash) {
+		t.Fatal("hash should be recognized as migrated")
+	}
+	// 旧版明文令牌：32 字节 base64url，43 位
+	legacy, err := newRandomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isSessionTokenHash(legacy) {
+		t.Fatalf("legacy token %s should not look hashed", legacy)
+	}
+	if id := SessionID(&models.AdminSession{TokenHash: hash}); id != hash[:16] {
+		t.Fatalf("SessionID = %s", id)
+	}
+}
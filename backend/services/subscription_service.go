@@ -0,0 +1,435 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SubscribeRequest struct {
+	Email  string   `json:"email"`
+	Topics []string `json:"topics"`
+}
+
+var validSubscriptionTopics = map[string]bool{
+	string(models.BatchMorning): true,
+	string(models.BatchNoon):    true,
+	string(models.BatchEvening): true,
+	models.SubscriptionAnalysis: true,
+}
+
+// DigestData 渲染邮件模板时可用的数据
+type DigestData struct {
+	Title          string
+	News           []models.NewsItem
+	Analyses       []models.Analysis
+	ConfirmURL     string
+	UnsubscribeURL string
+}
+
+const digestTextTemplate = `{{.Title}}
+{{range $i, $n := .News}}
+{{inc $i}}. {{$n.Title}}
+   {{$n.Url}}
+{{end}}{{range .Analyses}}
+【{{analysisLabel .Type}}】
+{{.Content}}
+{{end}}
+退订：{{.UnsubscribeURL}}
+`
+
+const digestHTMLTemplate = `<!DOCTYPE html>
+<html><body style="font-family:sans-serif;line-height:1.6">
+<h2>{{.Title}}</h2>
+{{if .News}}<ol>{{range .News}}<li><a href="{{.Url}}">{{.Title}}</a></li>{{end}}</ol>{{end}}
+{{range .Analyses}}<h3>{{analysisLabel .Type}}</h3><div style="white-space:pre-wrap">{{.Content}}</div>{{end}}
+<p style="color:#999;font-size:12px"><a href="{{.UnsubscribeURL}}">退订</a></p>
+</body></html>`
+
+const confirmTextTemplate = `您好，请打开以下链接确认订阅「{{.Title}}」：
+{{.ConfirmURL}}
+
+如果这不是您本人的操作，请忽略此邮件。
+`
+
+const confirmHTMLTemplate = `<!DOCTYPE html>
+<html><body style="font-family:sans-serif;line-height:1.6">
+<p>您好，请点击以下链接确认订阅「{{.Title}}」：</p>
+<p><a href="{{.ConfirmURL}}">确认订阅</a></p>
+<p style="color:#999;font-size:12px">如果这不是您本人的操作，请忽略此邮件。</p>
+</body></html>`
+
+var digestFuncs = map[string]interface{}{
+	"inc": func(i int) int { return i + 1 },
+	"analysisLabel": func(t models.AnalysisType) string {
+		if t == models.Analysis7Day {
+			return "7 日财经分析"
+		}
+		return "3 日财经分析"
+	},
+}
+
+var (
+	digestText  = texttemplate.Must(texttemplate.New("digest").Funcs(digestFuncs).Parse(digestTextTemplate))
+	digestHTML  = htmltemplate.Must(htmltemplate.New("digest").Funcs(digestFuncs).Parse(digestHTMLTemplate))
+	confirmText = texttemplate.Must(texttemplate.New("confirm").Parse(confirmTextTemplate))
+	confirmHTML = htmltemplate.Must(htmltemplate.New("confirm").Parse(confirmHTMLTemplate))
+)
+
+// RenderDigest 渲染纯文本与 HTML 两种正文
+func RenderDigest(data DigestData) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, data); err != nil {
+		return "", "", err
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+func renderConfirm(data DigestData) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := confirmText.Execute(&text, data); err != nil {
+		return "", "", err
+	}
+	if err := confirmHTML.Execute(&html, data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+func subscriptionLink(path, token string) string {
+	base := strings.TrimRight(config.AppConfig.Subscription.BaseURL, "/")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+func normalizeTopics(topics []string) (string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range topics {
+		t = strings.TrimSpace(t)
+		if !validSubscriptionTopics[t] {
+			return "", errors.New("invalid topic: " + t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		return "", errors.New("topics empty")
+	}
+	return strings.Join(out, ","), nil
+}
+
+func hasTopic(topics, topic string) bool {
+	for _, t := range strings.Split(topics, ",") {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrSubscribeRateLimited = errors.New("too many subscription requests, please try again later")
+	ErrConfirmExpired       = errors.New("confirmation link expired")
+	// ErrInvalidSubscription 邮箱或主题不合法，错误信息可以直接返回给用户
+	ErrInvalidSubscription = errors.New("invalid subscription")
+)
+
+// SubscriptionLimits 订阅确认邮件的发送限制和确认链接有效期
+type SubscriptionLimits struct {
+	ConfirmTTL   time.Duration
+	Resend       time.Duration
+	MaxPerIPHour int
+}
+
+// CurrentSubscriptionLimits 读取配置，未配置的项使用默认值
+func CurrentSubscriptionLimits() SubscriptionLimits {
+	cfg := config.AppConfig.Subscription
+	limits := SubscriptionLimits{ConfirmTTL: 48 * time.Hour, Resend: 10 * time.Minute, MaxPerIPHour: 5}
+	if cfg.ConfirmTTLHours > 0 {
+		limits.ConfirmTTL = time.Duration(cfg.ConfirmTTLHours) * time.Hour
+	}
+	if cfg.ResendMinutes > 0 {
+		limits.Resend = time.Duration(cfg.ResendMinutes) * time.Minute
+	}
+	if cfg.MaxPerIPHour > 0 {
+		limits.MaxPerIPHour = cfg.MaxPerIPHour
+	}
+	return limits
+}
+
+// windowLimiter 进程内的滑动窗口计数，用于限制同一 IP 的订阅请求
+type windowLimiter struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+var subscribeIPLimiter = &windowLimiter{hits: map[string][]time.Time{}}
+
+// Allow 窗口内次数未达到 limit 时记录一次并返回 true
+func (l *windowLimiter) Allow(key string, limit int, window time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := l.hits[key][:0]
+	for _, t := range l.hits[key] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		l.hits[key] = recent
+		return false
+	}
+	l.hits[key] = append(recent, now)
+	// 顺带清理长时间没有请求的 IP，避免 map 无限增长
+	if len(l.hits) > 10000 {
+		for k, v := range l.hits {
+			if len(v) == 0 || now.Sub(v[len(v)-1]) >= window {
+				delete(l.hits, k)
+			}
+		}
+	}
+	return true
+}
+
+// Subscribe 创建或更新订阅，并发送确认邮件；主题变更也需重新确认。
+// 同一 IP 每小时的请求数和同一邮箱的确认邮件间隔受限，超出时返回 ErrSubscribeRateLimited
+func Subscribe(db *gorm.DB, req SubscribeRequest, limits SubscriptionLimits, ip string, now time.Time) (*models.Subscriber, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+	topics, err := normalizeTopics(req.Topics)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+	if !subscribeIPLimiter.Allow(ip, limits.MaxPerIPHour, time.Hour, now) {
+		return nil, ErrSubscribeRateLimited
+	}
+	confirmToken, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(limits.ConfirmTTL)
+
+	var sub models.Subscriber
+	err = db.Where("email = ?", email).First(&sub).Error
+	if err == nil && sub.ConfirmSentAt != nil && now.Sub(*sub.ConfirmSentAt) < limits.Resend {
+		return nil, ErrSubscribeRateLimited
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		unsubscribeToken, err := randomToken(24)
+		if err != nil {
+			return nil, err
+		}
+		sub = models.Subscriber{
+			Email:            email,
+			PendingTopics:    topics,
+			Status:           models.SubscriberPending,
+			ConfirmToken:     confirmToken,
+			ConfirmExpiresAt: &expiresAt,
+			ConfirmSentAt:    &now,
+			UnsubscribeToken: unsubscribeToken,
+		}
+		if err := db.Create(&sub).Error; err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		updates := map[string]interface{}{
+			"pending_topics":     topics,
+			"confirm_token":      confirmToken,
+			"confirm_expires_at": expiresAt,
+			"confirm_sent_at":    now,
+		}
+		if sub.Status == models.SubscriberUnsubscribed {
+			updates["status"] = models.SubscriberPending
+		}
+		if err := db.Model(&models.Subscriber{}).Where("id = ?", sub.ID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		sub.PendingTopics = topics
+		sub.ConfirmToken = confirmToken
+	}
+
+	text, html, err := renderConfirm(DigestData{
+		Title:      topicsLabel(topics),
+		ConfirmURL: subscriptionLink("/api/subscribe/confirm", confirmToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := EnqueueEmail(db, MailMessage{
+		To:       email,
+		Subject:  "请确认您的新闻订阅",
+		TextBody: text,
+		HTMLBody: html,
+	}); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ConfirmSubscription 通过确认令牌激活订阅，链接过期时返回 ErrConfirmExpired
+func ConfirmSubscription(db *gorm.DB, token string, now time.Time) (*models.Subscriber, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	if token == "" {
+		return nil, errors.New("token empty")
+	}
+	var sub models.Subscriber
+	if err := db.Where("confirm_token = ?", token).First(&sub).Error; err != nil {
+		return nil, err
+	}
+	// 升级前发出的链接没有过期时间，从订阅记录最后更新的时间起算
+	expiresAt := sub.UpdatedAt.Add(CurrentSubscriptionLimits().ConfirmTTL)
+	if sub.ConfirmExpiresAt != nil {
+		expiresAt = *sub.ConfirmExpiresAt
+	}
+	if !now.Before(expiresAt) {
+		return nil, ErrConfirmExpired
+	}
+	updates := map[string]interface{}{
+		"status":         models.SubscriberActive,
+		"topics":         sub.PendingTopics,
+		"pending_topics": "",
+		"confirm_token":  "",
+		"confirmed_at":   now,
+	}
+	if err := db.Model(&models.Subscriber{}).Where("id = ?", sub.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	sub.Status = models.SubscriberActive
+	sub.Topics = sub.PendingTopics
+	sub.PendingTopics = ""
+	sub.ConfirmedAt = &now
+	return &sub, nil
+}
+
+// Unsubscribe 通过退订令牌取消订阅
+func Unsubscribe(db *gorm.DB, token string) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if token == "" {
+		return errors.New("token empty")
+	}
+	var sub models.Subscriber
+	if err := db.Where("unsubscribe_token = ?", token).First(&sub).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Subscriber{}).Where("id = ?", sub.ID).Updates(map[string]interface{}{
+		"status":        models.SubscriberUnsubscribed,
+		"confirm_token": "",
+	}).Error
+}
+
+func topicsLabel(topics string) string {
+	var labels []string
+	for _, t := range strings.Split(topics, ",") {
+		if t == models.SubscriptionAnalysis {
+			labels = append(labels, "每日分析")
+		} else if label, ok := batchTypeLabels[models.BatchType(t)]; ok {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, "、")
+}
+
+// EnqueueBatchDigest 为订阅了该批次类型的订阅者生成新闻邮件
+func EnqueueBatchDigest(db *gorm.DB, batchID uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var batch models.BatchLog
+	if err := db.Where("id = ?", batchID).First(&batch).Error; err != nil {
+		return err
+	}
+	var news []models.NewsItem
+	if err := db.Where("batch_id = ? AND status = ?", batch.ID, models.StatusPublished).Order("sort_rank asc, id asc").Find(&news).Error; err != nil {
+		return err
+	}
+	if len(news) == 0 {
+		return nil
+	}
+	data := DigestData{
+		Title: batch.Date + " " + batchTypeLabels[batch.Type],
+		News:  news,
+	}
+	return enqueueDigestForTopic(db, string(batch.Type), data)
+}
+
+// EnqueueAnalysisDigest 为订阅了每日分析的订阅者生成分析邮件
+func EnqueueAnalysisDigest(db *gorm.DB, batchID uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var batch models.BatchLog
+	if err := db.Where("id = ?", batchID).First(&batch).Error; err != nil {
+		return err
+	}
+	var analyses []models.Analysis
+	if err := db.Where("batch_id = ? AND status = ?", batch.ID, models.StatusPublished).Order("type asc").Find(&analyses).Error; err != nil {
+		return err
+	}
+	if len(analyses) == 0 {
+		return nil
+	}
+	data := DigestData{
+		Title:    batch.Date + " 每日财经分析",
+		Analyses: analyses,
+	}
+	return enqueueDigestForTopic(db, models.SubscriptionAnalysis, data)
+}
+
+func enqueueDigestForTopic(db *gorm.DB, topic string, data DigestData) error {
+	var subs []models.Subscriber
+	if err := db.Where("status = ? AND topics like ?", models.SubscriberActive, "%"+topic+"%").Find(&subs).Error; err != nil {
+		return err
+	}
+
+	count := 0
+	for _, sub := range subs {
+		if !hasTopic(sub.Topics, topic) {
+			continue
+		}
+		data.UnsubscribeURL = subscriptionLink("/api/unsubscribe", sub.UnsubscribeToken)
+		text, html, err := RenderDigest(data)
+		if err != nil {
+			return err
+		}
+		if err := EnqueueEmail(db, MailMessage{
+			To:          sub.Email,
+			Subject:     data.Title,
+			TextBody:    text,
+			HTMLBody:    html,
+			Unsubscribe: data.UnsubscribeURL,
+		}); err != nil {
+			return err
+		}
+		count++
+	}
+	if count > 0 {
+		fmt.Printf("已为主题 %s 生成 %d 封订阅邮件\n", topic, count)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// subscriptionDB 在 newCaptureDB 基础上按顺序返回订阅者查询结果，并记录新建的订阅、邮件和更新内容
+type subscriptionDB struct {
+	*gorm.DB
+	subscribers []models.Subscriber
+	emails      []models.EmailQueue
+	updates     []map[string]interface{}
+}
+
+func newSubscriptionDB(t *testing.T, rows ...models.Subscriber) *subscriptionDB {
+	t.Helper()
+	db, _ := newCaptureDB(t)
+	sdb := &subscriptionDB{DB: db}
+	err := db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		switch v := tx.Statement.Dest.(type) {
+		case *models.Subscriber:
+			if len(rows) == 0 {
+				tx.AddError(gorm.ErrRecordNotFound)
+				return
+			}
+			*v = rows[0]
+			tx.RowsAffected = 1
+		case *[]models.Subscriber:
+			*v = append([]models.Subscriber(nil), rows...)
+			tx.RowsAffected = int64(len(rows))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Create().Before("gorm:create").Register("test:subscription", func(tx *gorm.DB) {
+		switch v := tx.Statement.Dest.(type) {
+		case *models.Subscriber:
+			sdb.subscribers = append(sdb.subscribers, *v)
+		case *models.EmailQueue:
+			sdb.emails = append(sdb.emails, *v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Update().Before("gorm:update").Register("test:subscription", func(tx *gorm.DB) {
+		if updates, ok := tx.Statement.Dest.(map[string]interface{}); ok {
+			sdb.updates = append(sdb.updates, updates)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sdb
+}
+
+func TestSubscribe(t *testing.T) {
+	now := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	limits := SubscriptionLimits{ConfirmTTL: time.Hour, Resend: 10 * time.Minute, MaxPerIPHour: 5}
+	req := SubscribeRequest{Email: " Reader@Example.com ", Topics: []string{"morning", "morning"}}
+
+	db := newSubscriptionDB(t)
+	if _, err := Subscribe(db.DB, req, limits, "198.51.100.1", now); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if len(db.subscribers) != 1 || len(db.emails) != 1 {
+		t.Fatalf("expected one subscriber and one email, got %+v %+v", db.subscribers, db.emails)
+	}
+	sub := db.subscribers[0]
+	if sub.Email != "reader@example.com" || sub.PendingTopics != "morning" || sub.Status != models.SubscriberPending {
+		t.Fatalf("unexpected subscriber %+v", sub)
+	}
+	if sub.ConfirmExpiresAt == nil || !sub.ConfirmExpiresAt.Equal(now.Add(time.Hour)) || sub.UnsubscribeToken == "" {
+		t.Fatalf("unexpected confirm expiry or unsubscribe token %+v", sub)
+	}
+	if !strings.Contains(db.emails[0].TextBody, "/api/subscribe/confirm?token="+sub.ConfirmToken) {
+		t.Fatalf("confirm email should link the token, got %s", db.emails[0].TextBody)
+	}
+
+	// 不合法的邮箱或主题可以直接返回给用户
+	for _, bad := range []SubscribeRequest{{Email: "not-an-email", Topics: []string{"morning"}}, {Email: "a@example.com", Topics: []string{"weekly"}}} {
+		if _, err := Subscribe(db.DB, bad, limits, "198.51.100.1", now); !errors.Is(err, ErrInvalidSubscription) {
+			t.Fatalf("expected ErrInvalidSubscription for %+v, got %v", bad, err)
+		}
+	}
+
+	// 已有订阅：确认邮件发送间隔内拒绝，超过间隔后重新生成令牌
+	sentAt := now.Add(-time.Minute)
+	existing := models.Subscriber{ID: 7, Email: "reader@example.com", Status: models.SubscriberUnsubscribed, ConfirmSentAt: &sentAt}
+	db = newSubscriptionDB(t, existing)
+	if _, err := Subscribe(db.DB, req, limits, "198.51.100.2", now); !errors.Is(err, ErrSubscribeRateLimited) {
+		t.Fatalf("expected resend limit, got %v", err)
+	}
+	if _, err := Subscribe(db.DB, req, limits, "198.51.100.2", now.Add(limits.Resend)); err != nil {
+		t.Fatalf("resubscribe: %v", err)
+	}
+	if len(db.subscribers) != 0 || len(db.updates) != 1 || db.updates[0]["status"] != models.SubscriberPending || db.updates[0]["confirm_token"] == "" {
+		t.Fatalf("existing subscriber should be updated back to pending, got %+v", db.updates)
+	}
+
+	// 同一 IP 每小时的请求数受限
+	limits.MaxPerIPHour = 1
+	db = newSubscriptionDB(t)
+	if _, err := Subscribe(db.DB, req, limits, "198.51.100.3", now); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if _, err := Subscribe(db.DB, req, limits, "198.51.100.3", now.Add(time.Minute)); !errors.Is(err, ErrSubscribeRateLimited) {
+		t.Fatalf("expected ip limit, got %v", err)
+	}
+}
+
+func TestConfirmSubscription(t *testing.T) {
+	now := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	future, past := now.Add(time.Hour), now.Add(-time.Second)
+
+	db := newSubscriptionDB(t, models.Subscriber{ID: 1, PendingTopics: "noon", ConfirmExpiresAt: &future})
+	sub, err := ConfirmSubscription(db.DB, "tok", now)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if sub.Status != models.SubscriberActive || sub.Topics != "noon" || len(db.updates) != 1 || db.updates[0]["confirm_token"] != "" {
+		t.Fatalf("unexpected confirmation %+v %+v", sub, db.updates)
+	}
+
+	db = newSubscriptionDB(t, models.Subscriber{ID: 1, PendingTopics: "noon", ConfirmExpiresAt: &past})
+	if _, err := ConfirmSubscription(db.DB, "tok", now); !errors.Is(err, ErrConfirmExpired) || len(db.updates) != 0 {
+		t.Fatalf("expected expired link, got %v %+v", err, db.updates)
+	}
+
+	// 升级前发出的链接没有过期时间，按最后更新时间加默认有效期判断
+	ttl := CurrentSubscriptionLimits().ConfirmTTL
+	db = newSubscriptionDB(t, models.Subscriber{ID: 1, PendingTopics: "noon", UpdatedAt: now.Add(-ttl + time.Minute)})
+	if _, err := ConfirmSubscription(db.DB, "tok", now); err != nil {
+		t.Fatalf("recent legacy link should confirm, got %v", err)
+	}
+	db = newSubscriptionDB(t, models.Subscriber{ID: 1, PendingTopics: "noon", UpdatedAt: now.Add(-ttl)})
+	if _, err := ConfirmSubscription(db.DB, "tok", now); !errors.Is(err, ErrConfirmExpired) {
+		t.Fatalf("old legacy link should expire, got %v", err)
+	}
+
+	db = newSubscriptionDB(t)
+	if _, err := ConfirmSubscription(db.DB, "missing", now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestEnqueueDigestForTopic(t *testing.T) {
+	// topics like 查询会匹配到子串，需要按完整主题过滤
+	db := newSubscriptionDB(t,
+		models.Subscriber{ID: 1, Email: "a@example.com", Topics: "morning,analysis", UnsubscribeToken: "ua"},
+		models.Subscriber{ID: 2, Email: "b@example.com", Topics: "evening", UnsubscribeToken: "ub"},
+	)
+	data := DigestData{Title: "2026-01-02 早报", News: []models.NewsItem{{Title: "央行降准", Url: "https://example.com/a"}}}
+	if err := enqueueDigestForTopic(db.DB, "morning", data); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if len(db.emails) != 1 {
+		t.Fatalf("expected one email, got %+v", db.emails)
+	}
+	email := db.emails[0]
+	if email.To != "a@example.com" || email.Subject != data.Title || !strings.HasSuffix(email.Unsubscribe, "/api/unsubscribe?token=ua") {
+		t.Fatalf("unexpected email %+v", email)
+	}
+	if !strings.Contains(email.TextBody, "央行降准") || !strings.Contains(email.TextBody, email.Unsubscribe) {
+		t.Fatalf("digest body should contain news and unsubscribe link, got %s", email.TextBody)
+	}
+}
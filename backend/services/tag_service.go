@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTags 初始标签体系，标签表为空时写入
+var DefaultTags = []models.Tag{
+	{Slug: "macro", Name: "宏观", Sort: 10, Keywords: "GDP,CPI,PPI,PMI,通胀,降准,降息,加息,利率,央行,美联储,财政,货币政策,经济数据,就业,非农,国债"},
+	{Slug: "markets", Name: "股市", Sort: 20, Keywords: "A股,港股,美股,沪指,深成指,创业板,恒指,纳指,道指,标普,股价,涨停,IPO,上市,北向资金"},
+	{Slug: "commodities", Name: "大宗商品", Sort: 30, Keywords: "原油,油价,黄金,金价,白银,铜,铁矿石,煤炭,天然气,大宗商品,OPEC,期货"},
+	{Slug: "fx", Name: "汇率", Sort: 40, Keywords: "人民币,汇率,美元指数,日元,欧元,外汇,离岸"},
+	{Slug: "banking", Name: "金融机构", Sort: 50, Keywords: "银行,保险,券商,基金,信托,理财,金融监管,证监会,金融监管总局"},
+	{Slug: "property", Name: "房地产", Sort: 60, Keywords: "房地产,楼市,房价,房企,住房,土地,LPR,按揭"},
+	{Slug: "tech", Name: "科技", Sort: 70, Keywords: "AI,人工智能,芯片,半导体,大模型,科技,互联网,新能源车,电动车,算力,英伟达"},
+	{Slug: "trade", Name: "贸易", Sort: 80, Keywords: "关税,出口,进口,贸易,外贸,制裁,供应链"},
+}
+
+// MaxTagsPerNews 每条新闻最多的自动标签数
+const MaxTagsPerNews = 3
+
+// ClassifyNewsFunc 为每条新闻返回标签 slug 列表，下标与 items 一致
+type ClassifyNewsFunc func(tags []models.Tag, items []models.NewsItem) ([][]string, error)
+
+// EnsureDefaultTags 标签表为空时写入默认标签
+func EnsureDefaultTags(db *gorm.DB) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var count int64
+	if err := db.Model(&models.Tag{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	tags := make([]models.Tag, len(DefaultTags))
+	copy(tags, DefaultTags)
+	return db.Create(&tags).Error
+}
+
+// ClassifyNews 调用 AI 为新闻打标签，只能使用给定的标签
+func ClassifyNews(tags []models.Tag, items []models.NewsItem) ([][]string, error) {
+	if len(items) == 0 || len(tags) == 0 {
+		return make([][]string, len(items)), nil
+	}
+
+	var tagList strings.Builder
+	for _, t := range tags {
+		tagList.WriteString(fmt.Sprintf("- %s：%s\n", t.Slug, t.Name))
+	}
+	var newsList strings.Builder
+	for i, item := range items {
+		newsList.WriteString(fmt.Sprintf("[%d] %s", i, item.Title))
+		if item.Summary != "" {
+			newsList.WriteString(" | 摘要: " + truncateText(item.Summary, 200))
+		}
+		newsList.WriteString("\n")
+	}
+	prompt := fmt.Sprintf(`请为以下每条财经新闻选择 1 到 %d 个最贴切的标签，只能使用给定的标签代码，与所有标签都无关时返回空数组。
+请严格按照 JSON 对象数组格式输出，每个对象包含 index（新闻编号）和 tags（标签代码数组）字段，不要包含 Markdown 标记或其他多余文字。
+例如：[{"index": 0, "tags": ["macro", "fx"]}]
+可用标签：
+%s
+新闻列表：
+%s`, MaxTagsPerNews, tagList.String(), newsList.String())
+	log.Printf("AI Prompt: %s", prompt)
+
+	response, err := CallAI(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseClassifyResponse(response, tags, len(items))
+}
+
+// parseClassifyResponse 解析 AI 分类结果，丢弃越界编号和未知标签
+func parseClassifyResponse(response string, tags []models.Tag, n int) ([][]string, error) {
+	cleanResponse := strings.TrimSpace(response)
+	start := strings.Index(cleanResponse, "[")
+	end := strings.LastIndex(cleanResponse, "]")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = cleanResponse[start : end+1]
+	}
+	var classified []struct {
+		Index int      `json:"index"`
+		Tags  []string `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(cleanResponse), &classified); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %v. Response: %s", err, response)
+	}
+
+	known := map[string]bool{}
+	for _, t := range tags {
+		known[t.Slug] = true
+	}
+	out := make([][]string, n)
+	for _, c := range classified {
+		if c.Index < 0 || c.Index >= n {
+			continue
+		}
+		for _, slug := range c.Tags {
+			slug = strings.ToLower(strings.TrimSpace(slug))
+			if known[slug] && !containsString(out[c.Index], slug) && len(out[c.Index]) < MaxTagsPerNews {
+				out[c.Index] = append(out[c.Index], slug)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ClassifyByKeywords 按标签关键词匹配标题和摘要，命中次数多的标签优先
+func ClassifyByKeywords(tags []models.Tag, item models.NewsItem) []string {
+	text := strings.ToLower(item.Title + " " + item.Summary)
+	type hit struct {
+		slug  string
+		count int
+	}
+	var hits []hit
+	for _, t := range tags {
+		count := 0
+		for _, kw := range strings.Split(t.Keywords, ",") {
+			kw = strings.ToLower(strings.TrimSpace(kw))
+			if kw != "" && containsKeyword(text, kw) {
+				count++
+			}
+		}
+		if count > 0 {
+			hits = append(hits, hit{t.Slug, count})
+		}
+	}
+	// 命中数相同时保持标签顺序
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].count > hits[j].count })
+	var out []string
+	for _, h := range hits {
+		if len(out) >= MaxTagsPerNews {
+			break
+		}
+		out = append(out, h.slug)
+	}
+	return out
+}
+
+// containsKeyword 英文关键词按单词边界匹配，避免 "ai" 命中 "said" 之类的单词
+func containsKeyword(text, kw string) bool {
+	if !isASCIIWord(kw) {
+		return strings.Contains(text, kw)
+	}
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], kw)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(kw)
+		if (start == 0 || !isASCIIAlnum(text[start-1])) && (end == len(text) || !isASCIIAlnum(text[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isASCIIWord(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIIAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TagBatchNews 为批次内新闻自动打标签；AI 失败或未给出标签的条目按关键词兜底
+// 管理员手工修正过的新闻保持不变
+func TagBatchNews(db *gorm.DB, batchID uint, classify ClassifyNewsFunc) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if classify == nil {
+		classify = ClassifyNews
+	}
+
+	var tags []models.Tag
+	if err := db.Order("sort asc, id asc").Find(&tags).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	var items []models.NewsItem
+	manual := db.Model(&models.NewsItemTag{}).Select("news_item_id").Where("source = ?", models.TagSourceManual)
+	if err := db.Where("batch_id = ? AND tags_manual = ? AND id NOT IN (?)", batchID, false, manual).Order("id asc").Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	aiTags, err := classify(tags, items)
+	if err != nil {
+		fmt.Printf("AI 分类失败，使用关键词规则: %v\n", err)
+		aiTags = nil
+	}
+
+	tagIDs := map[string]uint{}
+	for _, t := range tags {
+		tagIDs[t.Slug] = t.ID
+	}
+	for i, item := range items {
+		slugs, source := []string(nil), models.TagSourceAI
+		if i < len(aiTags) {
+			slugs = aiTags[i]
+		}
+		if len(slugs) == 0 {
+			slugs, source = ClassifyByKeywords(tags, item), models.TagSourceRule
+		}
+		links := make([]models.NewsItemTag, 0, len(slugs))
+		for _, slug := range slugs {
+			if id, ok := tagIDs[slug]; ok {
+				links = append(links, models.NewsItemTag{NewsItemID: item.ID, TagID: id, Source: source})
+			}
+		}
+		if err := replaceNewsTags(db, item.ID, links); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNewsTags 管理员手工设置新闻标签，之后自动分类不再覆盖；传空列表清空标签
+func SetNewsTags(db *gorm.DB, newsID uint, tagIDs []uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var tags []models.Tag
+	if len(tagIDs) > 0 {
+		if err := db.Where("id IN ?", tagIDs).Find(&tags).Error; err != nil {
+			return err
+		}
+		if len(tags) != len(uniqueUints(tagIDs)) {
+			return errors.New("unknown tag")
+		}
+	}
+	links := make([]models.NewsItemTag, 0, len(tags))
+	for _, t := range tags {
+		links = append(links, models.NewsItemTag{NewsItemID: newsID, TagID: t.ID, Source: models.TagSourceManual})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.NewsItem{}).Where("id = ?", newsID).Update("tags_manual", true).Error; err != nil {
+			return err
+		}
+		return replaceNewsTags(tx, newsID, links)
+	})
+}
+
+func replaceNewsTags(db *gorm.DB, newsID uint, links []models.NewsItemTag) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("news_item_id = ?", newsID).Delete(&models.NewsItemTag{}).Error; err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		return tx.Create(&links).Error
+	})
+}
+
+func uniqueUints(values []uint) []uint {
+	seen := map[uint]bool{}
+	var out []uint
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
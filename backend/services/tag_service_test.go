@@ -0,0 +1,40 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyByKeywords(t *testing.T) {
+	cases := []struct {
+		title string
+		want  []string
+	}{
+		{"央行宣布降准 0.5 个百分点，人民币汇率走强", []string{"macro", "fx"}},
+		{"国际油价大涨，OPEC 宣布减产", []string{"commodities"}},
+		{"英伟达发布新一代 AI 芯片", []string{"tech"}},
+		{"Ukraine talks resume", nil}, // "ai" 不应命中 Ukraine
+	}
+	for _, tc := range cases {
+		got := ClassifyByKeywords(DefaultTags, models.NewsItem{Title: tc.title})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestParseClassifyResponse(t *testing.T) {
+	response := "```json\n[{\"index\": 0, \"tags\": [\"Macro\", \"unknown\", \"macro\"]}, {\"index\": 5, \"tags\": [\"tech\"]}, {\"index\": 1, \"tags\": [\"tech\", \"markets\", \"fx\", \"trade\"]}]\n```"
+	got, err := parseClassifyResponse(response, DefaultTags, 3)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := [][]string{{"macro"}, {"tech", "markets", "fx"}, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, err := parseClassifyResponse("无法分类", DefaultTags, 1); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
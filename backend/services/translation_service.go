@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LangZh = "zh"
+	LangEn = "en"
+
+	translationChunkSize = 10
+)
+
+// languageNames 提示词中使用的语言名称
+var languageNames = map[string]string{
+	LangZh: "简体中文",
+	LangEn: "英文（English）",
+}
+
+// IsSupportedLanguage 判断是否为支持的语言代码
+func IsSupportedLanguage(lang string) bool {
+	_, ok := languageNames[lang]
+	return ok
+}
+
+// DetectLanguage 按汉字与拉丁字母的比例判断文本是中文还是英文
+func DetectLanguage(text string) string {
+	han, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		}
+	}
+	// 一个汉字大约相当于一个英文单词（约 5 个字母）
+	if latin > han*5 {
+		return LangEn
+	}
+	return LangZh
+}
+
+// newsLanguage 返回新闻的原文语言，历史数据未记录时现场判断
+func newsLanguage(item models.NewsItem) string {
+	if item.Language != "" {
+		return item.Language
+	}
+	return DetectLanguage(item.Title)
+}
+
+// TranslatedText 翻译后的标题和摘要
+type TranslatedText struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// TranslateNewsFunc 将新闻翻译为 lang，下标与 items 一致，翻译失败的为 nil
+type TranslateNewsFunc func(items []models.NewsItem, lang string) ([]*TranslatedText, error)
+
+// TranslateNews 调用 AI 翻译新闻标题和摘要，按 translationChunkSize 分批请求
+func TranslateNews(items []models.NewsItem, lang string) ([]*TranslatedText, error) {
+	name, ok := languageNames[lang]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q", lang)
+	}
+	out := make([]*TranslatedText, 0, len(items))
+	for start := 0; start < len(items); start += translationChunkSize {
+		end := min(start+translationChunkSize, len(items))
+		var sb strings.Builder
+		for i, item := range items[start:end] {
+			sb.WriteString(fmt.Sprintf("[%d] 标题: %s\n", i, item.Title))
+			if item.Summary != "" {
+				sb.WriteString(fmt.Sprintf("摘要: %s\n", truncateText(item.Summary, 600)))
+			}
+		}
+		prompt := fmt.Sprintf(`请将以下财经新闻的标题和摘要翻译为%s，保留公司名、股票代码和数字，使用财经媒体的常用译法，不要增加原文没有的内容。
+请严格按照 JSON 对象数组格式输出，每个对象包含 index（新闻编号）、title 和 summary 字段，原文没有摘要时 summary 为空字符串，不要包含 Markdown 标记或其他多余文字。
+例如：[{"index": 0, "title": "...", "summary": "..."}]
+新闻列表：
+%s`, name, sb.String())
+		log.Printf("AI Prompt: %s", prompt)
+
+		response, err := CallAI(prompt)
+		if err != nil {
+			return nil, err
+		}
+		translated, err := parseTranslationResponse(response, end-start)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, translated...)
+	}
+	return out, nil
+}
+
+// parseTranslationResponse 解析 AI 翻译结果，丢弃越界编号和空标题
+func parseTranslationResponse(response string, n int) ([]*TranslatedText, error) {
+	cleanResponse := strings.TrimSpace(response)
+	start := strings.Index(cleanResponse, "[")
+	end := strings.LastIndex(cleanResponse, "]")
+	if start != -1 && end != -1 && end > start {
+		cleanResponse = cleanResponse[start : end+1]
+	}
+	var translated []struct {
+		Index   int    `json:"index"`
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(cleanResponse), &translated); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %v. Response: %s", err, response)
+	}
+	out := make([]*TranslatedText, n)
+	for _, t := range translated {
+		title := strings.TrimSpace(t.Title)
+		if t.Index < 0 || t.Index >= n || title == "" {
+			continue
+		}
+		out[t.Index] = &TranslatedText{Title: truncateText(title, 512), Summary: strings.TrimSpace(t.Summary)}
+	}
+	return out, nil
+}
+
+// TranslateBatchNews 将批次内新闻翻译为 languages 中除原文外的每种语言，已有译文的跳过
+func TranslateBatchNews(db *gorm.DB, batchID uint, languages []string, translate TranslateNewsFunc) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if translate == nil {
+		translate = TranslateNews
+	}
+	if len(languages) == 0 {
+		languages = []string{LangZh, LangEn}
+	}
+
+	var errs []error
+	for _, lang := range languages {
+		if !IsSupportedLanguage(lang) {
+			errs = append(errs, fmt.Errorf("unsupported language %q", lang))
+			continue
+		}
+		done := db.Model(&models.NewsTranslation{}).Select("news_item_id").Where("lang = ?", lang)
+		var candidates []models.NewsItem
+		if err := db.Where("batch_id = ? AND id NOT IN (?)", batchID, done).Order("id asc").Find(&candidates).Error; err != nil {
+			return err
+		}
+		var items []models.NewsItem
+		for _, item := range candidates {
+			if newsLanguage(item) != lang {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		translated, err := translate(items, lang)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("translate to %s: %w", lang, err))
+			continue
+		}
+		for i, item := range items {
+			if i >= len(translated) || translated[i] == nil {
+				continue
+			}
+			row := models.NewsTranslation{NewsItemID: item.ID, Lang: lang, Title: translated[i].Title, Summary: translated[i].Summary}
+			if err := SaveNewsTranslation(db, row); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SaveNewsTranslation 保存译文，已存在时覆盖
+func SaveNewsTranslation(db *gorm.DB, row models.NewsTranslation) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "news_item_id"}, {Name: "lang"}},
+		DoUpdates: clause.AssignmentColumns([]string{"title", "summary", "updated_at"}),
+	}).Create(&row).Error
+}
+
+// LocalizeNews 将新闻的标题和摘要替换为 lang 版本，没有译文时保留原文；正文不翻译
+func LocalizeNews(db *gorm.DB, items []models.NewsItem, lang string) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	var ids []uint
+	for _, item := range items {
+		if newsLanguage(item) != lang {
+			ids = append(ids, item.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var rows []models.NewsTranslation
+	if err := db.Where("news_item_id IN ? AND lang = ?", ids, lang).Find(&rows).Error; err != nil {
+		return err
+	}
+	byID := map[uint]models.NewsTranslation{}
+	for _, row := range rows {
+		byID[row.NewsItemID] = row
+	}
+	applyTranslations(items, byID)
+	return nil
+}
+
+func applyTranslations(items []models.NewsItem, byID map[uint]models.NewsTranslation) {
+	for i := range items {
+		t, ok := byID[items[i].ID]
+		if !ok {
+			continue
+		}
+		items[i].Title = t.Title
+		if t.Summary != "" {
+			items[i].Summary = t.Summary
+		}
+		// 译文晚于原文更新时以译文时间为准，订阅源的 ETag 和 Last-Modified 随之变化
+		if t.UpdatedAt.After(items[i].UpdatedAt) {
+			items[i].UpdatedAt = t.UpdatedAt
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"testing"
+	"time"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"央行宣布下调存款准备金率":                              LangZh,
+		"Fed holds rates steady as inflation cools": LangEn,
+		"苹果 Apple 发布新款 iPhone":                      LangZh,
+		"":                                          LangZh,
+	}
+	for text, want := range cases {
+		if got := DetectLanguage(text); got != want {
+			t.Fatalf("DetectLanguage(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func TestParseTranslationResponse(t *testing.T) {
+	response := "```json\n[{\"index\": 1, \"title\": \" Gold hits record \", \"summary\": \"\"}, {\"index\": 0, \"title\": \"\"}, {\"index\": 5, \"title\": \"x\"}]\n```"
+	got, err := parseTranslationResponse(response, 2)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got[0] != nil {
+		t.Fatalf("empty title should be skipped, got %+v", got[0])
+	}
+	if got[1] == nil || got[1].Title != "Gold hits record" {
+		t.Fatalf("unexpected translation %+v", got[1])
+	}
+}
+
+func TestApplyTranslations(t *testing.T) {
+	created := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	items := []models.NewsItem{
+		{ID: 1, Title: "黄金创新高", Summary: "原文摘要", UpdatedAt: created},
+		{ID: 2, Title: "美元走弱"},
+	}
+	applyTranslations(items, map[uint]models.NewsTranslation{
+		1: {NewsItemID: 1, Lang: LangEn, Title: "Gold hits record", UpdatedAt: created.Add(time.Hour)},
+	})
+	if items[0].Title != "Gold hits record" || items[0].Summary != "原文摘要" {
+		t.Fatalf("unexpected first item %+v", items[0])
+	}
+	if !items[0].UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Fatalf("translation time should bump updated_at, got %s", items[0].UpdatedAt)
+	}
+	if items[1].Title != "美元走弱" {
+		t.Fatalf("item without translation should keep original, got %+v", items[1])
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TopicEntity  = "entity"
+	TopicKeyword = "keyword"
+
+	trendingBaselineBatches = 21 // 基线取之前约一周的批次
+	minBurstCount           = 2  // 本批次至少被 2 条新闻提及
+	minBurstScore           = 2.0
+	maxTrendingTopics       = 20
+)
+
+// BurstTopic 相对基线突增的话题
+type BurstTopic struct {
+	Key      string
+	Count    int
+	Baseline float64
+	Score    float64
+}
+
+// DetectBursts 比较本批次与历史批次的提及数，按泊松近似计算突增分数：
+// (count - mean) / sqrt(mean + 1)，历史批次中未出现的话题按 0 计
+func DetectBursts(current map[string]int, history []map[string]int) []BurstTopic {
+	if len(history) == 0 {
+		return nil
+	}
+	var out []BurstTopic
+	for key, count := range current {
+		if count < minBurstCount {
+			continue
+		}
+		sum := 0
+		for _, h := range history {
+			sum += h[key]
+		}
+		mean := float64(sum) / float64(len(history))
+		score := (float64(count) - mean) / math.Sqrt(mean+1)
+		if score < minBurstScore {
+			continue
+		}
+		out = append(out, BurstTopic{
+			Key:      key,
+			Count:    count,
+			Baseline: math.Round(mean*100) / 100,
+			Score:    math.Round(score*100) / 100,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+type topicInfo struct {
+	kind     string
+	label    string
+	entityID uint
+}
+
+// countBatchTopics 统计各批次中每个实体和关键词被多少条新闻提及
+func countBatchTopics(db *gorm.DB, batchIDs []uint) (map[uint]map[string]int, map[string]topicInfo, error) {
+	counts := map[uint]map[string]int{}
+	for _, id := range batchIDs {
+		counts[id] = map[string]int{}
+	}
+	infos := map[string]topicInfo{}
+
+	var entityRows []struct {
+		BatchID  uint
+		EntityID uint
+		Name     string
+		Count    int
+	}
+	err := db.Table("entity_mentions").
+		Select("news_items.batch_id, entity_mentions.entity_id, entities.name, COUNT(DISTINCT news_items.id) AS count").
+		Joins("JOIN news_items ON news_items.id = entity_mentions.news_item_id AND news_items.deleted_at IS NULL AND news_items.status <> ?", models.StatusRejected).
+		Joins("JOIN entities ON entities.id = entity_mentions.entity_id").
+		Where("news_items.batch_id IN ?", batchIDs).
+		Group("news_items.batch_id, entity_mentions.entity_id, entities.name").
+		Scan(&entityRows).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, row := range entityRows {
+		key := fmt.Sprintf("%s:%d", TopicEntity, row.EntityID)
+		counts[row.BatchID][key] = row.Count
+		infos[key] = topicInfo{kind: TopicEntity, label: row.Name, entityID: row.EntityID}
+	}
+
+	// 关键词使用标签的关键词表作为词汇表
+	var tags []models.Tag
+	if err := db.Find(&tags).Error; err != nil {
+		return nil, nil, err
+	}
+	var keywords []string
+	for _, t := range tags {
+		for _, kw := range strings.Split(t.Keywords, ",") {
+			if kw = strings.TrimSpace(kw); kw != "" && !containsString(keywords, kw) {
+				keywords = append(keywords, kw)
+			}
+		}
+	}
+	var news []models.NewsItem
+	if err := db.Select("id, batch_id, title, summary").Where("batch_id IN ? AND status <> ?", batchIDs, models.StatusRejected).Find(&news).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, n := range news {
+		text := strings.ToLower(n.Title + " " + n.Summary)
+		for _, kw := range keywords {
+			if containsKeyword(text, strings.ToLower(kw)) {
+				key := TopicKeyword + ":" + kw
+				counts[n.BatchID][key]++
+				infos[key] = topicInfo{kind: TopicKeyword, label: kw}
+			}
+		}
+	}
+	return counts, infos, nil
+}
+
+// UpdateTrendingTopics 计算批次的热点话题并替换该批次的快照
+func UpdateTrendingTopics(db *gorm.DB, batchID uint) ([]models.TrendingTopic, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var batch models.BatchLog
+	if err := db.First(&batch, batchID).Error; err != nil {
+		return nil, err
+	}
+	var history []models.BatchLog
+	if err := db.Where("created_at < ?", batch.CreatedAt).Order("created_at desc").Limit(trendingBaselineBatches).Find(&history).Error; err != nil {
+		return nil, err
+	}
+	batchIDs := []uint{batch.ID}
+	for _, h := range history {
+		batchIDs = append(batchIDs, h.ID)
+	}
+
+	counts, infos, err := countBatchTopics(db, batchIDs)
+	if err != nil {
+		return nil, err
+	}
+	var baseline []map[string]int
+	for _, h := range history {
+		baseline = append(baseline, counts[h.ID])
+	}
+
+	var topics []models.TrendingTopic
+	usedLabels := map[string]bool{}
+	for _, burst := range DetectBursts(counts[batch.ID], baseline) {
+		info := infos[burst.Key]
+		// 实体和关键词可能是同一个词，只保留分数更高的一个
+		label := strings.ToLower(info.label)
+		if usedLabels[label] {
+			continue
+		}
+		usedLabels[label] = true
+		topics = append(topics, models.TrendingTopic{
+			BatchID:  batch.ID,
+			Kind:     info.kind,
+			Key:      burst.Key,
+			Label:    info.label,
+			EntityID: info.entityID,
+			Count:    burst.Count,
+			Baseline: burst.Baseline,
+			Score:    burst.Score,
+			Rank:     len(topics) + 1,
+		})
+		if len(topics) >= maxTrendingTopics {
+			break
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ?", batch.ID).Delete(&models.TrendingTopic{}).Error; err != nil {
+			return err
+		}
+		if len(topics) == 0 {
+			return nil
+		}
+		return tx.Create(&topics).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestDetectBursts(t *testing.T) {
+	history := []map[string]int{
+		{"keyword:黄金": 1, "keyword:降息": 3},
+		{"keyword:降息": 3},
+		{"keyword:降息": 2, "entity:7": 1},
+		{"keyword:降息": 4},
+	}
+	current := map[string]int{
+		"keyword:黄金": 5, // 基线 0.25，明显突增
+		"keyword:降息": 4, // 一直很热，不算突增
+		"entity:7":   3,
+		"entity:9":   1, // 提及数太少
+	}
+	got := DetectBursts(current, history)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 bursts, got %+v", got)
+	}
+	if got[0].Key != "keyword:黄金" || got[0].Baseline != 0.25 || got[0].Score != 4.25 {
+		t.Fatalf("unexpected first burst %+v", got[0])
+	}
+	if got[1].Key != "entity:7" {
+		t.Fatalf("unexpected second burst %+v", got[1])
+	}
+	if DetectBursts(current, nil) != nil {
+		t.Fatal("expected no bursts without history")
+	}
+}
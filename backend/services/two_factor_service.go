@@ -0,0 +1,442 @@
+package services
+
+import (
+	"bre_new_backend/config"
+	"bre_new_backend/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// TOTP 参数（RFC 6238），与常见验证器 App 的默认值一致
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // 允许前后各一个时间步的时钟偏差
+
+	recoveryCodeCount    = 10
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+
+	settingRequire2FA = "require_2fa"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrTOTPSetupMissing   = errors.New("two-factor setup not started")
+	ErrTwoFactorRequired  = errors.New("two-factor authentication is required")
+	ErrInvalidOTP         = errors.New("invalid verification code")
+	ErrChallengeInvalid   = errors.New("login challenge expired or invalid")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret 生成 160 位随机密钥的 base32 编码
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// hotp RFC 4226 HOTP 算法
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// TOTPCode 计算 t 时刻的验证码
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, t.Unix()/totpPeriod), nil
+}
+
+// matchTOTP 在允许的时钟偏差内校验验证码，返回匹配的时间步；不接受不晚于 lastStep 的时间步，防止重放
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpIssuer() string {
+	if config.AppConfig.Login.TOTPIssuer != "" {
+		return config.AppConfig.Login.TOTPIssuer
+	}
+	return "BRE News"
+}
+
+// TOTPProvisioningURI 生成 otpauth:// 链接，供前端渲染二维码或手动录入
+func TOTPProvisioningURI(issuer, username, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(username)
+	// 部分验证器 App 不识别查询参数中表示空格的 +
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// normalizeOTP 去掉用户输入中的空格和连字符并转小写
+func normalizeOTP(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeOTP(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes 生成一组 xxxxx-xxxxx 形式的恢复码
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, s[:5]+"-"+s[5:])
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes 删除旧恢复码并保存新恢复码的哈希，返回明文（只展示一次）
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.AdminRecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, models.AdminRecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartTOTPSetup 为未启用两步验证的管理员生成新密钥，启用前需用 EnableTOTP 确认
+func StartTOTPSetup(db *gorm.DB, userID uint) (secret, uri string, err error) {
+	if db == nil {
+		return "", "", errors.New("db is nil")
+	}
+	var user models.AdminUser
+	if err := db.First(&user, userID).Error; err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.Model(&user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, TOTPProvisioningURI(totpIssuer(), user.Username, secret), nil
+}
+
+// EnableTOTP 用验证器 App 生成的验证码确认密钥并启用两步验证，返回恢复码
+func EnableTOTP(db *gorm.DB, userID uint, code string, now time.Time) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var user models.AdminUser
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.TOTPEnabled {
+			return ErrTOTPAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return ErrTOTPSetupMissing
+		}
+		step, ok := matchTOTP(user.TOTPSecret, normalizeOTP(code), now, user.TOTPLastStep)
+		if !ok {
+			return ErrInvalidOTP
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 关闭两步验证并删除密钥和恢复码，是否允许关闭由调用方判断
+func DisableTOTP(db *gorm.DB, userID uint) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.AdminUser
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.AdminLoginChallenge{}).Error
+	})
+}
+
+// DisableOwnTOTP 管理员关闭自己的两步验证：须提供当前验证码或恢复码，有本地密码的账号还需验证密码
+func DisableOwnTOTP(db *gorm.DB, user *models.AdminUser, password, code string, now time.Time) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	// 先校验第二因素，未通过时不判断密码，避免被用作不受登录锁定限制的密码猜测接口
+	ok, err := verifySecondFactor(db, user, code, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidOTP
+	}
+	if user.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	return DisableTOTP(db, user.ID)
+}
+
+// RegenerateRecoveryCodes 校验当前验证码后重新生成恢复码，旧恢复码全部作废
+func RegenerateRecoveryCodes(db *gorm.DB, userID uint, code string, now time.Time) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var user models.AdminUser
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+	ok, err := verifySecondFactor(db, &user, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidOTP
+	}
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingRecoveryCodes 未使用的恢复码数量
+func RemainingRecoveryCodes(db *gorm.DB, userID uint) (int64, error) {
+	if db == nil {
+		return 0, errors.New("db is nil")
+	}
+	var count int64
+	err := db.Model(&models.AdminRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// verifySecondFactor 校验 6 位验证码或恢复码；通过的时间步和恢复码用条件更新占用，同一个码并发提交只有一次成功
+func verifySecondFactor(db *gorm.DB, user *models.AdminUser, code string, now time.Time) (bool, error) {
+	code = normalizeOTP(code)
+	if code == "" {
+		return false, nil
+	}
+	if len(code) == totpDigits && strings.Trim(code, "0123456789") == "" {
+		step, ok := matchTOTP(user.TOTPSecret, code, now, user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		res := db.Model(&models.AdminUser{}).Where("id = ? AND totp_last_step < ?", user.ID, step).Update("totp_last_step", step)
+		if res.Error != nil {
+			return false, res.Error
+		}
+		user.TOTPLastStep = step
+		return res.RowsAffected == 1, nil
+	}
+
+	var rc models.AdminRecoveryCode
+	err := db.Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).First(&rc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	res := db.Model(&models.AdminRecoveryCode{}).Where("id = ? AND used_at IS NULL", rc.ID).Update("used_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// CreateLoginChallenge 密码校验通过后创建第二步验证的挑战，有效期 5 分钟
+func CreateLoginChallenge(db *gorm.DB, userID uint, now time.Time) (*models.AdminLoginChallenge, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.AdminLoginChallenge{}).Error; err != nil {
+		fmt.Printf("清理过期登录挑战失败: %v\n", err)
+	}
+	challenge := models.AdminLoginChallenge{Token: token, UserID: userID, ExpiresAt: now.Add(loginChallengeTTL), CreatedAt: now}
+	if err := db.Create(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CompleteLoginChallenge 第二步验证：校验验证码或恢复码，通过后返回管理员，由调用方创建会话。
+// 失败同样计入用户名和 IP 的失败次数，单个挑战最多尝试 5 次
+func CompleteLoginChallenge(db *gorm.DB, policy LoginPolicy, token, code, ip, userAgent string, now time.Time) (*models.AdminUser, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	var challenge models.AdminLoginChallenge
+	if err := db.Where("token = ?", token).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChallengeInvalid
+		}
+		return nil, err
+	}
+	if !now.Before(challenge.ExpiresAt) {
+		db.Delete(&challenge)
+		return nil, ErrChallengeInvalid
+	}
+	var user models.AdminUser
+	if err := db.First(&user, challenge.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			db.Delete(&challenge)
+			return nil, ErrChallengeInvalid
+		}
+		return nil, err
+	}
+
+	userKey, ipKey := userLockKey(user.Username), IPLockKey(ip)
+	if err := checkLoginLocks(db, now, userKey, ipKey); err != nil {
+		var locked *LoginLockedError
+		if errors.As(err, &locked) {
+			recordLoginAttempt(db, user.Username, ip, userAgent, LoginReasonLocked, now)
+		}
+		return nil, err
+	}
+
+	ok, err := verifySecondFactor(db, &user, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := registerLoginFailures(db, policy, userKey, ipKey, now); err != nil {
+			return nil, err
+		}
+		recordLoginAttempt(db, user.Username, ip, userAgent, LoginReasonBadCode, now)
+		challenge.Attempts++
+		if challenge.Attempts >= maxChallengeAttempts {
+			db.Delete(&challenge)
+		} else {
+			db.Model(&challenge).Update("attempts", challenge.Attempts)
+		}
+		return nil, ErrInvalidOTP
+	}
+
+	// 挑战只能使用一次
+	if res := db.Where("token = ?", token).Delete(&models.AdminLoginChallenge{}); res.Error != nil || res.RowsAffected == 0 {
+		return nil, ErrChallengeInvalid
+	}
+	clearLoginLock(db, userKey)
+	recordLoginAttempt(db, user.Username, ip, userAgent, LoginReasonOK, now)
+	return &user, nil
+}
+
+// TwoFactorRequired 是否要求所有管理员启用两步验证
+func TwoFactorRequired(db *gorm.DB) (bool, error) {
+	if db == nil {
+		return false, errors.New("db is nil")
+	}
+	var setting models.AdminSetting
+	err := db.Where("`key` = ?", settingRequire2FA).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return setting.Value == "true", nil
+}
+
+// SetTwoFactorRequired 修改两步验证策略
+func SetTwoFactorRequired(db *gorm.DB, required bool) error {
+	if db == nil {
+		return errors.New("db is nil")
+	}
+	return db.Save(&models.AdminSetting{Key: settingRequire2FA, Value: fmt.Sprint(required)}).Error
+}
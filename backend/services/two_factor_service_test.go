@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bre_new_backend/models"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量（取后 6 位）
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFCVectors(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		got, err := TOTPCode(rfcTOTPSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode error: %v", err)
+		}
+		if got != tc.want {
+			t.Fatalf("TOTPCode(%d) = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestMatchTOTPSkewAndReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	prev, _ := TOTPCode(rfcTOTPSecret, now.Add(-30*time.Second))
+	step, ok := matchTOTP(rfcTOTPSecret, prev, now, 0)
+	if !ok || step != now.Unix()/30-1 {
+		t.Fatalf("previous step should be accepted, got %d %v", step, ok)
+	}
+	if _, ok := matchTOTP(rfcTOTPSecret, prev, now, step); ok {
+		t.Fatal("a used step must not be accepted again")
+	}
+	old, _ := TOTPCode(rfcTOTPSecret, now.Add(-2*time.Minute))
+	if _, ok := matchTOTP(rfcTOTPSecret, old, now, 0); ok {
+		t.Fatal("codes outside the skew window must be rejected")
+	}
+	if _, ok := matchTOTP(rfcTOTPSecret, "12345", now, 0); ok {
+		t.Fatal("short codes must be rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("BRE News", "alice", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/BRE%20News:alice?") {
+		t.Fatalf("unexpected label: %s", uri)
+	}
+	for _, part := range []string{"secret=ABC", "issuer=BRE%20News", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Fatalf("uri %s missing %s", uri, part)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Fatalf("unexpected format %q", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+	// 用户输入时大小写、空格和连字符不影响匹配
+	input := " " + strings.ToUpper(strings.Replace(codes[0], "-", "", 1)) + " "
+	if hashRecoveryCode(input) != hashRecoveryCode(codes[0]) {
+		t.Fatal("normalized input should hash to the same value")
+	}
+}
+
+func TestDisableOwnTOTPRequiresSecondFactor(t *testing.T) {
+	db, _ := newCaptureDB(t)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Correct-Horse-1"), bcrypt.MinCost)
+	user := &models.AdminUser{ID: 1, PasswordHash: string(hash), TOTPEnabled: true, TOTPSecret: rfcTOTPSecret}
+	now := time.Unix(59, 0)
+	code, _ := TOTPCode(rfcTOTPSecret, now.Add(time.Hour)) // 超出允许的时钟偏差
+
+	// 只有密码不能关闭，错误的验证码在密码之前被拒绝
+	if err := DisableOwnTOTP(db, user, "Correct-Horse-1", code, now); !errors.Is(err, ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP, got %v", err)
+	}
+	if err := DisableOwnTOTP(db, user, "Correct-Horse-1", "", now); !errors.Is(err, ErrInvalidOTP) {
+		t.Fatalf("empty code should be rejected, got %v", err)
+	}
+	user.TOTPEnabled = false
+	if err := DisableOwnTOTP(db, user, "", "287082", now); !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Fatalf("expected ErrTOTPNotEnabled, got %v", err)
+	}
+}
@@ -42,6 +42,36 @@ export async function adminLogin({ username, password }) {
   return res.data
 }
 
+export async function adminLoginVerify({ challenge, code }) {
+  const res = await api.post('/admin/login/verify', { challenge, code })
+  return res.data
+}
+
+export async function adminOIDCConfig() {
+  const res = await api.get('/admin/oidc/config')
+  return res.data
+}
+
+// 单点登录由浏览器整页跳转，回调后结果放在地址的 # 片段中
+export const adminOIDCLoginURL = `${api.defaults.baseURL}/admin/oidc/login`
+
+// 关联单点登录账号：返回身份提供方地址，回调后用片段中的 sso_link 确认
+export async function adminOIDCLink() {
+  const res = await api.post('/admin/me/oidc/link')
+  return res.data
+}
+
+export async function adminOIDCLinkConfirm(token) {
+  const res = await api.post('/admin/me/oidc/link/confirm', { token })
+  return res.data
+}
+
+// 修改自己的密码，成功后其他设备的登录会被注销
+export async function adminPasswordChange({ oldPassword, newPassword }) {
+  const res = await api.post('/admin/me/password', { old_password: oldPassword, new_password: newPassword })
+  return res.data
+}
+
 export async function adminLogout() {
   const res = await api.post('/admin/logout')
   return res.data
@@ -52,8 +82,74 @@ export async function adminUserList() {
   return res.data
 }
 
-export async function adminUserCreate({ username, password }) {
-  const res = await api.post('/admin/users', { username, password })
+export async function adminUserCreate({ username, password, role }) {
+  const res = await api.post('/admin/users', { username, password, role })
+  return res.data
+}
+
+// role: owner, editor, operator, viewer
+export async function adminUserSetRole({ id, role }) {
+  const res = await api.patch(`/admin/users/${id}/role`, { role })
+  return res.data
+}
+
+export async function adminMe() {
+  const res = await api.get('/admin/me')
+  return res.data
+}
+
+export async function adminSessionList() {
+  const res = await api.get('/admin/me/sessions')
+  return res.data
+}
+
+export async function adminSessionRevoke(id) {
+  const res = await api.delete(`/admin/me/sessions/${id}`)
+  return res.data
+}
+
+export async function adminSessionRevokeOthers() {
+  const res = await api.delete('/admin/me/sessions')
+  return res.data
+}
+
+export async function adminTOTPStatus() {
+  const res = await api.get('/admin/me/totp')
+  return res.data
+}
+
+export async function adminTOTPSetup() {
+  const res = await api.post('/admin/me/totp/setup')
+  return res.data
+}
+
+export async function adminTOTPEnable(code) {
+  const res = await api.post('/admin/me/totp/enable', { code })
+  return res.data
+}
+
+export async function adminTOTPDisable(code, password) {
+  const res = await api.post('/admin/me/totp/disable', { code, password })
+  return res.data
+}
+
+export async function adminTOTPRecoveryCodes(code) {
+  const res = await api.post('/admin/me/totp/recovery-codes', { code })
+  return res.data
+}
+
+export async function adminUserResetTOTP(id) {
+  const res = await api.delete(`/admin/users/${id}/totp`)
+  return res.data
+}
+
+export async function adminSecurityPolicy() {
+  const res = await api.get('/admin/security-policy')
+  return res.data
+}
+
+export async function adminSecurityPolicyUpdate({ require_2fa }) {
+  const res = await api.put('/admin/security-policy', { require_2fa })
   return res.data
 }
 
@@ -67,11 +163,71 @@ export async function adminUserDelete(id) {
   return res.data
 }
 
+export async function adminAuditLogList({ action, targetType, targetId, username, createdAtStart, createdAtEnd, page, pageSize } = {}) {
+  const params = new URLSearchParams()
+  if (action) params.set('action', action)
+  if (targetType) params.set('targetType', targetType)
+  if (targetId) params.set('targetId', String(targetId))
+  if (username) params.set('username', username)
+  if (createdAtStart) params.set('createdAtStart', createdAtStart)
+  if (createdAtEnd) params.set('createdAtEnd', createdAtEnd)
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
+  const qs = params.toString() ? `?${params.toString()}` : ''
+  const res = await api.get(`/admin/audit-logs${qs}`)
+  return res.data
+}
+
+export async function adminLoginAttemptList({ username, ip, success, reason, page, pageSize } = {}) {
+  const params = new URLSearchParams()
+  if (username) params.set('username', username)
+  if (ip) params.set('ip', ip)
+  if (success !== '' && success !== undefined) params.set('success', String(success))
+  if (reason) params.set('reason', reason)
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
+  const qs = params.toString() ? `?${params.toString()}` : ''
+  const res = await api.get(`/admin/login-attempts${qs}`)
+  return res.data
+}
+
+export async function adminLoginReport(days = 7) {
+  const res = await api.get(`/admin/login-attempts/report?days=${days}`)
+  return res.data
+}
+
+export async function adminLoginUnlock(key) {
+  const res = await api.delete(`/admin/login-locks/${encodeURIComponent(key)}`)
+  return res.data
+}
+
+export async function adminAPIKeyList({ userId, active, page, pageSize } = {}) {
+  const params = new URLSearchParams()
+  if (userId) params.set('userId', String(userId))
+  if (active) params.set('active', '1')
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
+  const qs = params.toString() ? `?${params.toString()}` : ''
+  const res = await api.get(`/admin/api-keys${qs}`)
+  return res.data
+}
+
+export async function adminAPIKeyCreate({ name, scopes, user_id, expires_in_days }) {
+  const res = await api.post('/admin/api-keys', { name, scopes, user_id, expires_in_days })
+  return res.data
+}
+
+export async function adminAPIKeyRevoke(id) {
+  const res = await api.delete(`/admin/api-keys/${id}`)
+  return res.data
+}
+
 // User Aliases
 export const getUsers = adminUserList
 export const createUser = adminUserCreate
 export const updateUserPassword = adminUserSetPassword
 export const deleteUser = adminUserDelete
+export const updateUserRole = adminUserSetRole
 
 export async function adminSiteCategoryList() {
   const res = await api.get('/admin/site-categories')
@@ -126,11 +282,14 @@ export const createSite = adminSiteCreate
 export const updateSite = adminSiteUpdate
 export const deleteSite = adminSiteDelete
 
-export async function adminBatchList({ type, createdAtStart, createdAtEnd } = {}) {
+export async function adminBatchList({ type, status, createdAtStart, createdAtEnd, page, pageSize } = {}) {
   const params = new URLSearchParams()
   if (type) params.set('type', type)
+  if (status) params.set('status', status)
   if (createdAtStart) params.set('createdAtStart', createdAtStart)
   if (createdAtEnd) params.set('createdAtEnd', createdAtEnd)
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
   const qs = params.toString() ? `?${params.toString()}` : ''
   const res = await api.get(`/admin/batches${qs}`)
   return res.data
@@ -146,17 +305,40 @@ export async function adminBatchDelete(batchId) {
   return res.data
 }
 
+// action: submit, approve, reject, withdraw
+export async function adminBatchReview(batchId, action, note = '') {
+  const res = await api.post(`/admin/batches/${batchId}/review`, { action, note })
+  return res.data
+}
+
+// ids 为新的顺序，未列出的新闻排在后面
+export async function adminBatchReorder(batchId, ids) {
+  const res = await api.put(`/admin/batches/${batchId}/order`, { ids })
+  return res.data
+}
+
+export async function adminNewsReview(newsId, action) {
+  const res = await api.post(`/admin/news/${newsId}/review`, { action })
+  return res.data
+}
+
 // Batch Aliases
 export const getBatches = adminBatchList
 export const getBatchNews = adminBatchNewsList
 export const deleteBatch = adminBatchDelete
+export const reviewBatch = adminBatchReview
+export const reorderBatch = adminBatchReorder
+export const reviewNews = adminNewsReview
 
-export async function adminNewsList({ batchId, keyword, createdAtStart, createdAtEnd } = {}) {
+export async function adminNewsList({ batchId, keyword, tag, createdAtStart, createdAtEnd, page, pageSize } = {}) {
   const params = new URLSearchParams()
   if (batchId) params.set('batchId', String(batchId))
   if (keyword) params.set('keyword', keyword)
+  if (tag) params.set('tag', tag)
   if (createdAtStart) params.set('createdAtStart', createdAtStart)
   if (createdAtEnd) params.set('createdAtEnd', createdAtEnd)
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
   const qs = params.toString() ? `?${params.toString()}` : ''
   const res = await api.get(`/admin/news${qs}`)
   return res.data
@@ -183,12 +365,14 @@ export const createNews = adminNewsCreate
 export const updateNews = adminNewsUpdate
 export const deleteNews = adminNewsDelete
 
-export async function adminAnalysisList({ batchId, type, createdAtStart, createdAtEnd } = {}) {
+export async function adminAnalysisList({ batchId, type, createdAtStart, createdAtEnd, page, pageSize } = {}) {
   const params = new URLSearchParams()
   if (batchId) params.set('batchId', String(batchId))
   if (type) params.set('type', type)
   if (createdAtStart) params.set('createdAtStart', createdAtStart)
   if (createdAtEnd) params.set('createdAtEnd', createdAtEnd)
+  if (page) params.set('page', String(page))
+  if (pageSize) params.set('pageSize', String(pageSize))
   const qs = params.toString() ? `?${params.toString()}` : ''
   const res = await api.get(`/admin/analysis${qs}`)
   return res.data